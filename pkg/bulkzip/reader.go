@@ -0,0 +1,47 @@
+// Package bulkzip reads the zip files of the bulk data sets from a stream,
+// e.g. the body of a download or a zip file within a bulk zip file.
+package bulkzip
+
+import (
+	"archive/zip"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/krolaw/zipstream"
+)
+
+// ErrMalformedZipStream is returned if a zip stream can not be read
+var ErrMalformedZipStream = errors.New("malformed zip stream")
+
+// Reader reads the entries of a zip file from a stream.
+// zipstream panics on some truncated streams, these panics are returned as ErrMalformedZipStream.
+// Only the reads of the stream are recovered, panics of the callers are not.
+type Reader struct {
+	zr *zipstream.Reader
+}
+
+// NewReader creates a reader of the zip stream
+func NewReader(r io.Reader) *Reader {
+	return &Reader{zr: zipstream.NewReader(r)}
+}
+
+// Next advances to the next entry of the zip stream,
+// io.EOF is returned at the end of the zip stream
+func (r *Reader) Next() (header *zip.FileHeader, err error) {
+	defer recoverMalformed(&err)
+	return r.zr.Next()
+}
+
+// Read reads from the current entry of the zip stream
+func (r *Reader) Read(p []byte) (n int, err error) {
+	defer recoverMalformed(&err)
+	return r.zr.Read(p)
+}
+
+// recoverMalformed converts a panic of zipstream into an ErrMalformedZipStream
+func recoverMalformed(err *error) {
+	if v := recover(); v != nil {
+		*err = fmt.Errorf("%w: %v", ErrMalformedZipStream, v)
+	}
+}
@@ -0,0 +1,78 @@
+package bulkzip
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// zipBytes creates a zip file with the entries
+func zipBytes(t *testing.T, entries map[string]string) []byte {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, content := range entries {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, _ = w.Write([]byte(content))
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestReader(t *testing.T) {
+	ass := assert.New(t)
+	zr := NewReader(bytes.NewReader(zipBytes(t, map[string]string{"a.csv": "a,b\n1,2\n"})))
+
+	header, err := zr.Next()
+	ass.NoError(err)
+	ass.Equal("a.csv", header.Name)
+	content, err := io.ReadAll(zr)
+	ass.NoError(err)
+	ass.Equal("a,b\n1,2\n", string(content))
+	_, err = zr.Next()
+	ass.Equal(io.EOF, err)
+}
+
+func TestReaderMalformed(t *testing.T) {
+	ass := assert.New(t)
+	data := zipBytes(t, map[string]string{"a.csv": string(bytes.Repeat([]byte("a,b\n"), 1000))})
+
+	// the stream ends within the entry
+	var err error
+	for _, size := range []int{10, 40, len(data) / 2, len(data) - 10} {
+		zr := NewReader(bytes.NewReader(data[:size]))
+		_, err = zr.Next()
+		if err == nil {
+			_, err = io.ReadAll(zr)
+		}
+		if err == nil {
+			_, err = zr.Next()
+		}
+		ass.Error(err, size)
+	}
+
+	// zipstream panics if there is no current entry
+	zr := NewReader(bytes.NewReader(data))
+	_, err = zr.Read(make([]byte, 1))
+	ass.ErrorIs(err, ErrMalformedZipStream)
+}
+
+func TestReaderDoesNotRecoverCallers(t *testing.T) {
+	ass := assert.New(t)
+	zr := NewReader(bytes.NewReader(zipBytes(t, map[string]string{"a.csv": "a"})))
+	_, err := zr.Next()
+	ass.NoError(err)
+
+	// a panic while the entry is handled is not converted into an error
+	ass.PanicsWithValue("handler", func() {
+		_, _ = zr.Read(make([]byte, 1))
+		panic("handler")
+	})
+}
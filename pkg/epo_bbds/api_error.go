@@ -0,0 +1,92 @@
+package epo_bbds
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxErrorBodySize is the maximum number of bytes of the response body kept in an APIError
+const maxErrorBodySize = 1024
+
+// APIError is returned if the bulk data service responds with an unexpected status code.
+// It matches the sentinel error of the operation with errors.Is,
+// i.e. ErrNo200StatusCode for the login and the catalog and ErrCanNotDownload for downloads.
+type APIError struct {
+	StatusCode int           // http status code
+	URL        string        // requested url
+	Body       string        // beginning of the response body
+	RetryAfter time.Duration // value of the Retry-After header, zero if not set
+	sentinel   error
+}
+
+// newAPIError creates an APIError from the response and reads the beginning of the body
+func newAPIError(resp *http.Response, sentinel error) *APIError {
+	e := &APIError{
+		StatusCode: resp.StatusCode,
+		RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()),
+		sentinel:   sentinel,
+	}
+	if resp.Request != nil && resp.Request.URL != nil {
+		e.URL = resp.Request.URL.String()
+	}
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBodySize))
+	e.Body = strings.TrimSpace(string(body))
+	return e
+}
+
+// Error returns the error message
+func (e *APIError) Error() string {
+	msg := fmt.Sprintf("%s: %d %s from %s", e.sentinel, e.StatusCode, http.StatusText(e.StatusCode), e.URL)
+	if e.Body != "" {
+		msg += ": " + e.Body
+	}
+	return msg
+}
+
+// Unwrap returns the sentinel error of the operation
+func (e *APIError) Unwrap() error {
+	return e.sentinel
+}
+
+// Temporary reports if the request can be retried
+func (e *APIError) Temporary() bool {
+	return retryableStatusCode(e.StatusCode)
+}
+
+// retryableStatusCode reports if a request with this status code can be retried
+func retryableStatusCode(statusCode int) bool {
+	switch statusCode {
+	case http.StatusTooManyRequests,
+		http.StatusInternalServerError,
+		http.StatusBadGateway,
+		http.StatusServiceUnavailable,
+		http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// parseRetryAfter parses the Retry-After header,
+// which is either a number of seconds or a http date
+func parseRetryAfter(header string, now time.Time) time.Duration {
+	header = strings.TrimSpace(header)
+	if header == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(header); err == nil {
+		if seconds < 0 {
+			return 0
+		}
+		return time.Duration(seconds) * time.Second
+	}
+	if date, err := http.ParseTime(header); err == nil {
+		if d := date.Sub(now); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
@@ -0,0 +1,45 @@
+package epo_bbds
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAPIError(t *testing.T) {
+	ass := assert.New(t)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		_, _ = w.Write([]byte(`{"error":"product not found"}` + strings.Repeat(" ", 2000) + "tail"))
+	}))
+	defer srv.Close()
+	c := NewClient(WithBaseURL(srv.URL))
+
+	_, err := c.GetEpoBddsFileItems("Bearer x", "99")
+	ass.ErrorIs(err, ErrNo200StatusCode)
+	var apiErr *APIError
+	ass.True(errors.As(err, &apiErr))
+	ass.Equal(http.StatusNotFound, apiErr.StatusCode)
+	ass.Equal(srv.URL+"/products/99", apiErr.URL)
+	ass.Equal(`{"error":"product not found"}`, apiErr.Body)
+	ass.False(apiErr.Temporary())
+
+	err = c.DownloadFile("Bearer x", "99", 1, 2, t.TempDir(), "a.zip")
+	ass.ErrorIs(err, ErrCanNotDownload)
+	ass.NotErrorIs(err, ErrNo200StatusCode)
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	ass := assert.New(t)
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	ass.Equal(time.Duration(0), parseRetryAfter("", now))
+	ass.Equal(120*time.Second, parseRetryAfter("120", now))
+	ass.Equal(30*time.Second, parseRetryAfter("Mon, 01 Jan 2024 12:00:30 GMT", now))
+	ass.Equal(time.Duration(0), parseRetryAfter("Mon, 01 Jan 2024 11:00:00 GMT", now))
+	ass.Equal(time.Duration(0), parseRetryAfter("soon", now))
+}
@@ -4,12 +4,14 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
 )
 
 func TestApi(t *testing.T) {
+	skipWithoutCredentials(t)
 	ass := assert.New(t)
 
 	t.Log("Getting EPO Token")
@@ -32,7 +34,7 @@ func TestApi(t *testing.T) {
 	}
 
 	res2B, _ := json.Marshal(resFrontFiles)
-	_ = os.WriteFile("big_marhsall.json", res2B, os.ModePerm)
+	_ = os.WriteFile(filepath.Join(t.TempDir(), "big_marhsall.json"), res2B, 0644)
 
 	fmt.Println(string(res2B))
 	ass.NoError(err)
@@ -0,0 +1,102 @@
+package epo_bbds
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"log/slog"
+	"strings"
+)
+
+// ErrChecksumMismatch is returned if a file does not match the checksum of the catalog
+var ErrChecksumMismatch = errors.New("checksum mismatch")
+
+// ChecksumError is returned if a file does not match the checksum of the catalog.
+// It matches ErrChecksumMismatch with errors.Is.
+type ChecksumError struct {
+	FileName string // name of the file
+	Expected string // checksum from the catalog
+	Actual   string // checksum of the local file
+}
+
+// Error returns the error message
+func (e *ChecksumError) Error() string {
+	return fmt.Sprintf("%s: checksum of %s is %s, expected %s", ErrChecksumMismatch, e.FileName, e.Actual, e.Expected)
+}
+
+// Is reports if the target is ErrChecksumMismatch
+func (e *ChecksumError) Is(target error) bool {
+	return target == ErrChecksumMismatch
+}
+
+// newChecksumHash returns the hash that matches the checksum of the catalog.
+// The algorithm is taken from an optional prefix e.g. "sha256:..."
+// or otherwise determined from the length of the hex encoded checksum.
+// If the algorithm is not known, a warning is logged and nil is returned,
+// so that the file is not verified instead of failing.
+func newChecksumHash(checksum string) hash.Hash {
+	algorithm := ""
+	if i := strings.Index(checksum, ":"); i >= 0 {
+		algorithm = strings.ToLower(checksum[:i])
+		checksum = checksum[i+1:]
+	}
+	if algorithm == "" {
+		switch len(checksum) {
+		case 32:
+			algorithm = "md5"
+		case 40:
+			algorithm = "sha1"
+		case 64:
+			algorithm = "sha256"
+		case 128:
+			algorithm = "sha512"
+		}
+	}
+	switch strings.ReplaceAll(algorithm, "-", "") {
+	case "md5":
+		return md5.New()
+	case "sha1":
+		return sha1.New()
+	case "sha256":
+		return sha256.New()
+	case "sha512":
+		return sha512.New()
+	}
+	slog.With("checksum", checksum).Warn("unknown checksum algorithm, skipping verification")
+	return nil
+}
+
+// checksumMatches compares the hash sum with the checksum of the catalog
+func checksumMatches(h hash.Hash, checksum string) (actual string, ok bool) {
+	if i := strings.Index(checksum, ":"); i >= 0 {
+		checksum = checksum[i+1:]
+	}
+	actual = hex.EncodeToString(h.Sum(nil))
+	return actual, strings.EqualFold(actual, checksum)
+}
+
+// VerifyFileChecksum checks if the file matches the checksum of the catalog.
+// It returns a *ChecksumError if the file does not match.
+// If the checksum is empty, the file is not checked.
+func VerifyFileChecksum(filePath, checksum string) (err error) {
+	if checksum == "" {
+		return nil
+	}
+	h := newChecksumHash(checksum)
+	if h == nil {
+		return nil
+	}
+	err = hashFile(filePath, h)
+	if err != nil {
+		return
+	}
+	if actual, ok := checksumMatches(h, checksum); !ok {
+		return &ChecksumError{FileName: filePath, Expected: checksum, Actual: actual}
+	}
+	return nil
+}
@@ -0,0 +1,40 @@
+package epo_bbds
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVerifyFileChecksum(t *testing.T) {
+	ass := assert.New(t)
+	filePath := filepath.Join(t.TempDir(), "a.zip")
+	err := os.WriteFile(filePath, []byte("content"), 0644)
+	ass.NoError(err)
+
+	sha := sha256.Sum256([]byte("content"))
+	md := md5.Sum([]byte("content"))
+	shaHex := hex.EncodeToString(sha[:])
+
+	ass.NoError(VerifyFileChecksum(filePath, ""))
+	ass.NoError(VerifyFileChecksum(filePath, shaHex))
+	ass.NoError(VerifyFileChecksum(filePath, strings.ToUpper(shaHex)))
+	ass.NoError(VerifyFileChecksum(filePath, "SHA-256:"+shaHex))
+	ass.NoError(VerifyFileChecksum(filePath, hex.EncodeToString(md[:])))
+	// unknown algorithms are not verified
+	ass.NoError(VerifyFileChecksum(filePath, "abc"))
+	ass.NoError(VerifyFileChecksum(filePath, "crc32:1234abcd"))
+
+	err = VerifyFileChecksum(filePath, strings.Repeat("0", 64))
+	ass.ErrorIs(err, ErrChecksumMismatch)
+	var checksumErr *ChecksumError
+	ass.True(errors.As(err, &checksumErr))
+	ass.Equal(shaHex, checksumErr.Actual)
+}
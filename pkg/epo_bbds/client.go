@@ -0,0 +1,191 @@
+package epo_bbds
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultBaseURL is the base url of the EPO bulk data service api
+const DefaultBaseURL = "https://publication-bdds.apps.epo.org/bdds/bdds-bff-service/prod/api"
+
+// DefaultRequestTimeout is the timeout of the login and catalog requests
+// of the functions that do not accept a context
+const DefaultRequestTimeout = time.Second * 20
+
+// DefaultDownloadAttempts is the number of attempts to download a file
+// before an interrupted download is given up
+const DefaultDownloadAttempts = 3
+
+// DefaultUserAgent is the user agent that is sent if no other user agent is configured
+const DefaultUserAgent = "go-epo-bdds"
+
+// Client is a client for the EPO bulk data service.
+// Each client has its own endpoints, credentials and http client,
+// so multiple accounts can be used in one process.
+type Client struct {
+	loginEndpoint    string              // e.g. EpoLoginEndpoint
+	productsEndpoint string              // list of all products
+	productEndpoint  string              // format string for a single product
+	fileEndpoint     string              // format string for a file download
+	credentials      CredentialsProvider // credentials of the EPO account
+	oauthClientID    string              // client id of the login
+	httpClient       *http.Client        // http client used for all requests
+	userAgent        string              // user agent header
+	logger           *slog.Logger        // logger
+	tokens           *TokenSource        // cached authorization token
+	downloadAttempts int                 // attempts to resume an interrupted download
+	retryPolicy      RetryPolicy         // retries of failed requests
+}
+
+// ClientOption configures a Client
+type ClientOption func(c *Client)
+
+// NewClient creates a new client for the bulk data service.
+// Without options the client uses the public EPO endpoints,
+// the credentials from the environment and http.DefaultClient.
+func NewClient(opts ...ClientOption) *Client {
+	c := &Client{
+		loginEndpoint:    EpoLoginEndpoint,
+		credentials:      NewEnvCredentials(),
+		oauthClientID:    DefaultOAuthClientID,
+		httpClient:       http.DefaultClient,
+		userAgent:        DefaultUserAgent,
+		logger:           slog.Default(),
+		downloadAttempts: DefaultDownloadAttempts,
+		retryPolicy:      DefaultRetryPolicy,
+	}
+	c.tokens = NewTokenSource(c)
+	c.setBaseURL(DefaultBaseURL)
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// defaultClientOptions are the options of the client used by the package level functions
+var (
+	defaultClientOptionsMu sync.Mutex
+	defaultClientOptions   []ClientOption
+)
+
+// SetDefaultClientOptions sets the options of the client used by the package level functions,
+// e.g. WithHTTPClient with a proxy. The package level endpoint variables take precedence.
+func SetDefaultClientOptions(opts ...ClientOption) {
+	defaultClientOptionsMu.Lock()
+	defer defaultClientOptionsMu.Unlock()
+	defaultClientOptions = append([]ClientOption{}, opts...)
+}
+
+// defaultClient returns the client used by the package level functions.
+// It is built from the package level endpoint variables on every call,
+// so changes to those variables are still respected.
+func defaultClient() *Client {
+	defaultClientOptionsMu.Lock()
+	opts := defaultClientOptions
+	defaultClientOptionsMu.Unlock()
+	c := NewClient(opts...)
+	c.productsEndpoint = EpoProductsEndpoint
+	c.productEndpoint = EpoBddsProductEndpoint
+	c.fileEndpoint = EpoBddsFileEndpoint
+	return c
+}
+
+// WithBaseURL sets the base url of the bulk data service api
+// e.g. https://publication-bdds.apps.epo.org/bdds/bdds-bff-service/prod/api
+func WithBaseURL(baseURL string) ClientOption {
+	return func(c *Client) {
+		c.setBaseURL(baseURL)
+	}
+}
+
+// WithLoginURL sets the url of the login endpoint
+func WithLoginURL(loginURL string) ClientOption {
+	return func(c *Client) {
+		c.loginEndpoint = loginURL
+	}
+}
+
+// WithCredentials sets the username and password of the EPO account
+func WithCredentials(username, password string) ClientOption {
+	return WithCredentialsProvider(NewStaticCredentials(username, password))
+}
+
+// WithCredentialsProvider sets the provider of the credentials of the EPO account,
+// e.g. NewFileCredentials for mounted secrets
+func WithCredentialsProvider(provider CredentialsProvider) ClientOption {
+	return func(c *Client) {
+		if provider != nil {
+			c.credentials = provider
+		}
+	}
+}
+
+// WithOAuthClientID sets the client id that is sent to the login endpoint
+func WithOAuthClientID(clientID string) ClientOption {
+	return func(c *Client) {
+		if clientID != "" {
+			c.oauthClientID = clientID
+		}
+	}
+}
+
+// WithHTTPClient sets the http client that is used for all requests
+func WithHTTPClient(httpClient *http.Client) ClientOption {
+	return func(c *Client) {
+		if httpClient != nil {
+			c.httpClient = httpClient
+		}
+	}
+}
+
+// WithUserAgent sets the user agent header
+func WithUserAgent(userAgent string) ClientOption {
+	return func(c *Client) {
+		c.userAgent = userAgent
+	}
+}
+
+// WithDownloadAttempts sets the number of attempts to download a file,
+// an interrupted download is resumed where it stopped
+func WithDownloadAttempts(attempts int) ClientOption {
+	return func(c *Client) {
+		if attempts > 0 {
+			c.downloadAttempts = attempts
+		}
+	}
+}
+
+// WithLogger sets the logger
+func WithLogger(logger *slog.Logger) ClientOption {
+	return func(c *Client) {
+		if logger != nil {
+			c.logger = logger
+		}
+	}
+}
+
+// setBaseURL derives all api endpoints from the base url
+func (c *Client) setBaseURL(baseURL string) {
+	baseURL = strings.TrimSuffix(baseURL, "/")
+	c.productsEndpoint = baseURL + "/products/"
+	c.productEndpoint = baseURL + "/products/%s"
+	c.fileEndpoint = baseURL + "/products/%s/delivery/%d/file/%d/download"
+}
+
+// newRequest creates a new request with the default headers of the client
+func (c *Client) newRequest(ctx context.Context, method, url string, body io.Reader) (req *http.Request, err error) {
+	req, err = http.NewRequestWithContext(ctx, method, url, body)
+	if err != nil {
+		c.logger.With("err", err).Error("failed to create new request")
+		return
+	}
+	if c.userAgent != "" {
+		req.Header.Set("User-Agent", c.userAgent)
+	}
+	return
+}
@@ -0,0 +1,72 @@
+package epo_bbds
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/max-planck-innovation-competition/go-epo-bdds/pkg/epo_bbdstest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClient(t *testing.T) {
+	ass := assert.New(t)
+	srv, alice := newTestClient(t, []epo_bbdstest.Product{{
+		ID:   3,
+		Name: "DOCDB",
+		Deliveries: []epo_bbdstest.Delivery{{
+			ID:    10,
+			Name:  "DOCDB 202402",
+			Files: []epo_bbdstest.File{epo_bbdstest.NewFile(20, "a.zip", []byte("content"))},
+		}},
+	}}, WithUserAgent("test-agent"))
+	bob := NewClient(
+		WithBaseURL(srv.BaseURL()+"/"),
+		WithLoginURL(srv.LoginURL()),
+		WithCredentials("bob", "wrong"),
+	)
+
+	token, err := alice.GetAuthorizationToken()
+	ass.NoError(err)
+	ass.Equal("Bearer fake-token-1", token)
+
+	_, err = bob.GetAuthorizationToken()
+	ass.ErrorIs(err, ErrNo200StatusCode)
+
+	products, err := alice.GetProducts(token)
+	ass.NoError(err)
+	ass.Equal([]EpoProductItem{{ID: 3, Name: "DOCDB"}}, products)
+
+	deliveries, err := alice.GetEpoBddsFileItems(token, EpoDocDBFrontFilesProductID)
+	ass.NoError(err)
+	ass.Len(deliveries.Deliveries, 1)
+
+	dir := t.TempDir()
+	err = alice.DownloadFile(token, EpoDocDBFrontFilesProductID, 10, 20, dir, "a.zip")
+	ass.NoError(err)
+	data, err := os.ReadFile(filepath.Join(dir, "a.zip"))
+	ass.NoError(err)
+	ass.Equal("content", string(data))
+}
+
+func TestClientHeaders(t *testing.T) {
+	ass := assert.New(t)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-User-Agent", r.UserAgent())
+		w.Header().Set("X-Token", r.Header.Get(AuthHeader))
+	}))
+	defer srv.Close()
+
+	c := NewClient(WithBaseURL(srv.URL+"/api"), WithUserAgent("test-agent"))
+	req, err := c.newRequest(context.Background(), "GET", c.productsEndpoint, nil)
+	ass.NoError(err)
+	req.Header.Set(AuthHeader, "Bearer x")
+	resp, err := c.httpClient.Do(req)
+	ass.NoError(err)
+	_ = resp.Body.Close()
+	ass.Equal("test-agent", resp.Header.Get("X-User-Agent"))
+	ass.Equal("Bearer x", resp.Header.Get("X-Token"))
+}
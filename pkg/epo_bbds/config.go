@@ -14,6 +14,7 @@ var EpoBddsFileEndpoint = "https://publication-bdds.apps.epo.org/bdds/bdds-bff-s
 var EpoBddsProductEndpoint = "https://publication-bdds.apps.epo.org/bdds/bdds-bff-service/prod/api/products/%s"
 
 // EpoBddsBProductID is the product id for epo bulk datasets
+// products without a constant can be resolved by name with a ProductCatalog
 type EpoBddsBProductID string
 
 // EpoFullTextFrontFilesProductID is the EP full-text data - front file
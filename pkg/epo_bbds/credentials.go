@@ -0,0 +1,169 @@
+package epo_bbds
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"runtime"
+	"strings"
+)
+
+// ErrNoCredentials is returned if a credentials provider has no credentials
+var ErrNoCredentials = errors.New("no epo credentials")
+
+// ErrInsecureCredentialsFile is returned if a credentials file can be read or written by other users
+var ErrInsecureCredentialsFile = errors.New("insecure permissions of credentials file")
+
+// DefaultNetrcMachine is the machine of the EPO account in a netrc file
+const DefaultNetrcMachine = "login.epo.org"
+
+// Credentials are the username and password of an EPO account
+type Credentials struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+// CredentialsProvider provides the credentials of the EPO account.
+// The credentials are requested before every login,
+// so rotated secrets are used without restarting the application.
+type CredentialsProvider interface {
+	Credentials(ctx context.Context) (Credentials, error)
+}
+
+// StaticCredentials provides fixed credentials, e.g. for tests
+type StaticCredentials Credentials
+
+// NewStaticCredentials creates a provider with fixed credentials
+func NewStaticCredentials(username, password string) StaticCredentials {
+	return StaticCredentials{Username: username, Password: password}
+}
+
+// Credentials returns the fixed credentials
+func (s StaticCredentials) Credentials(ctx context.Context) (Credentials, error) {
+	return Credentials(s), nil
+}
+
+// EnvCredentials provides the credentials from environment variables
+type EnvCredentials struct {
+	UsernameVar string // e.g. EPO_USERNAME
+	PasswordVar string // e.g. EPO_PASSWORD
+}
+
+// NewEnvCredentials creates a provider that reads EPO_USERNAME and EPO_PASSWORD
+func NewEnvCredentials() EnvCredentials {
+	return EnvCredentials{UsernameVar: "EPO_USERNAME", PasswordVar: "EPO_PASSWORD"}
+}
+
+// Credentials returns the credentials from the environment variables
+func (e EnvCredentials) Credentials(ctx context.Context) (Credentials, error) {
+	return Credentials{
+		Username: os.Getenv(e.UsernameVar),
+		Password: os.Getenv(e.PasswordVar),
+	}, nil
+}
+
+// FileCredentials provides the credentials from a file, e.g. a mounted secret.
+// The file is either a JSON object with the fields "username" and "password"
+// or a netrc file with the entry of the machine or a default entry, e.g.
+//
+//	machine login.epo.org login hello@world.com password secret
+//
+// The file is read again for every login.
+// On unix the file must not be writable by the group or accessible by other users,
+// e.g. mode 0600, 0400 or 0440.
+type FileCredentials struct {
+	Path                     string
+	Machine                  string // machine of the netrc entry, DefaultNetrcMachine if empty
+	AllowInsecurePermissions bool   // skip the permission check
+}
+
+// NewFileCredentials creates a provider that reads the credentials from the file
+func NewFileCredentials(path string) *FileCredentials {
+	return &FileCredentials{Path: path, Machine: DefaultNetrcMachine}
+}
+
+// Credentials reads the credentials from the file
+func (f *FileCredentials) Credentials(ctx context.Context) (credentials Credentials, err error) {
+	info, err := os.Stat(f.Path)
+	if err != nil {
+		return
+	}
+	if !f.AllowInsecurePermissions && runtime.GOOS != "windows" && info.Mode().Perm()&0o027 != 0 {
+		err = fmt.Errorf("%w: %s has mode %s", ErrInsecureCredentialsFile, f.Path, info.Mode().Perm())
+		return
+	}
+	content, err := os.ReadFile(f.Path)
+	if err != nil {
+		return
+	}
+	if strings.HasPrefix(strings.TrimSpace(string(content)), "{") {
+		err = json.Unmarshal(content, &credentials)
+		if err != nil {
+			err = fmt.Errorf("failed to parse credentials file %s: %w", f.Path, err)
+		}
+		return
+	}
+	machine := f.Machine
+	if machine == "" {
+		machine = DefaultNetrcMachine
+	}
+	credentials, ok := parseNetrc(string(content), machine)
+	if !ok {
+		err = fmt.Errorf("%w: no entry for %s in %s", ErrNoCredentials, machine, f.Path)
+	}
+	return
+}
+
+// parseNetrc returns the login and password of the machine
+// or of the default entry if there is no entry for the machine
+func parseNetrc(content, machine string) (credentials Credentials, ok bool) {
+	var current, fallback *Credentials
+	var found bool
+	scanner := bufio.NewScanner(strings.NewReader(content))
+	scanner.Split(bufio.ScanWords)
+	for scanner.Scan() {
+		switch scanner.Text() {
+		case "machine":
+			if !scanner.Scan() {
+				break
+			}
+			current = nil
+			if !found && scanner.Text() == machine {
+				current = &credentials
+				found = true
+			}
+		case "default":
+			current = nil
+			if fallback == nil {
+				fallback = &Credentials{}
+				current = fallback
+			}
+		case "login":
+			if scanner.Scan() && current != nil {
+				current.Username = scanner.Text()
+			}
+		case "password":
+			if scanner.Scan() && current != nil {
+				current.Password = scanner.Text()
+			}
+		case "account":
+			// not used
+			scanner.Scan()
+		case "macdef":
+			// macros are not supported, the rest of the file is ignored
+			current = nil
+			for scanner.Scan() {
+			}
+		}
+	}
+	if found {
+		return credentials, true
+	}
+	if fallback != nil {
+		return *fallback, true
+	}
+	return credentials, false
+}
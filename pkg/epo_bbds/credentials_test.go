@@ -0,0 +1,140 @@
+package epo_bbds
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/max-planck-innovation-competition/go-epo-bdds/pkg/epo_bbdstest"
+	"github.com/stretchr/testify/assert"
+)
+
+func writeCredentialsFile(t *testing.T, content string, mode os.FileMode) string {
+	path := filepath.Join(t.TempDir(), "credentials")
+	err := os.WriteFile(path, []byte(content), mode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the mode of WriteFile is masked by the umask
+	err = os.Chmod(path, mode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestStaticAndEnvCredentials(t *testing.T) {
+	ass := assert.New(t)
+	ctx := context.Background()
+
+	credentials, err := NewStaticCredentials("user", "secret").Credentials(ctx)
+	ass.NoError(err)
+	ass.Equal(Credentials{Username: "user", Password: "secret"}, credentials)
+
+	t.Setenv("EPO_USERNAME", "env-user")
+	t.Setenv("EPO_PASSWORD", "env-secret")
+	credentials, err = NewEnvCredentials().Credentials(ctx)
+	ass.NoError(err)
+	ass.Equal(Credentials{Username: "env-user", Password: "env-secret"}, credentials)
+}
+
+func TestFileCredentials(t *testing.T) {
+	ass := assert.New(t)
+	ctx := context.Background()
+
+	// json
+	path := writeCredentialsFile(t, `{"username": "json-user", "password": "p&ss word"}`, 0o600)
+	credentials, err := NewFileCredentials(path).Credentials(ctx)
+	ass.NoError(err)
+	ass.Equal(Credentials{Username: "json-user", Password: "p&ss word"}, credentials)
+
+	// netrc
+	netrc := `machine example.com login other password other-secret
+machine login.epo.org
+	login netrc-user
+	password netrc-secret
+default login default-user password default-secret
+`
+	path = writeCredentialsFile(t, netrc, 0o400)
+	credentials, err = NewFileCredentials(path).Credentials(ctx)
+	ass.NoError(err)
+	ass.Equal(Credentials{Username: "netrc-user", Password: "netrc-secret"}, credentials)
+
+	provider := NewFileCredentials(path)
+	provider.Machine = "unknown.org"
+	credentials, err = provider.Credentials(ctx)
+	ass.NoError(err)
+	ass.Equal(Credentials{Username: "default-user", Password: "default-secret"}, credentials)
+
+	path = writeCredentialsFile(t, "machine example.com login other password other-secret\n", 0o600)
+	_, err = NewFileCredentials(path).Credentials(ctx)
+	ass.ErrorIs(err, ErrNoCredentials)
+
+	// invalid json
+	path = writeCredentialsFile(t, `{"username": `, 0o600)
+	_, err = NewFileCredentials(path).Credentials(ctx)
+	ass.Error(err)
+
+	_, err = NewFileCredentials(filepath.Join(t.TempDir(), "missing")).Credentials(ctx)
+	ass.ErrorIs(err, os.ErrNotExist)
+}
+
+func TestFileCredentialsPermissions(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the permissions are not checked on windows")
+	}
+	ass := assert.New(t)
+	ctx := context.Background()
+
+	for _, mode := range []os.FileMode{0o644, 0o604, 0o660, 0o666} {
+		path := writeCredentialsFile(t, `{"username": "user", "password": "secret"}`, mode)
+		_, err := NewFileCredentials(path).Credentials(ctx)
+		ass.ErrorIs(err, ErrInsecureCredentialsFile, mode.String())
+
+		provider := NewFileCredentials(path)
+		provider.AllowInsecurePermissions = true
+		_, err = provider.Credentials(ctx)
+		ass.NoError(err, mode.String())
+	}
+	path := writeCredentialsFile(t, `{"username": "user", "password": "secret"}`, 0o440)
+	_, err := NewFileCredentials(path).Credentials(ctx)
+	ass.NoError(err)
+}
+
+func TestLoginWithCredentialsProvider(t *testing.T) {
+	ass := assert.New(t)
+	srv := epo_bbdstest.NewServer()
+	defer srv.Close()
+	// the password must be url-encoded
+	srv.Password = "p&ss+word=%20"
+	srv.ClientID = DefaultOAuthClientID
+
+	path := writeCredentialsFile(t, `{"username": "user@example.com", "password": "p&ss+word=%20"}`, 0o600)
+	c := NewClient(
+		WithLoginURL(srv.LoginURL()),
+		WithCredentialsProvider(NewFileCredentials(path)),
+	)
+	token, err := c.GetAuthorizationTokenContext(context.Background())
+	ass.NoError(err)
+	ass.NotEmpty(token)
+
+	// another client id
+	srv.ClientID = "custom-client"
+	_, err = c.GetAuthorizationTokenContext(context.Background())
+	ass.ErrorIs(err, ErrNo200StatusCode)
+	c = NewClient(
+		WithLoginURL(srv.LoginURL()),
+		WithCredentialsProvider(NewFileCredentials(path)),
+		WithOAuthClientID("custom-client"),
+	)
+	_, err = c.GetAuthorizationTokenContext(context.Background())
+	ass.NoError(err)
+
+	// missing credentials
+	c = NewClient(WithLoginURL(srv.LoginURL()), WithCredentials("user@example.com", ""))
+	_, err = c.GetAuthorizationTokenContext(context.Background())
+	ass.ErrorIs(err, ErrNoCredentials)
+	ass.Equal(3, srv.Requests(epo_bbdstest.EndpointLogin))
+}
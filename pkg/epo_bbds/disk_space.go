@@ -0,0 +1,79 @@
+package epo_bbds
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// ErrInsufficientDiskSpace is returned if the planned downloads do not fit on the destination filesystem
+var ErrInsufficientDiskSpace = errors.New("insufficient disk space")
+
+// DiskSpaceError is returned if the planned downloads do not fit on the destination filesystem.
+// It matches ErrInsufficientDiskSpace with errors.Is.
+type DiskSpaceError struct {
+	Path      string // destination path
+	Required  int64  // bytes of the planned downloads
+	Available int64  // free bytes on the filesystem
+}
+
+// Error returns the error message
+func (e *DiskSpaceError) Error() string {
+	return fmt.Sprintf("%s: %s required in %s, %s available",
+		ErrInsufficientDiskSpace, FormatFileSize(e.Required), e.Path, FormatFileSize(e.Available))
+}
+
+// Is reports if the target is ErrInsufficientDiskSpace
+func (e *DiskSpaceError) Is(target error) bool {
+	return target == ErrInsufficientDiskSpace
+}
+
+// DiskSpaceCheck sets what happens if the planned downloads of a sync do not fit on the destination filesystem
+type DiskSpaceCheck int
+
+const (
+	// DiskSpaceRefuse refuses to start the sync
+	DiskSpaceRefuse DiskSpaceCheck = iota
+	// DiskSpaceWarn logs a warning and starts the sync
+	DiskSpaceWarn
+	// DiskSpaceIgnore does not check the disk space
+	DiskSpaceIgnore
+)
+
+// FreeDiskSpace returns the bytes available to the user on the filesystem of the path.
+// If the path does not exist yet, its closest existing parent is used.
+func FreeDiskSpace(path string) (free int64, err error) {
+	path, err = filepath.Abs(path)
+	if err != nil {
+		return
+	}
+	for !pathExists(path) {
+		parent := filepath.Dir(path)
+		if parent == path {
+			break
+		}
+		path = parent
+	}
+	return freeDiskSpace(path)
+}
+
+// PlannedDownloadSize returns the bytes that the jobs still have to download.
+// Complete files are not counted and partial downloads only with their remaining bytes.
+// Existing files that do not match the checksum are downloaded again and counted with their full size,
+// so the files are hashed like by the DownloadManager.
+func PlannedDownloadSize(jobs []DownloadJob) (size int64) {
+	for _, job := range jobs {
+		if pathExists(job.FilePath()) && job.verifyFile() == nil {
+			continue
+		}
+		remaining := job.File.SizeBytes()
+		if info, err := os.Stat(job.FilePath() + PartFileSuffix); err == nil {
+			remaining -= info.Size()
+		}
+		if remaining > 0 {
+			size += remaining
+		}
+	}
+	return
+}
@@ -0,0 +1,10 @@
+//go:build !linux && !darwin && !freebsd && !windows
+
+package epo_bbds
+
+import "errors"
+
+// freeDiskSpace is not supported on this platform
+func freeDiskSpace(path string) (free int64, err error) {
+	return 0, errors.New("free disk space is not supported on this platform")
+}
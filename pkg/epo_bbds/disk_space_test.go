@@ -0,0 +1,59 @@
+package epo_bbds
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFreeDiskSpace(t *testing.T) {
+	ass := assert.New(t)
+	// a path that does not exist yet uses its parent
+	free, err := FreeDiskSpace(filepath.Join(t.TempDir(), "not", "yet"))
+	ass.NoError(err)
+	ass.Greater(free, int64(0))
+}
+
+func TestPlannedDownloadSize(t *testing.T) {
+	ass := assert.New(t)
+	dir := t.TempDir()
+	jobs := []DownloadJob{
+		{File: EpoDocDbFileItem{FileName: "complete.zip", FileSize: "1 KB"}, DestinationPath: dir},
+		{File: EpoDocDbFileItem{FileName: "partial.zip", FileSize: "1 KB"}, DestinationPath: dir},
+		{File: EpoDocDbFileItem{FileName: "missing.zip", FileSize: "2 KB"}, DestinationPath: dir},
+		// the file does not match the checksum and is downloaded again
+		{File: EpoDocDbFileItem{FileName: "corrupt.zip", FileSize: "4 KB", FileChecksum: strings.Repeat("0", 64)}, DestinationPath: dir},
+	}
+	ass.NoError(os.WriteFile(filepath.Join(dir, "complete.zip"), []byte("x"), 0644))
+	ass.NoError(os.WriteFile(filepath.Join(dir, "partial.zip"+PartFileSuffix), make([]byte, 24), 0644))
+	ass.NoError(os.WriteFile(filepath.Join(dir, "corrupt.zip"), make([]byte, 4096), 0644))
+	ass.Equal(int64(1000+2048+4096), PlannedDownloadSize(jobs))
+}
+
+func TestProductSyncDiskSpace(t *testing.T) {
+	ass := assert.New(t)
+	catalog := testCatalog
+	catalog.Deliveries = []EpoProductDelivery{testCatalog.Deliveries[1]}
+	catalog.Deliveries[0].Files = []EpoDocDbFileItem{
+		{FileID: 21, FileName: "data_PATSTAT_Global_2024_Spring_01.zip", FileSize: "1000000 TB"},
+	}
+	_, c := newCatalogTestServer(t, catalog)
+	dir := t.TempDir()
+
+	_, err := c.NewProductSync(EpoPatstatGlobalProductID, dir).Run(context.Background())
+	ass.ErrorIs(err, ErrInsufficientDiskSpace)
+	var diskSpaceErr *DiskSpaceError
+	ass.True(errors.As(err, &diskSpaceErr))
+	ass.Equal(int64(1000000)<<40, diskSpaceErr.Required)
+	ass.False(pathExists(filepath.Join(dir, "data_PATSTAT_Global_2024_Spring_01.zip")))
+
+	// a warning does not stop the sync
+	result, err := c.NewProductSync(EpoPatstatGlobalProductID, dir).SetDiskSpaceCheck(DiskSpaceWarn).Run(context.Background())
+	ass.NoError(err)
+	ass.Len(result.Downloaded(), 1)
+}
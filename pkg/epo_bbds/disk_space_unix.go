@@ -0,0 +1,15 @@
+//go:build linux || darwin || freebsd
+
+package epo_bbds
+
+import "syscall"
+
+// freeDiskSpace returns the bytes available to the user on the filesystem of the path
+func freeDiskSpace(path string) (free int64, err error) {
+	var stat syscall.Statfs_t
+	err = syscall.Statfs(path, &stat)
+	if err != nil {
+		return
+	}
+	return int64(stat.Bavail) * int64(stat.Bsize), nil
+}
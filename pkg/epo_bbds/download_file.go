@@ -4,138 +4,376 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"hash"
 	"io"
-	"log/slog"
 	"net/http"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
-	"time"
 )
 
 // ErrCanNotDownload is thrown if the download is not possible
 var ErrCanNotDownload = errors.New("can not download file")
 
+// PartFileSuffix is appended to the file name of a download in progress
+const PartFileSuffix = ".part"
+
 // DownloadFile downloads a file from the bulk data service
-func DownloadFile(token string, productID EpoBddsBProductID, deliveryID, fileID int, destinationFilePath, destinationFileName string) (err error) {
+func DownloadFile(token string, productID EpoBddsBProductID, deliveryID, fileID int, destinationFilePath, destinationFileName string, options ...DownloadOption) (err error) {
+	return defaultClient().DownloadFile(token, productID, deliveryID, fileID, destinationFilePath, destinationFileName, options...)
+}
+
+// DownloadFileContext downloads a file from the bulk data service
+// the download is aborted if the context is cancelled
+func DownloadFileContext(ctx context.Context, token string, productID EpoBddsBProductID, deliveryID, fileID int, destinationFilePath, destinationFileName string, options ...DownloadOption) (err error) {
+	return defaultClient().DownloadFileContext(ctx, token, productID, deliveryID, fileID, destinationFilePath, destinationFileName, options...)
+}
+
+// DownloadFileItemContext downloads a file of the catalog to the destination path
+// and verifies it against the checksum of the catalog
+func DownloadFileItemContext(ctx context.Context, token string, productID EpoBddsBProductID, deliveryID int, file EpoDocDbFileItem, destinationFilePath string, options ...DownloadOption) (err error) {
+	return defaultClient().DownloadFileItemContext(ctx, token, productID, deliveryID, file, destinationFilePath, options...)
+}
+
+// DownloadFile downloads a file from the bulk data service
+// if the token is empty, the token source of the client is used
+func (c *Client) DownloadFile(token string, productID EpoBddsBProductID, deliveryID, fileID int, destinationFilePath, destinationFileName string, options ...DownloadOption) (err error) {
+	return c.DownloadFileContext(context.Background(), token, productID, deliveryID, fileID, destinationFilePath, destinationFileName, options...)
+}
+
+// DownloadFileContext downloads a file from the bulk data service
+// if the token is empty, the token source of the client is used
+// the download is aborted if the context is cancelled.
+// The file is written to a part file next to the destination, which is renamed once complete.
+// An interrupted download is resumed with a range request, in the same call
+// or in a later call that finds the part file. A complete part file is not downloaded again.
+func (c *Client) DownloadFileContext(ctx context.Context, token string, productID EpoBddsBProductID, deliveryID, fileID int, destinationFilePath, destinationFileName string, options ...DownloadOption) (err error) {
+	// build endpoint url
+	endpoint := fmt.Sprintf(c.fileEndpoint, string(productID), deliveryID, fileID)
+	opts := newDownloadOptions(EpoDocDbFileItem{FileID: fileID, FileName: destinationFileName}, options)
+	err = c.downloadFile(ctx, token, endpoint, destinationFilePath, destinationFileName, opts)
+	opts.progress.finish(err == nil)
+	return
+}
+
+// DownloadFileItemContext downloads a file of the catalog to the destination path.
+// The file is hashed while it is downloaded and compared with the checksum of the catalog,
+// a *ChecksumError is returned if they do not match.
+func (c *Client) DownloadFileItemContext(ctx context.Context, token string, productID EpoBddsBProductID, deliveryID int, file EpoDocDbFileItem, destinationFilePath string, options ...DownloadOption) (err error) {
 	// build endpoint url
-	endpoint := fmt.Sprintf(EpoBddsFileEndpoint, string(productID), deliveryID, fileID)
+	endpoint := fmt.Sprintf(c.fileEndpoint, string(productID), deliveryID, file.FileID)
+	opts := newDownloadOptions(file, options)
+	opts.checksum = file.FileChecksum
+	err = c.downloadFile(ctx, token, endpoint, destinationFilePath, file.FileName, opts)
+	opts.progress.finish(err == nil)
+	return
+}
+
+// DownloadOption is an option of a single file download
+type DownloadOption func(opts *downloadOptions)
+
+// WithDownloadProgress sends the progress of the download to fn,
+// at most one event per DefaultProgressInterval and one event when the download is done
+func WithDownloadProgress(fn ProgressFunc) DownloadOption {
+	return func(opts *downloadOptions) {
+		opts.progressFn = fn
+	}
+}
+
+// downloadOptions are the optional settings of a single download
+type downloadOptions struct {
+	checksum         string        // checksum of the catalog, not verified if empty
+	requestLimiter   *RateLimiter  // optional limit of the requests per second
+	bandwidthLimiter *RateLimiter  // optional limit of the bytes per second
+	progress         *fileProgress // optional progress of the download
+	progressFn       ProgressFunc  // optional receiver of the progress of a single download
+}
+
+// newDownloadOptions applies the options of a single download of the file
+func newDownloadOptions(file EpoDocDbFileItem, options []DownloadOption) (opts downloadOptions) {
+	for _, option := range options {
+		option(&opts)
+	}
+	if opts.progressFn != nil {
+		job := DownloadJob{File: file}
+		opts.progress = newRunProgress(opts.progressFn, DefaultProgressInterval, []DownloadJob{job}).file(job)
+	}
+	return
+}
+
+// downloadFile downloads the endpoint to the destination
+// and verifies the checksum if it is not empty
+func (c *Client) downloadFile(ctx context.Context, token, endpoint, destinationFilePath, destinationFileName string, opts downloadOptions) (err error) {
+	checksum := opts.checksum
 	// create path if not exists
 	err = os.MkdirAll(destinationFilePath, os.ModePerm)
 	if err != nil {
-		slog.With("err", err).Error("failed to create file path")
+		c.logger.With("err", err).Error("failed to create file path")
 		return
 	}
 	// join file and filepath
 	path := filepath.Join(destinationFilePath, destinationFileName)
+	// the file is downloaded to a part file first,
+	// which is renamed once the download is complete
+	partPath := path + PartFileSuffix
+	logger := c.logger.With("file", path)
+
+	// hash the file while it is downloaded
+	var h hash.Hash
+	if checksum != "" {
+		h = newChecksumHash(checksum)
+	}
 
-	// create file
-	out, err := os.Create(path)
+	for attempt := 1; ; attempt++ {
+		var retry bool
+		retry, err = c.downloadPart(ctx, token, endpoint, partPath, h, opts)
+		if err == nil {
+			break
+		}
+		// keep the part file to resume later
+		if !retry || ctx.Err() != nil || attempt >= c.downloadAttempts {
+			logger.With("err", err, "attempt", attempt).Error("failed to download file")
+			return
+		}
+		logger.With("err", err, "attempt", attempt).Warn("download interrupted, resume download")
+	}
+
+	// verify the checksum
+	if h != nil {
+		if actual, ok := checksumMatches(h, checksum); !ok {
+			err = &ChecksumError{FileName: destinationFileName, Expected: checksum, Actual: actual}
+			logger.With("err", err).Error("downloaded file does not match checksum")
+			// the part file is corrupt and can not be resumed
+			if errRemove := os.Remove(partPath); errRemove != nil {
+				logger.With("err", errRemove).Error("failed to remove part file")
+			}
+			return
+		}
+	}
+
+	// move the complete file to its final destination
+	err = os.Rename(partPath, path)
+	if err != nil {
+		logger.With("err", err).Error("failed to rename part file")
+		return
+	}
+	return
+}
+
+// downloadPart downloads the file to the part file.
+// If the part file already exists, the download is resumed with a range request.
+// If h is not nil, the whole content of the part file is written to h.
+// retry reports if the error is temporary and the download can be resumed.
+func (c *Client) downloadPart(ctx context.Context, token, endpoint, partPath string, h hash.Hash, opts downloadOptions) (retry bool, err error) {
+	// check how much was already downloaded
+	var offset int64
+	if info, errStat := os.Stat(partPath); errStat == nil {
+		offset = info.Size()
+	}
+	logger := c.logger.With("file", partPath, "offset", offset)
+
+	// wait for the rate limit
+	err = opts.requestLimiter.Wait(ctx)
 	if err != nil {
-		slog.With("err", err).Error("failed to create file")
 		return
 	}
-	defer func(out *os.File) {
-		err := out.Close()
-		if err != nil {
-			slog.With("err", err).Error("failed to close file")
-		}
-	}(out)
 	// download file
-	req, err := http.NewRequestWithContext(context.TODO(), "GET", endpoint, strings.NewReader(""))
+	req, err := c.newRequest(ctx, "GET", endpoint, strings.NewReader(""))
 	if err != nil {
-		slog.With("err", err).Error("failed to create new request")
 		return
 	}
-	// add header
-	req.Header.Set(AuthHeader, token)
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	}
 	// send request
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := c.do(req, token)
 	if err != nil {
-		slog.With("err", err).Error("failed to send request")
-		return
+		logger.With("err", err).Error("failed to send request")
+		return true, err
 	}
 	defer func(Body io.ReadCloser) {
 		err := Body.Close()
 		if err != nil {
-			slog.With("err", err).Error("failed to close body")
+			logger.With("err", err).Error("failed to close body")
 		}
 	}(resp.Body)
 
 	// check status code
-	if resp.StatusCode != http.StatusOK {
-		slog.With("status", resp.Status).Error("failed to download file")
-		err = ErrCanNotDownload
-		slog.With("err", err).Error("failed to download file")
+	flags := os.O_CREATE | os.O_WRONLY
+	switch {
+	case resp.StatusCode == http.StatusPartialContent && offset > 0 && contentRangeStart(resp.Header.Get("Content-Range")) == offset:
+		// resume the download
+		logger.Info("resume download")
+		flags |= os.O_APPEND
+	case resp.StatusCode == http.StatusOK ||
+		resp.StatusCode == http.StatusPartialContent && contentRangeStart(resp.Header.Get("Content-Range")) == 0:
+		// the server ignores the range, restart from zero
+		if offset > 0 {
+			logger.Info("server does not support ranges, restart download")
+		}
+		flags |= os.O_TRUNC
+	case resp.StatusCode == http.StatusPartialContent:
+		// the range of the response does not match the part file, restart from zero
+		logger.With("contentRange", resp.Header.Get("Content-Range")).Warn("unexpected content range, restart download")
+		err = os.Remove(partPath)
+		if err != nil && !os.IsNotExist(err) {
+			logger.With("err", err).Error("failed to remove part file")
+			return
+		}
+		return true, ErrCanNotDownload
+	case resp.StatusCode == http.StatusRequestedRangeNotSatisfiable && offset > 0 && contentRangeSize(resp.Header.Get("Content-Range")) == offset:
+		// the part file is complete, e.g. the previous run stopped before the rename,
+		// and is verified by the caller
+		logger.Info("part file is complete")
+		opts.progress.begin(offset, 0)
+		if h != nil {
+			h.Reset()
+			err = hashFile(partPath, h)
+			if err != nil {
+				logger.With("err", err).Error("failed to hash part file")
+			}
+		}
+		return
+	case resp.StatusCode == http.StatusRequestedRangeNotSatisfiable:
+		// the part file does not match the file on the server, restart from zero
+		logger.Warn("range not satisfiable, restart download")
+		err = os.Remove(partPath)
+		if err != nil {
+			logger.With("err", err).Error("failed to remove part file")
+			return
+		}
+		return true, ErrCanNotDownload
+	default:
+		err = newAPIError(resp, ErrCanNotDownload)
+		logger.With("err", err, "status", resp.Status).Error("failed to download file")
+		// temporary errors were already retried when the request was sent
 		return
 	}
 
+	if flags&os.O_APPEND != 0 {
+		opts.progress.begin(offset, resp.ContentLength)
+	} else {
+		opts.progress.begin(0, resp.ContentLength)
+	}
+
+	// open the part file
+	out, err := os.OpenFile(partPath, flags, 0644)
+	if err != nil {
+		logger.With("err", err).Error("failed to create file")
+		return
+	}
 	// copy file
-	_, err = io.Copy(out, resp.Body)
+	var w io.Writer = out
+	if h != nil {
+		h.Reset()
+		if flags&os.O_APPEND != 0 {
+			// hash the part that was already downloaded
+			err = hashFile(partPath, h)
+			if err != nil {
+				logger.With("err", err).Error("failed to hash part file")
+				_ = out.Close()
+				return
+			}
+		}
+		w = io.MultiWriter(out, h)
+	}
+	if opts.progress != nil {
+		w = io.MultiWriter(w, opts.progress)
+	}
+	var body io.Reader = resp.Body
+	body = newRateLimitedReader(ctx, body, opts.bandwidthLimiter)
+	_, err = io.Copy(w, body)
+	errClose := out.Close()
+	if err != nil {
+		logger.With("err", err).Error("failed to copy file")
+		return true, err
+	}
+	if errClose != nil {
+		logger.With("err", errClose).Error("failed to close file")
+		return false, errClose
+	}
+	return
+}
+
+// hashFile writes the content of the file to the hash
+func hashFile(filePath string, h hash.Hash) (err error) {
+	f, err := os.Open(filePath)
 	if err != nil {
-		slog.With("err", err).Error("failed to copy file")
 		return
 	}
+	defer func(f *os.File) {
+		_ = f.Close()
+	}(f)
+	_, err = io.Copy(h, f)
 	return
 }
 
+// contentRangeStart returns the first byte of a content range header
+// e.g. "bytes 100-199/200" returns 100 and -1 if the header can not be parsed
+func contentRangeStart(contentRange string) int64 {
+	var start, end, size int64
+	_, err := fmt.Sscanf(contentRange, "bytes %d-%d/%d", &start, &end, &size)
+	if err != nil {
+		// the size can also be unknown e.g. "bytes 100-199/*"
+		_, err = fmt.Sscanf(contentRange, "bytes %d-%d/*", &start, &end)
+		if err != nil {
+			return -1
+		}
+	}
+	return start
+}
+
+// contentRangeSize returns the size of the file of a content range header
+// e.g. "bytes */200" of a response with the status 416 returns 200 and -1 if the size is unknown
+func contentRangeSize(contentRange string) int64 {
+	i := strings.LastIndex(contentRange, "/")
+	if !strings.HasPrefix(contentRange, "bytes ") || i < 0 {
+		return -1
+	}
+	size, err := strconv.ParseInt(contentRange[i+1:], 10, 64)
+	if err != nil {
+		return -1
+	}
+	return size
+}
+
 // DownloadAllFiles downloads all files from the bulk data service of a product
 // checks if the file already exists
 // downloads the new files
 // returns a list of new files
 func DownloadAllFiles(productID EpoBddsBProductID, destinationPath string) (newFiles []string, err error) {
-	// get token
-	token, errToken := GetAuthorizationToken()
-	if errToken != nil {
-		err = errToken
-		slog.With("err", err).Error("can not get the auth token")
-		return
-	}
+	return defaultClient().DownloadAllFiles(productID, destinationPath)
+}
+
+// DownloadAllFilesContext downloads all files from the bulk data service of a product
+// and stops after the current file if the context is cancelled
+func DownloadAllFilesContext(ctx context.Context, productID EpoBddsBProductID, destinationPath string) (newFiles []string, err error) {
+	return defaultClient().DownloadAllFilesContext(ctx, productID, destinationPath)
+}
 
-	// get back files
-	deliveries, err := GetEpoBddsFileItems(token, productID)
+// DownloadAllFiles downloads all files from the bulk data service of a product
+// checks if the file already exists and matches the checksum of the catalog
+// downloads the new files in parallel with a DownloadManager
+// returns a list of new files
+// a failed download does not stop the other downloads
+// the token is taken from the token source of the client
+// and only refreshed when it is about to expire
+func (c *Client) DownloadAllFiles(productID EpoBddsBProductID, destinationPath string) (newFiles []string, err error) {
+	return c.DownloadAllFilesContext(context.Background(), productID, destinationPath)
+}
+
+// DownloadAllFilesContext downloads all files from the bulk data service of a product
+// and stops if the context is cancelled
+func (c *Client) DownloadAllFilesContext(ctx context.Context, productID EpoBddsBProductID, destinationPath string) (newFiles []string, err error) {
+	result, err := c.NewProductSync(productID, destinationPath).Run(ctx)
+	for _, res := range result.Downloaded() {
+		newFiles = append(newFiles, res.Job.File.FileName)
+	}
 	if err != nil {
-		slog.With("err", err).Error("could not get files")
 		return
 	}
-	for i, d := range deliveries.Deliveries {
-		slog.
-			With("deliveryName", d.DeliveryName, "no", i+1, "total", len(deliveries.Deliveries)).
-			Info("process delivery")
-		amountFiles := len(d.Files)
-		for j, f := range d.Files {
-			// check if file exists
-			if pathExists(filepath.Join(destinationPath, f.FileName)) {
-				slog.With("file", f.FileName, "no", j, "total", amountFiles).Info("file exists already")
-				continue
-			}
-			// add file to list
-			newFiles = append(newFiles, f.FileName)
-			// get token
-			token, errToken = GetAuthorizationToken()
-			if errToken != nil {
-				err = errToken
-				slog.With("err", err).Error("can not get the auth token")
-				return
-			}
-			slog.With("file", f.FileName, "no", j+1, "total", amountFiles).Info("start downloading")
-			errDownload := DownloadFile(
-				token,
-				EpoDocDBFrontFilesProductID,
-				deliveries.Deliveries[i].DeliveryID,
-				deliveries.Deliveries[i].Files[j].FileID,
-				destinationPath,
-				deliveries.Deliveries[i].Files[j].FileName,
-			)
-			if errDownload != nil {
-				slog.With("err", errDownload).Error("could not download file")
-				err = errDownload
-				return
-			}
-			time.Sleep(time.Second * 30)
-		}
-	}
-	slog.Info("All downloads done")
+	c.logger.Info("All downloads done")
 	return
 }
 
@@ -1,15 +1,24 @@
 package epo_bbds
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
+	"github.com/max-planck-innovation-competition/go-epo-bdds/pkg/epo_bbdstest"
 	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"path/filepath"
 	"sort"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestDownloadDocDbFrontFileWithEncodingIssues(t *testing.T) {
+	skipWithoutCredentials(t)
 	ass := assert.New(t)
 
 	t.Log("Getting EPO Token")
@@ -50,6 +59,7 @@ func TestDownloadDocDbFrontFileWithEncodingIssues(t *testing.T) {
 }
 
 func TestDownloadDocDbBackFile(t *testing.T) {
+	skipWithoutCredentials(t)
 	ass := assert.New(t)
 
 	// get token
@@ -99,6 +109,7 @@ func TestDownloadDocDbBackFile(t *testing.T) {
 }
 
 func TestDownloadDocDbFrontFile(t *testing.T) {
+	skipWithoutCredentials(t)
 	ass := assert.New(t)
 	// get token
 	token, err := GetAuthorizationToken()
@@ -129,6 +140,7 @@ func TestDownloadDocDbFrontFile(t *testing.T) {
 }
 
 func TestDownloadDocDbBackFiles(t *testing.T) {
+	skipWithoutCredentials(t)
 	destinationPath := os.Getenv("DOCDB_BACKFILES_PATH")
 	if len(destinationPath) == 0 {
 		t.Error("no file path found")
@@ -142,6 +154,7 @@ func TestDownloadDocDbBackFiles(t *testing.T) {
 }
 
 func TestDownloadDocDbFrontFiles(t *testing.T) {
+	skipWithoutCredentials(t)
 	destinationPath := os.Getenv("DOCDB_FRONTFILES_PATH")
 	if len(destinationPath) == 0 {
 		t.Error("no file path found")
@@ -155,6 +168,7 @@ func TestDownloadDocDbFrontFiles(t *testing.T) {
 }
 
 func TestDownloadPatstat(t *testing.T) {
+	skipWithoutCredentials(t)
 	destinationPath := os.Getenv("PATSTAT_FILES_PATH")
 	if len(destinationPath) == 0 {
 		t.Error("no file path found")
@@ -166,3 +180,225 @@ func TestDownloadPatstat(t *testing.T) {
 		return
 	}
 }
+
+func TestDownloadFileContextCancelled(t *testing.T) {
+	ass := assert.New(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("first part"))
+		w.(http.Flusher).Flush()
+		// cancel the download while the body is streamed
+		cancel()
+		<-r.Context().Done()
+	}))
+	defer srv.Close()
+
+	c := NewClient(WithBaseURL(srv.URL))
+	dir := t.TempDir()
+	err := c.DownloadFileContext(ctx, "Bearer x", EpoDocDBFrontFilesProductID, 1, 2, dir, "a.zip")
+	ass.ErrorIs(err, context.Canceled)
+	// the incomplete file is not moved to the destination
+	ass.False(pathExists(filepath.Join(dir, "a.zip")))
+}
+
+// rangeTestProduct serves the content as the file 2 of the delivery 1 of the DocDB front files
+func rangeTestProduct(content string) []epo_bbdstest.Product {
+	return []epo_bbdstest.Product{{
+		ID: 3,
+		Deliveries: []epo_bbdstest.Delivery{{
+			ID:    1,
+			Files: []epo_bbdstest.File{epo_bbdstest.NewFile(2, "a.zip", []byte(content))},
+		}},
+	}}
+}
+
+// dropHalf drops the connection of the next download after half of the content
+func dropHalf(srv *epo_bbdstest.Server, content string) {
+	srv.AddFaults(epo_bbdstest.Fault{Endpoint: epo_bbdstest.EndpointDownload, Drop: true, DropAfter: int64(len(content) / 2)})
+}
+
+func TestDownloadFileResume(t *testing.T) {
+	ass := assert.New(t)
+	content := strings.Repeat("0123456789", 100)
+	srv, c := newTestClient(t, rangeTestProduct(content))
+	dropHalf(srv, content)
+
+	dir := t.TempDir()
+	err := c.DownloadFile("", EpoDocDBFrontFilesProductID, 1, 2, dir, "a.zip")
+	ass.NoError(err)
+	data, err := os.ReadFile(filepath.Join(dir, "a.zip"))
+	ass.NoError(err)
+	ass.Equal(content, string(data))
+	ass.Equal([]string{"", "bytes=500-"}, srv.Ranges())
+	ass.False(pathExists(filepath.Join(dir, "a.zip"+PartFileSuffix)))
+}
+
+func TestDownloadFileResumeWithoutRangeSupport(t *testing.T) {
+	ass := assert.New(t)
+	content := strings.Repeat("0123456789", 100)
+	srv, c := newTestClient(t, rangeTestProduct(content))
+	dropHalf(srv, content)
+	srv.SetRangeSupport(false)
+
+	dir := t.TempDir()
+	err := c.DownloadFile("", EpoDocDBFrontFilesProductID, 1, 2, dir, "a.zip")
+	ass.NoError(err)
+	data, err := os.ReadFile(filepath.Join(dir, "a.zip"))
+	ass.NoError(err)
+	ass.Equal(content, string(data))
+	ass.Equal([]string{"", "bytes=500-"}, srv.Ranges())
+}
+
+func TestDownloadFileResumeFromPartFile(t *testing.T) {
+	ass := assert.New(t)
+	content := strings.Repeat("0123456789", 100)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeContent(w, r, "file.zip", time.Time{}, strings.NewReader(content))
+	}))
+	defer srv.Close()
+
+	// part file of a previous run
+	dir := t.TempDir()
+	err := os.WriteFile(filepath.Join(dir, "a.zip"+PartFileSuffix), []byte(content[:300]), 0644)
+	ass.NoError(err)
+
+	c := NewClient(WithBaseURL(srv.URL), WithDownloadAttempts(1))
+	err = c.DownloadFile("Bearer x", EpoDocDBFrontFilesProductID, 1, 2, dir, "a.zip")
+	ass.NoError(err)
+	data, err := os.ReadFile(filepath.Join(dir, "a.zip"))
+	ass.NoError(err)
+	ass.Equal(content, string(data))
+}
+
+func TestDownloadFileItemChecksum(t *testing.T) {
+	ass := assert.New(t)
+	content := strings.Repeat("0123456789", 100)
+	sum := sha256.Sum256([]byte(content))
+	srv, c := newTestClient(t, rangeTestProduct(content))
+	dropHalf(srv, content)
+
+	dir := t.TempDir()
+	file := EpoDocDbFileItem{FileID: 2, FileName: "a.zip", FileChecksum: hex.EncodeToString(sum[:])}
+	// the download is resumed, the hash must include the first part
+	err := c.DownloadFileItemContext(context.Background(), "", EpoDocDBFrontFilesProductID, 1, file, dir)
+	ass.NoError(err)
+	ass.True(pathExists(filepath.Join(dir, "a.zip")))
+
+	// wrong checksum
+	file.FileName = "b.zip"
+	file.FileChecksum = strings.Repeat("0", 64)
+	err = c.DownloadFileItemContext(context.Background(), "", EpoDocDBFrontFilesProductID, 1, file, dir)
+	ass.ErrorIs(err, ErrChecksumMismatch)
+	ass.False(pathExists(filepath.Join(dir, "b.zip")))
+	ass.False(pathExists(filepath.Join(dir, "b.zip"+PartFileSuffix)))
+
+	// a checksum of an unknown format is not verified
+	file.FileName = "c.zip"
+	file.FileChecksum = "crc32:1234abcd"
+	err = c.DownloadFileItemContext(context.Background(), "", EpoDocDBFrontFilesProductID, 1, file, dir)
+	ass.NoError(err)
+	ass.True(pathExists(filepath.Join(dir, "c.zip")))
+}
+
+func TestDownloadFileCompletePartFile(t *testing.T) {
+	ass := assert.New(t)
+	content := strings.Repeat("0123456789", 100)
+	sum := sha256.Sum256([]byte(content))
+	srv, c := newTestClient(t, rangeTestProduct(content))
+	file := EpoDocDbFileItem{FileID: 2, FileName: "a.zip", FileChecksum: hex.EncodeToString(sum[:])}
+
+	// the previous run stopped before the rename of the complete part file
+	dir := t.TempDir()
+	partPath := filepath.Join(dir, "a.zip"+PartFileSuffix)
+	ass.NoError(os.WriteFile(partPath, []byte(content), 0644))
+	err := c.DownloadFileItemContext(context.Background(), "", EpoDocDBFrontFilesProductID, 1, file, dir)
+	ass.NoError(err)
+	data, err := os.ReadFile(filepath.Join(dir, "a.zip"))
+	ass.NoError(err)
+	ass.Equal(content, string(data))
+	ass.False(pathExists(partPath))
+	// the content is not downloaded again
+	ass.Equal([]string{"bytes=1000-"}, srv.Ranges())
+
+	// a complete part file that does not match the checksum is removed
+	file.FileName = "b.zip"
+	partPath = filepath.Join(dir, "b.zip"+PartFileSuffix)
+	ass.NoError(os.WriteFile(partPath, []byte(strings.Repeat("x", len(content))), 0644))
+	err = c.DownloadFileItemContext(context.Background(), "", EpoDocDBFrontFilesProductID, 1, file, dir)
+	ass.ErrorIs(err, ErrChecksumMismatch)
+	ass.False(pathExists(partPath))
+	ass.False(pathExists(filepath.Join(dir, "b.zip")))
+
+	// a part file that is longer than the file is downloaded again
+	file.FileName = "c.zip"
+	partPath = filepath.Join(dir, "c.zip"+PartFileSuffix)
+	ass.NoError(os.WriteFile(partPath, []byte(content+content), 0644))
+	err = c.DownloadFileItemContext(context.Background(), "", EpoDocDBFrontFilesProductID, 1, file, dir)
+	ass.NoError(err)
+	data, err = os.ReadFile(filepath.Join(dir, "c.zip"))
+	ass.NoError(err)
+	ass.Equal(content, string(data))
+}
+
+func TestContentRangeSize(t *testing.T) {
+	ass := assert.New(t)
+	ass.Equal(int64(200), contentRangeSize("bytes */200"))
+	ass.Equal(int64(200), contentRangeSize("bytes 100-199/200"))
+	ass.Equal(int64(-1), contentRangeSize("bytes 100-199/*"))
+	ass.Equal(int64(-1), contentRangeSize(""))
+}
+
+func TestDownloadFileUnexpectedContentRange(t *testing.T) {
+	ass := assert.New(t)
+	content := strings.Repeat("0123456789", 100)
+	var ranges []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ranges = append(ranges, r.Header.Get("Range"))
+		if r.Header.Get("Range") != "" {
+			// the range does not start at the offset of the part file
+			w.Header().Set("Content-Range", fmt.Sprintf("bytes 100-%d/%d", len(content)-1, len(content)))
+			w.WriteHeader(http.StatusPartialContent)
+			_, _ = w.Write([]byte(content[100:]))
+			return
+		}
+		_, _ = w.Write([]byte(content))
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	err := os.WriteFile(filepath.Join(dir, "a.zip"+PartFileSuffix), []byte(content[:300]), 0644)
+	ass.NoError(err)
+
+	c := NewClient(WithBaseURL(srv.URL))
+	err = c.DownloadFile("Bearer x", EpoDocDBFrontFilesProductID, 1, 2, dir, "a.zip")
+	ass.NoError(err)
+	data, err := os.ReadFile(filepath.Join(dir, "a.zip"))
+	ass.NoError(err)
+	ass.Equal(content, string(data))
+	ass.Equal([]string{"bytes=300-", ""}, ranges)
+}
+
+func TestDownloadFileProgress(t *testing.T) {
+	ass := assert.New(t)
+	content := strings.Repeat("0123456789", 100)
+	srv, c := newTestClient(t, rangeTestProduct(content))
+	dropHalf(srv, content)
+
+	var events []ProgressEvent
+	err := c.DownloadFile("", EpoDocDBFrontFilesProductID, 1, 2, t.TempDir(), "a.zip",
+		WithDownloadProgress(func(event ProgressEvent) {
+			events = append(events, event)
+		}))
+	ass.NoError(err)
+	if !ass.NotEmpty(events) {
+		return
+	}
+	// the resumed download counts the first part
+	last := events[len(events)-1]
+	ass.True(last.File.Done)
+	ass.True(last.Overall.Done)
+	ass.Equal("a.zip", last.File.FileName)
+	ass.Equal(int64(len(content)), last.File.Bytes)
+	ass.Equal(int64(len(content)), last.File.Total)
+	ass.Equal(1, last.Files)
+}
@@ -0,0 +1,243 @@
+package epo_bbds
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/max-planck-innovation-competition/go-epo-bdds/pkg/storage"
+)
+
+// DefaultDownloadParallelism is the default number of parallel downloads
+const DefaultDownloadParallelism = 2
+
+// DownloadJob is a single file of the catalog that should be downloaded
+type DownloadJob struct {
+	ProductID       EpoBddsBProductID // product of the file
+	DeliveryID      int               // delivery of the file
+	File            EpoDocDbFileItem  // file of the catalog
+	DestinationPath string            // directory the file is saved to
+}
+
+// FilePath returns the local path of the downloaded file
+func (j DownloadJob) FilePath() string {
+	return filepath.Join(j.DestinationPath, j.File.FileName)
+}
+
+// ObjectName returns the name of the downloaded file in a storage,
+// the destination path is the prefix of the name
+func (j DownloadJob) ObjectName() string {
+	return path.Join(filepath.ToSlash(j.DestinationPath), j.File.FileName)
+}
+
+// verifyFile checks if the existing file of the job is complete,
+// a file that does not match the checksum of the catalog is downloaded again
+func (j DownloadJob) verifyFile() error {
+	return VerifyFileChecksum(j.FilePath(), j.File.FileChecksum)
+}
+
+// DownloadResult is the result of a single download job
+type DownloadResult struct {
+	Job      DownloadJob
+	Object   string        // name of the object in the storage, empty without a storage
+	Skipped  bool          // the file existed already and matched the checksum
+	Err      error         // nil if the download was successful
+	Duration time.Duration // time the download took
+}
+
+// DownloadManager downloads a queue of files in parallel.
+// The requests per second and the bandwidth can be limited.
+type DownloadManager struct {
+	client           *Client
+	Parallelism      int             // number of parallel downloads
+	requestLimiter   *RateLimiter    // optional limit of the requests per second
+	bandwidthLimiter *RateLimiter    // optional limit of the bytes per second over all downloads
+	progressFn       ProgressFunc    // optional receiver of progress events
+	progressInterval time.Duration   // minimum time between two progress events of a file
+	storage          storage.Storage // optional storage the files are saved to instead of the local filesystem
+
+	mu    sync.Mutex
+	queue []DownloadJob
+}
+
+// NewDownloadManager creates a new download manager for the client
+func NewDownloadManager(c *Client) *DownloadManager {
+	return &DownloadManager{
+		client:           c,
+		Parallelism:      DefaultDownloadParallelism,
+		progressInterval: DefaultProgressInterval,
+	}
+}
+
+// SetParallelism sets the number of parallel downloads
+func (m *DownloadManager) SetParallelism(parallelism int) *DownloadManager {
+	if parallelism < 1 {
+		parallelism = 1
+	}
+	m.Parallelism = parallelism
+	return m
+}
+
+// SetRequestRate limits the download requests per second.
+// burst is the number of requests that can be sent at once.
+func (m *DownloadManager) SetRequestRate(requestsPerSecond float64, burst int) *DownloadManager {
+	m.requestLimiter = NewRateLimiter(requestsPerSecond, burst)
+	return m
+}
+
+// SetBandwidthLimit limits the bytes per second of all downloads together,
+// a limit of 0 or less turns the limit off
+func (m *DownloadManager) SetBandwidthLimit(bytesPerSecond int64) *DownloadManager {
+	if bytesPerSecond <= 0 {
+		m.bandwidthLimiter = nil
+		return m
+	}
+	m.bandwidthLimiter = NewRateLimiter(float64(bytesPerSecond), int(bytesPerSecond))
+	return m
+}
+
+// SetProgress sets a function that receives the progress of the files and of the whole run.
+// The total size is taken from the Content-Length of the response or the file size of the catalog.
+func (m *DownloadManager) SetProgress(fn ProgressFunc) *DownloadManager {
+	m.progressFn = fn
+	return m
+}
+
+// SetProgressInterval sets the minimum time between two progress events of a file,
+// the event of a finished file is always sent
+func (m *DownloadManager) SetProgressInterval(interval time.Duration) *DownloadManager {
+	m.progressInterval = interval
+	return m
+}
+
+// SetStorage saves the files to the storage instead of the local filesystem.
+// The destination paths of the jobs are the prefixes of the object names.
+func (m *DownloadManager) SetStorage(s storage.Storage) *DownloadManager {
+	m.storage = s
+	return m
+}
+
+// Enqueue adds jobs to the queue
+func (m *DownloadManager) Enqueue(jobs ...DownloadJob) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.queue = append(m.queue, jobs...)
+}
+
+// Run downloads all jobs of the queue and empties the queue.
+// A failed download does not stop the other downloads,
+// the results are returned in the order of the queue.
+// If the context is cancelled, the remaining jobs fail with the context error.
+func (m *DownloadManager) Run(ctx context.Context) (results []DownloadResult) {
+	m.mu.Lock()
+	jobs := m.queue
+	m.queue = nil
+	m.mu.Unlock()
+
+	var progress *runProgress
+	if m.progressFn != nil {
+		progress = newRunProgress(m.progressFn, m.progressInterval, jobs)
+	}
+
+	results = make([]DownloadResult, len(jobs))
+	jobCh := make(chan int, len(jobs))
+	for i := range jobs {
+		jobCh <- i
+	}
+	close(jobCh)
+
+	parallelism := m.Parallelism
+	if parallelism < 1 {
+		parallelism = 1
+	}
+	var wg sync.WaitGroup
+	for w := 0; w < parallelism; w++ {
+		wg.Add(1)
+		go func(workerId int) {
+			defer wg.Done()
+			for i := range jobCh {
+				results[i] = m.download(ctx, jobs[i], progress.file(jobs[i]))
+				m.client.logger.
+					With("workerId", workerId, "file", jobs[i].File.FileName, "no", i+1, "total", len(jobs)).
+					Debug("worker finished download job")
+			}
+		}(w)
+	}
+	wg.Wait()
+	return
+}
+
+// download runs a single job
+func (m *DownloadManager) download(ctx context.Context, job DownloadJob, progress *fileProgress) (result DownloadResult) {
+	result.Job = job
+	start := time.Now()
+	defer func() {
+		result.Duration = time.Since(start)
+		progress.finish(result.Err == nil)
+	}()
+	logger := m.client.logger.With("file", job.File.FileName)
+
+	if ctx.Err() != nil {
+		result.Err = ctx.Err()
+		return
+	}
+	if m.storage != nil {
+		result.Object = job.ObjectName()
+		result.Skipped, result.Err = m.downloadToStorage(ctx, job, progress)
+		return
+	}
+	// check if file exists and is complete
+	if pathExists(job.FilePath()) {
+		errVerify := job.verifyFile()
+		if errVerify == nil {
+			logger.Info("file exists already")
+			result.Skipped = true
+			return
+		}
+		logger.With("err", errVerify).Warn("existing file does not match the checksum, download again")
+	}
+
+	logger.Info("start downloading")
+	endpoint := fmt.Sprintf(m.client.fileEndpoint, string(job.ProductID), job.DeliveryID, job.File.FileID)
+	result.Err = m.client.downloadFile(ctx, "", endpoint, job.DestinationPath, job.File.FileName, downloadOptions{
+		checksum:         job.File.FileChecksum,
+		requestLimiter:   m.requestLimiter,
+		bandwidthLimiter: m.bandwidthLimiter,
+		progress:         progress,
+	})
+	if result.Err != nil {
+		logger.With("err", result.Err).Error("could not download file")
+	}
+	return
+}
+
+// downloadToStorage runs a single job with the storage
+func (m *DownloadManager) downloadToStorage(ctx context.Context, job DownloadJob, progress *fileProgress) (skipped bool, err error) {
+	name := job.ObjectName()
+	logger := m.client.logger.With("object", name)
+	// check if the object exists and is complete
+	if _, errStat := m.storage.Stat(ctx, name); errStat == nil {
+		errVerify := VerifyObjectChecksum(ctx, m.storage, name, job.File.FileChecksum)
+		if errVerify == nil {
+			logger.Info("object exists already")
+			return true, nil
+		}
+		logger.With("err", errVerify).Warn("existing object does not match the checksum, download again")
+	}
+
+	logger.Info("start downloading")
+	endpoint := fmt.Sprintf(m.client.fileEndpoint, string(job.ProductID), job.DeliveryID, job.File.FileID)
+	err = m.client.downloadToStorage(ctx, "", endpoint, m.storage, name, downloadOptions{
+		checksum:         job.File.FileChecksum,
+		requestLimiter:   m.requestLimiter,
+		bandwidthLimiter: m.bandwidthLimiter,
+		progress:         progress,
+	})
+	if err != nil {
+		logger.With("err", err).Error("could not download file")
+	}
+	return
+}
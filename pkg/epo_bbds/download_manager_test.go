@@ -0,0 +1,108 @@
+package epo_bbds
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDownloadManager(t *testing.T) {
+	ass := assert.New(t)
+	var parallel, maxParallel int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&parallel, 1)
+		defer atomic.AddInt32(&parallel, -1)
+		for {
+			max := atomic.LoadInt32(&maxParallel)
+			if n <= max || atomic.CompareAndSwapInt32(&maxParallel, max, n) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+		if strings.Contains(r.URL.Path, "/file/3/") {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write([]byte("content"))
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	sum := sha256.Sum256([]byte("content"))
+	checksum := hex.EncodeToString(sum[:])
+	// an existing complete file
+	err := os.WriteFile(filepath.Join(dir, "0.zip"), []byte("content"), 0644)
+	ass.NoError(err)
+
+	m := NewDownloadManager(NewClient(WithBaseURL(srv.URL), WithCredentials("u", "p"))).
+		SetParallelism(3).
+		SetRequestRate(1000, 10)
+	m.client.tokens.token = "Bearer x"
+	for i, name := range []string{"0.zip", "1.zip", "2.zip", "3.zip", "4.zip", "5.zip"} {
+		m.Enqueue(DownloadJob{
+			ProductID:       EpoDocDBBackFilesProductID,
+			DeliveryID:      1,
+			File:            EpoDocDbFileItem{FileID: i, FileName: name, FileChecksum: checksum},
+			DestinationPath: dir,
+		})
+	}
+	results := m.Run(context.Background())
+	ass.Len(results, 6)
+	ass.True(results[0].Skipped)
+	for i, result := range results {
+		ass.Equal(i, result.Job.File.FileID)
+		if i == 3 {
+			ass.ErrorIs(result.Err, ErrCanNotDownload)
+			continue
+		}
+		ass.NoError(result.Err)
+		ass.True(pathExists(result.Job.FilePath()))
+	}
+	ass.Equal(int32(3), atomic.LoadInt32(&maxParallel))
+
+	// the queue is empty after the run
+	ass.Empty(m.Run(context.Background()))
+}
+
+func TestDownloadManagerCancelled(t *testing.T) {
+	ass := assert.New(t)
+	m := NewDownloadManager(NewClient())
+	m.Enqueue(DownloadJob{File: EpoDocDbFileItem{FileName: "a.zip"}, DestinationPath: t.TempDir()})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	results := m.Run(ctx)
+	ass.Len(results, 1)
+	ass.ErrorIs(results[0].Err, context.Canceled)
+}
+
+func TestDownloadManagerBandwidthLimit(t *testing.T) {
+	ass := assert.New(t)
+	content := strings.Repeat("x", 3000)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(content))
+	}))
+	defer srv.Close()
+
+	// the first 1000 bytes are the burst, the rest takes two seconds
+	m := NewDownloadManager(NewClient(WithBaseURL(srv.URL))).SetBandwidthLimit(1000)
+	m.client.tokens.token = "Bearer x"
+	m.Enqueue(DownloadJob{File: EpoDocDbFileItem{FileName: "a.zip"}, DestinationPath: t.TempDir()})
+	start := time.Now()
+	results := m.Run(context.Background())
+	ass.NoError(results[0].Err)
+	ass.GreaterOrEqual(time.Since(start), 1500*time.Millisecond)
+
+	// a limit of 0 turns the limit off
+	m.SetBandwidthLimit(0)
+	ass.Nil(m.bandwidthLimiter)
+}
@@ -0,0 +1,155 @@
+package epo_bbds
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/max-planck-innovation-competition/go-epo-bdds/pkg/storage"
+)
+
+// DownloadFileItemToStorageContext downloads a file of the catalog into an object of the storage
+// and verifies it against the checksum of the catalog
+func DownloadFileItemToStorageContext(ctx context.Context, token string, productID EpoBddsBProductID, deliveryID int, file EpoDocDbFileItem, store storage.Storage, prefix string, options ...DownloadOption) (err error) {
+	return defaultClient().DownloadFileItemToStorageContext(ctx, token, productID, deliveryID, file, store, prefix, options...)
+}
+
+// DownloadFileItemToStorageContext downloads a file of the catalog into an object of the storage.
+// The name of the object is the file name below the prefix.
+// The object is only created once the content is complete and matches the checksum of the catalog,
+// a *ChecksumError is returned if they do not match.
+func (c *Client) DownloadFileItemToStorageContext(ctx context.Context, token string, productID EpoBddsBProductID, deliveryID int, file EpoDocDbFileItem, store storage.Storage, prefix string, options ...DownloadOption) (err error) {
+	// build endpoint url
+	endpoint := fmt.Sprintf(c.fileEndpoint, string(productID), deliveryID, file.FileID)
+	name := DownloadJob{File: file, DestinationPath: prefix}.ObjectName()
+	opts := newDownloadOptions(file, options)
+	opts.checksum = file.FileChecksum
+	err = c.downloadToStorage(ctx, token, endpoint, store, name, opts)
+	opts.progress.finish(err == nil)
+	return
+}
+
+// downloadToStorage downloads the endpoint into an object of the storage.
+// The object is only created once the content is complete and matches the checksum.
+// Storages do not support resuming, an interrupted download starts again.
+func (c *Client) downloadToStorage(ctx context.Context, token, endpoint string, store storage.Storage, name string, opts downloadOptions) (err error) {
+	logger := c.logger.With("object", name)
+	for attempt := 1; ; attempt++ {
+		var retry bool
+		retry, err = c.downloadObject(ctx, token, endpoint, store, name, opts)
+		if err == nil {
+			return
+		}
+		if !retry || ctx.Err() != nil || attempt >= c.downloadAttempts {
+			logger.With("err", err, "attempt", attempt).Error("failed to download file")
+			return
+		}
+		logger.With("err", err, "attempt", attempt).Warn("download interrupted, restart download")
+	}
+}
+
+// downloadObject downloads the endpoint into an object of the storage.
+// retry reports if the error is temporary and the download can be restarted.
+func (c *Client) downloadObject(ctx context.Context, token, endpoint string, store storage.Storage, name string, opts downloadOptions) (retry bool, err error) {
+	logger := c.logger.With("object", name)
+
+	// hash the file while it is downloaded
+	var h hash.Hash
+	if opts.checksum != "" {
+		h = newChecksumHash(opts.checksum)
+	}
+	// wait for the rate limit
+	err = opts.requestLimiter.Wait(ctx)
+	if err != nil {
+		return
+	}
+	req, err := c.newRequest(ctx, "GET", endpoint, strings.NewReader(""))
+	if err != nil {
+		return
+	}
+	resp, err := c.do(req, token)
+	if err != nil {
+		logger.With("err", err).Error("failed to send request")
+		return true, err
+	}
+	defer func(Body io.ReadCloser) {
+		err := Body.Close()
+		if err != nil {
+			logger.With("err", err).Error("failed to close body")
+		}
+	}(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		err = newAPIError(resp, ErrCanNotDownload)
+		logger.With("err", err, "status", resp.Status).Error("failed to download file")
+		// temporary errors were already retried when the request was sent
+		return
+	}
+	opts.progress.begin(0, resp.ContentLength)
+
+	var body io.Reader = resp.Body
+	body = newRateLimitedReader(ctx, body, opts.bandwidthLimiter)
+	if opts.progress != nil {
+		body = io.TeeReader(body, opts.progress)
+	}
+	if h != nil {
+		body = &checksumReader{reader: io.TeeReader(body, h), hash: h, checksum: opts.checksum, name: name}
+	}
+	_, err = store.Put(ctx, name, body)
+	if err != nil {
+		// a corrupt file is not retried
+		return !errors.Is(err, ErrChecksumMismatch), err
+	}
+	return
+}
+
+// checksumReader compares the hash with the checksum at the end of the content
+// and returns a *ChecksumError instead of io.EOF if they do not match,
+// so that the storage does not create the object
+type checksumReader struct {
+	reader   io.Reader
+	hash     hash.Hash
+	checksum string
+	name     string
+}
+
+// Read reads from the reader and verifies the checksum at the end
+func (r *checksumReader) Read(p []byte) (n int, err error) {
+	n, err = r.reader.Read(p)
+	if err == io.EOF {
+		if actual, ok := checksumMatches(r.hash, r.checksum); !ok {
+			return n, &ChecksumError{FileName: r.name, Expected: r.checksum, Actual: actual}
+		}
+	}
+	return
+}
+
+// VerifyObjectChecksum verifies an object of the storage against a checksum of the catalog.
+// An empty checksum is not verified.
+func VerifyObjectChecksum(ctx context.Context, store storage.Storage, name, checksum string) (err error) {
+	if checksum == "" {
+		return nil
+	}
+	h := newChecksumHash(checksum)
+	if h == nil {
+		return nil
+	}
+	rc, err := store.Open(ctx, name)
+	if err != nil {
+		return
+	}
+	defer func(rc io.ReadCloser) {
+		_ = rc.Close()
+	}(rc)
+	_, err = io.Copy(h, rc)
+	if err != nil {
+		return
+	}
+	if actual, ok := checksumMatches(h, checksum); !ok {
+		return &ChecksumError{FileName: name, Expected: checksum, Actual: actual}
+	}
+	return nil
+}
@@ -0,0 +1,102 @@
+package epo_bbds
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"io"
+	"testing"
+
+	"github.com/max-planck-innovation-competition/go-epo-bdds/pkg/epo_bbdstest"
+	"github.com/max-planck-innovation-competition/go-epo-bdds/pkg/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+// checksumCatalog returns the test catalog with the checksums of the file contents
+func checksumCatalog() EpoProductDeliveriesResponse {
+	catalog := testCatalog
+	catalog.Deliveries = nil
+	for _, d := range testCatalog.Deliveries {
+		d.Files = append([]EpoDocDbFileItem{}, d.Files...)
+		for i, f := range d.Files {
+			sum := md5.Sum([]byte(f.FileName))
+			d.Files[i].FileChecksum = hex.EncodeToString(sum[:])
+		}
+		catalog.Deliveries = append(catalog.Deliveries, d)
+	}
+	return catalog
+}
+
+func TestProductSyncStorage(t *testing.T) {
+	ass := assert.New(t)
+	srv, c := newCatalogTestServer(t, checksumCatalog())
+	store := storage.NewLocal(t.TempDir())
+	ctx := context.Background()
+
+	// the first download of a file is dropped and restarted
+	srv.AddFaults(epo_bbdstest.Fault{Endpoint: epo_bbdstest.EndpointDownload, Drop: true, DropAfter: 3})
+	s := c.NewProductSync(EpoPatstatGlobalProductID, "patstat").SetLayout(MirrorLayout)
+	s.DownloadManager().SetStorage(store).SetParallelism(1)
+	result, err := s.Run(ctx)
+	ass.NoError(err)
+	ass.Len(result.Downloaded(), 4)
+
+	objects, err := store.List(ctx, "patstat/17/")
+	ass.NoError(err)
+	ass.Len(objects, 4)
+	rc, err := store.Open(ctx, "patstat/17/PATSTAT Global 2024 Spring/data_PATSTAT_Global_2024_Spring_01.zip")
+	ass.NoError(err)
+	content, _ := io.ReadAll(rc)
+	_ = rc.Close()
+	ass.Equal("data_PATSTAT_Global_2024_Spring_01.zip", string(content))
+
+	// the complete objects are skipped
+	result, err = s.Run(ctx)
+	ass.NoError(err)
+	ass.Len(result.Skipped(), 4)
+}
+
+func TestProductSyncStorageChecksumMismatch(t *testing.T) {
+	ass := assert.New(t)
+	catalog := checksumCatalog()
+	catalog.Deliveries = catalog.Deliveries[:1]
+	catalog.Deliveries[0].Files = catalog.Deliveries[0].Files[:1]
+	catalog.Deliveries[0].Files[0].FileChecksum = "00000000000000000000000000000000"
+	srv, c := newCatalogTestServer(t, catalog)
+	store := storage.NewLocal(t.TempDir())
+
+	s := c.NewProductSync(EpoPatstatGlobalProductID, "")
+	s.DownloadManager().SetStorage(store)
+	_, err := s.Run(context.Background())
+	ass.ErrorIs(err, ErrChecksumMismatch)
+	// a corrupt file is neither stored nor downloaded again
+	objects, err := store.List(context.Background(), "")
+	ass.NoError(err)
+	ass.Empty(objects)
+	ass.Equal(1, srv.Requests(epo_bbdstest.EndpointDownload))
+}
+
+func TestDownloadFileItemToStorage(t *testing.T) {
+	ass := assert.New(t)
+	catalog := checksumCatalog()
+	_, c := newCatalogTestServer(t, catalog)
+	store := storage.NewLocal(t.TempDir())
+	ctx := context.Background()
+
+	file := catalog.Deliveries[1].Files[0]
+	err := c.DownloadFileItemToStorageContext(ctx, "", EpoPatstatGlobalProductID, 2, file, store, "patstat")
+	ass.NoError(err)
+	rc, err := store.Open(ctx, "patstat/data_PATSTAT_Global_2024_Spring_01.zip")
+	ass.NoError(err)
+	content, _ := io.ReadAll(rc)
+	_ = rc.Close()
+	ass.Equal(file.FileName, string(content))
+
+	// a corrupt file is not stored
+	file = catalog.Deliveries[1].Files[1]
+	file.FileChecksum = "00000000000000000000000000000000"
+	err = c.DownloadFileItemToStorageContext(ctx, "", EpoPatstatGlobalProductID, 2, file, store, "patstat")
+	ass.ErrorIs(err, ErrChecksumMismatch)
+	_, err = store.Stat(ctx, "patstat/documentation_PATSTAT_Global_2024_Spring.zip")
+	ass.Error(err)
+}
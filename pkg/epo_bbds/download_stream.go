@@ -0,0 +1,198 @@
+package epo_bbds
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+)
+
+// OpenFileStream opens the content of a file of the bulk data service as a stream
+func OpenFileStream(token string, productID EpoBddsBProductID, deliveryID, fileID int) (rc io.ReadCloser, err error) {
+	return defaultClient().OpenFileStream(token, productID, deliveryID, fileID)
+}
+
+// OpenFileStreamContext opens the content of a file of the bulk data service as a stream
+// the download is aborted if the context is cancelled
+func OpenFileStreamContext(ctx context.Context, token string, productID EpoBddsBProductID, deliveryID, fileID int) (rc io.ReadCloser, err error) {
+	return defaultClient().OpenFileStreamContext(ctx, token, productID, deliveryID, fileID)
+}
+
+// OpenFileItemStreamContext opens the content of a file of the catalog as a stream
+// and verifies it against the checksum of the catalog
+func OpenFileItemStreamContext(ctx context.Context, token string, productID EpoBddsBProductID, deliveryID int, file EpoDocDbFileItem) (rc io.ReadCloser, err error) {
+	return defaultClient().OpenFileItemStreamContext(ctx, token, productID, deliveryID, file)
+}
+
+// OpenFileStream opens the content of a file of the bulk data service as a stream
+// if the token is empty, the token source of the client is used
+func (c *Client) OpenFileStream(token string, productID EpoBddsBProductID, deliveryID, fileID int) (rc io.ReadCloser, err error) {
+	return c.OpenFileStreamContext(context.Background(), token, productID, deliveryID, fileID)
+}
+
+// OpenFileStreamContext opens the content of a file of the bulk data service as a stream,
+// e.g. to process a bulk file while it is downloaded without writing it to disk.
+// If the token is empty, the token source of the client is used.
+// An interrupted connection is resumed with a range request while reading.
+// The caller must close the stream.
+func (c *Client) OpenFileStreamContext(ctx context.Context, token string, productID EpoBddsBProductID, deliveryID, fileID int) (rc io.ReadCloser, err error) {
+	// build endpoint url
+	endpoint := fmt.Sprintf(c.fileEndpoint, string(productID), deliveryID, fileID)
+	return c.openFileStream(ctx, token, endpoint, fmt.Sprintf("%d", fileID), "")
+}
+
+// OpenFileItemStreamContext opens the content of a file of the catalog as a stream.
+// The content is hashed while it is read and a *ChecksumError is returned
+// instead of io.EOF if it does not match the checksum of the catalog.
+func (c *Client) OpenFileItemStreamContext(ctx context.Context, token string, productID EpoBddsBProductID, deliveryID int, file EpoDocDbFileItem) (rc io.ReadCloser, err error) {
+	// build endpoint url
+	endpoint := fmt.Sprintf(c.fileEndpoint, string(productID), deliveryID, file.FileID)
+	return c.openFileStream(ctx, token, endpoint, file.FileName, file.FileChecksum)
+}
+
+// openFileStream opens the endpoint as a stream
+// and verifies the checksum at the end if it is not empty
+func (c *Client) openFileStream(ctx context.Context, token, endpoint, name, checksum string) (rc io.ReadCloser, err error) {
+	s := &fileStream{
+		client:   c,
+		ctx:      ctx,
+		token:    token,
+		endpoint: endpoint,
+		name:     name,
+		checksum: checksum,
+		logger:   c.logger.With("file", name),
+	}
+	if checksum != "" {
+		s.hash = newChecksumHash(checksum)
+	}
+	err = s.connect()
+	if err != nil {
+		return
+	}
+	return s, nil
+}
+
+// fileStream reads the content of a download
+// and resumes the download with a range request if the connection is interrupted
+type fileStream struct {
+	client   *Client
+	ctx      context.Context
+	token    string
+	endpoint string
+	name     string
+	checksum string
+	hash     hash.Hash
+	logger   *slog.Logger
+
+	body     io.ReadCloser
+	offset   int64 // bytes read so far
+	attempts int   // requests sent so far
+	err      error // sticky error once the stream failed or ended
+}
+
+// connect sends requests until the content is available from the current offset
+func (s *fileStream) connect() (err error) {
+	for {
+		s.attempts++
+		var retry bool
+		retry, err = s.open()
+		if err == nil {
+			return
+		}
+		if !retry || s.ctx.Err() != nil || s.attempts >= s.client.downloadAttempts {
+			s.logger.With("err", err, "attempt", s.attempts).Error("failed to download file")
+			return
+		}
+		s.logger.With("err", err, "attempt", s.attempts).Warn("download interrupted, resume download")
+	}
+}
+
+// open requests the content from the current offset.
+// retry reports if the error is temporary and the request can be sent again.
+func (s *fileStream) open() (retry bool, err error) {
+	logger := s.logger.With("offset", s.offset)
+	req, err := s.client.newRequest(s.ctx, "GET", s.endpoint, strings.NewReader(""))
+	if err != nil {
+		return
+	}
+	if s.offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", s.offset))
+	}
+	resp, err := s.client.do(req, s.token)
+	if err != nil {
+		logger.With("err", err).Error("failed to send request")
+		return true, err
+	}
+	switch {
+	case resp.StatusCode == http.StatusPartialContent && s.offset > 0 && contentRangeStart(resp.Header.Get("Content-Range")) == s.offset:
+		// resume the download
+		logger.Info("resume download")
+	case resp.StatusCode == http.StatusOK:
+		if s.offset > 0 {
+			// the server ignores the range, skip the content that was already read
+			logger.Info("server does not support ranges, skip content that was already read")
+			_, err = io.CopyN(io.Discard, resp.Body, s.offset)
+			if err != nil {
+				_ = resp.Body.Close()
+				return true, err
+			}
+		}
+	default:
+		err = newAPIError(resp, ErrCanNotDownload)
+		logger.With("err", err, "status", resp.Status).Error("failed to download file")
+		_ = resp.Body.Close()
+		// temporary errors were already retried when the request was sent
+		return
+	}
+	s.body = resp.Body
+	return
+}
+
+// Read reads the content, resumes interrupted downloads
+// and verifies the checksum at the end
+func (s *fileStream) Read(p []byte) (n int, err error) {
+	if s.err != nil {
+		return 0, s.err
+	}
+	n, err = s.body.Read(p)
+	s.offset += int64(n)
+	if s.hash != nil {
+		s.hash.Write(p[:n])
+	}
+	switch {
+	case err == io.EOF:
+		if s.hash != nil {
+			if actual, ok := checksumMatches(s.hash, s.checksum); !ok {
+				err = &ChecksumError{FileName: s.name, Expected: s.checksum, Actual: actual}
+				s.logger.With("err", err).Error("downloaded file does not match checksum")
+			}
+		}
+	case err != nil:
+		_ = s.body.Close()
+		if s.ctx.Err() != nil || s.attempts >= s.client.downloadAttempts {
+			s.logger.With("err", err, "attempt", s.attempts).Error("failed to download file")
+			break
+		}
+		s.logger.With("err", err, "attempt", s.attempts).Warn("download interrupted, resume download")
+		err = s.connect()
+	}
+	if err != nil {
+		s.err = err
+	}
+	return
+}
+
+// Close closes the connection
+func (s *fileStream) Close() error {
+	if s.err == nil {
+		s.err = errStreamClosed
+	}
+	return s.body.Close()
+}
+
+// errStreamClosed is returned when reading from a closed stream
+var errStreamClosed = errors.New("read from closed stream")
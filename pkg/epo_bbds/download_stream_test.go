@@ -0,0 +1,83 @@
+package epo_bbds
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/max-planck-innovation-competition/go-epo-bdds/pkg/epo_bbdstest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOpenFileItemStreamContext(t *testing.T) {
+	ass := assert.New(t)
+	catalog := checksumCatalog()
+	file := catalog.Deliveries[1].Files[0]
+	ctx := context.Background()
+
+	for _, ranges := range []bool{true, false} {
+		srv, c := newCatalogTestServer(t, catalog)
+		srv.SetRangeSupport(ranges)
+		// the connection is dropped and resumed while reading
+		srv.AddFaults(epo_bbdstest.Fault{Endpoint: epo_bbdstest.EndpointDownload, Drop: true, DropAfter: 5})
+
+		rc, err := c.OpenFileItemStreamContext(ctx, "", EpoPatstatGlobalProductID, 2, file)
+		if !ass.NoError(err) {
+			continue
+		}
+		content, err := io.ReadAll(rc)
+		ass.NoError(err)
+		ass.NoError(rc.Close())
+		ass.Equal(file.FileName, string(content))
+		ass.Equal(2, srv.Requests(epo_bbdstest.EndpointDownload))
+	}
+}
+
+func TestOpenFileItemStreamContextChecksumMismatch(t *testing.T) {
+	ass := assert.New(t)
+	catalog := checksumCatalog()
+	_, c := newCatalogTestServer(t, catalog)
+	file := catalog.Deliveries[1].Files[0]
+	file.FileChecksum = "00000000000000000000000000000000"
+
+	rc, err := c.OpenFileItemStreamContext(context.Background(), "", EpoPatstatGlobalProductID, 2, file)
+	ass.NoError(err)
+	defer rc.Close()
+	content, err := io.ReadAll(rc)
+	ass.ErrorIs(err, ErrChecksumMismatch)
+	// the content is read anyway
+	ass.Equal(file.FileName, string(content))
+}
+
+func TestOpenFileStreamContextErrors(t *testing.T) {
+	ass := assert.New(t)
+	srv, c := newCatalogTestServer(t, testCatalog)
+	ctx := context.Background()
+
+	// unknown file
+	_, err := c.OpenFileStreamContext(ctx, "", EpoPatstatGlobalProductID, 2, 99)
+	var apiErr *APIError
+	ass.True(errors.As(err, &apiErr))
+	ass.ErrorIs(err, ErrCanNotDownload)
+
+	// the connection is dropped more often than the download is attempted
+	faults := make([]epo_bbdstest.Fault, DefaultDownloadAttempts)
+	for i := range faults {
+		faults[i] = epo_bbdstest.Fault{Endpoint: epo_bbdstest.EndpointDownload, Drop: true, DropAfter: 1}
+	}
+	srv.AddFaults(faults...)
+	rc, err := c.OpenFileStreamContext(ctx, "", EpoPatstatGlobalProductID, 2, 21)
+	ass.NoError(err)
+	_, err = io.ReadAll(rc)
+	ass.Error(err)
+	ass.NoError(rc.Close())
+	ass.Equal(DefaultDownloadAttempts, srv.Requests(epo_bbdstest.EndpointDownload)-1)
+
+	// a permanent error is returned when the stream is opened
+	srv.AddFaults(epo_bbdstest.Fault{Endpoint: epo_bbdstest.EndpointDownload, StatusCode: http.StatusForbidden})
+	_, err = c.OpenFileStreamContext(ctx, "", EpoPatstatGlobalProductID, 2, 21)
+	ass.True(errors.As(err, &apiErr))
+	ass.Equal(http.StatusForbidden, apiErr.StatusCode)
+}
@@ -0,0 +1,125 @@
+package epo_bbds
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// fileSizeUnits are the units of the file sizes in the catalog
+var fileSizeUnits = map[string]float64{
+	"":      1,
+	"B":     1,
+	"BYTES": 1,
+	"KB":    1 << 10,
+	"MB":    1 << 20,
+	"GB":    1 << 30,
+	"TB":    1 << 40,
+}
+
+// ParseFileSize parses a file size of the catalog e.g. "1.7 GB" into bytes.
+// A number without a unit are bytes.
+// The units are binary, 1 KB are 1024 bytes.
+func ParseFileSize(size string) (result int64, err error) {
+	_, unit, value, err := splitFileSize(size)
+	if err != nil {
+		return
+	}
+	return int64(value * fileSizeUnits[unit]), nil
+}
+
+// splitFileSize splits a file size of the catalog into the number, the unit and the value of the number
+func splitFileSize(size string) (number, unit string, value float64, err error) {
+	fields := strings.Fields(strings.ToUpper(size))
+	if len(fields) == 0 || len(fields) > 2 {
+		err = fmt.Errorf("could not parse size: %s", size)
+		return
+	}
+	number = fields[0]
+	if len(fields) == 2 {
+		unit = fields[1]
+	} else if i := strings.IndexFunc(number, func(r rune) bool {
+		return (r < '0' || r > '9') && r != '.'
+	}); i >= 0 {
+		// the unit can follow the number without a space e.g. "12MB"
+		number, unit = number[:i], number[i:]
+	}
+	if _, ok := fileSizeUnits[unit]; !ok {
+		err = fmt.Errorf("unknown unit of size: %s", size)
+		return
+	}
+	value, err = strconv.ParseFloat(number, 64)
+	if err != nil || value < 0 {
+		err = fmt.Errorf("could not parse size: %s", size)
+		return
+	}
+	return
+}
+
+// SizeBytes returns the parsed size of the file or 0 if the size can not be parsed
+func (f EpoDocDbFileItem) SizeBytes() int64 {
+	size, err := ParseFileSize(f.FileSize)
+	if err != nil {
+		return 0
+	}
+	return size
+}
+
+// SizeBytes returns the size of all files of the delivery
+func (d EpoProductDelivery) SizeBytes() (size int64) {
+	for _, f := range d.Files {
+		size += f.SizeBytes()
+	}
+	return
+}
+
+// SizeBytes returns the size of all files of all deliveries of the product
+func (r EpoProductDeliveriesResponse) SizeBytes() (size int64) {
+	for _, d := range r.Deliveries {
+		size += d.SizeBytes()
+	}
+	return
+}
+
+// FormatFileSize formats bytes with a binary unit e.g. "1.5 GB"
+func FormatFileSize(bytes int64) string {
+	units := []string{"B", "KB", "MB", "GB", "TB"}
+	value := float64(bytes)
+	i := 0
+	for value >= 1024 && i < len(units)-1 {
+		value /= 1024
+		i++
+	}
+	if i == 0 {
+		return fmt.Sprintf("%d B", bytes)
+	}
+	return fmt.Sprintf("%.1f %s", value, units[i])
+}
+
+// FileSizeMatches checks if the bytes match a rounded file size of the catalog e.g. "1.7 GB".
+// The difference may be up to half of the last digit of the size,
+// and the units may be binary or decimal, since the catalog does not specify them.
+func FileSizeMatches(size string, bytes int64) bool {
+	number, unit, value, err := splitFileSize(size)
+	if err != nil {
+		return false
+	}
+	// half of the last digit
+	tolerance := 0.5
+	if i := strings.Index(number, "."); i >= 0 {
+		tolerance = 0.5 / math.Pow(10, float64(len(number)-i-1))
+	}
+	// exponent of the unit, e.g. 3 for GB
+	exponent := 0.0
+	for factor := fileSizeUnits[unit]; factor > 1; factor /= 1024 {
+		exponent++
+	}
+	for _, base := range []float64{1024, 1000} {
+		factor := math.Pow(base, exponent)
+		if math.Abs(float64(bytes)-value*factor) <= tolerance*factor {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,42 @@
+package epo_bbds
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseFileSize(t *testing.T) {
+	ass := assert.New(t)
+	for size, expected := range map[string]int64{
+		"1.5 GB":  3 << 29,
+		"1 KB":    1024,
+		"12MB":    12 << 20,
+		"512":     512,
+		"3 bytes": 3,
+		"0.5 tb":  1 << 39,
+	} {
+		actual, err := ParseFileSize(size)
+		ass.NoError(err, size)
+		ass.Equal(expected, actual, size)
+	}
+	for _, size := range []string{"", "GB", "1 PB", "1 2 3", "-1 KB"} {
+		_, err := ParseFileSize(size)
+		ass.Error(err, size)
+	}
+}
+
+func TestSizeBytes(t *testing.T) {
+	ass := assert.New(t)
+	ass.Equal(int64(1024), testCatalog.Deliveries[0].Files[0].SizeBytes())
+	ass.Equal(int64(2048), testCatalog.Deliveries[0].SizeBytes())
+	ass.Equal(int64(4096), testCatalog.SizeBytes())
+	ass.Equal(int64(0), EpoDocDbFileItem{FileSize: "unknown"}.SizeBytes())
+}
+
+func TestFormatFileSize(t *testing.T) {
+	ass := assert.New(t)
+	ass.Equal("512 B", FormatFileSize(512))
+	ass.Equal("1.5 KB", FormatFileSize(1536))
+	ass.Equal("1.5 GB", FormatFileSize(3<<29))
+}
@@ -7,6 +7,7 @@ import (
 )
 
 func TestGetAuthorization(t *testing.T) {
+	skipWithoutCredentials(t)
 	ass := assert.New(t)
 	token, err := GetAuthorizationToken()
 	ass.NoError(err)
@@ -5,8 +5,6 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
-	"log/slog"
-	"net/http"
 	"strings"
 	"time"
 )
@@ -46,45 +44,59 @@ type EpoProductDelivery struct {
 
 // GetEpoBddsFileItems returns the links to the front files of the doc db
 func GetEpoBddsFileItems(token string, productID EpoBddsBProductID) (response EpoProductDeliveriesResponse, err error) {
+	return defaultClient().GetEpoBddsFileItems(token, productID)
+}
 
-	// build endpoint url
-	endpoint := fmt.Sprintf(EpoBddsProductEndpoint, string(productID))
+// GetEpoBddsFileItemsContext returns the deliveries and files of a product
+func GetEpoBddsFileItemsContext(ctx context.Context, token string, productID EpoBddsBProductID) (response EpoProductDeliveriesResponse, err error) {
+	return defaultClient().GetEpoBddsFileItemsContext(ctx, token, productID)
+}
 
-	// create new http request with header and payload
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second*20)
+// GetEpoBddsFileItems returns the deliveries and files of a product
+// if the token is empty, the token source of the client is used
+// the request times out after DefaultRequestTimeout
+func (c *Client) GetEpoBddsFileItems(token string, productID EpoBddsBProductID) (response EpoProductDeliveriesResponse, err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultRequestTimeout)
 	defer cancel()
+	return c.GetEpoBddsFileItemsContext(ctx, token, productID)
+}
 
-	req, err := http.NewRequestWithContext(ctx, "GET", endpoint, strings.NewReader(""))
+// GetEpoBddsFileItemsContext returns the deliveries and files of a product
+// if the token is empty, the token source of the client is used
+func (c *Client) GetEpoBddsFileItemsContext(ctx context.Context, token string, productID EpoBddsBProductID) (response EpoProductDeliveriesResponse, err error) {
+
+	// build endpoint url
+	endpoint := fmt.Sprintf(c.productEndpoint, string(productID))
+
+	// create new http request with header and payload
+	req, err := c.newRequest(ctx, "GET", endpoint, strings.NewReader(""))
 	if err != nil {
-		slog.With("err", err).Error("failed to create new request")
 		return
 	}
-	// add header
-	req.Header.Set(AuthHeader, token)
 
 	// send request
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := c.do(req, token)
 	if err != nil {
-		slog.With("err", err).Error("failed to send request")
-		return
-	}
-	// check status code
-	if resp.StatusCode != 200 {
-		err = ErrNo200StatusCode
-		slog.With("err", err).With("statusCode", resp.StatusCode).Error("server responded with non 200 status code")
+		c.logger.With("err", err).Error("failed to send request")
 		return
 	}
 	// close response body
 	defer func(Body io.ReadCloser) {
 		err := Body.Close()
 		if err != nil {
-			slog.With("err", err).Error("failed to close body")
+			c.logger.With("err", err).Error("failed to close body")
 		}
 	}(resp.Body)
+	// check status code
+	if resp.StatusCode != 200 {
+		err = newAPIError(resp, ErrNo200StatusCode)
+		c.logger.With("err", err).With("statusCode", resp.StatusCode).Error("server responded with non 200 status code")
+		return
+	}
 	// parse response
 	err = json.NewDecoder(resp.Body).Decode(&response)
 	if err != nil {
-		slog.With("err", err).Error("failed to parse response")
+		c.logger.With("err", err).Error("failed to parse response")
 		return
 	}
 
@@ -3,12 +3,11 @@ package epo_bbds
 import (
 	"fmt"
 	"github.com/stretchr/testify/assert"
-	"regexp"
-	"strconv"
 	"testing"
 )
 
 func TestGetDocDbFrontFileLinks(t *testing.T) {
+	skipWithoutCredentials(t)
 	ass := assert.New(t)
 	resToken, err := GetAuthorizationToken()
 	ass.NoError(err)
@@ -26,6 +25,7 @@ func TestGetDocDbFrontFileLinks(t *testing.T) {
 }
 
 func TestGetDocDbBackFileLinks(t *testing.T) {
+	skipWithoutCredentials(t)
 	ass := assert.New(t)
 	resToken, err := GetAuthorizationToken()
 	ass.NoError(err)
@@ -43,6 +43,7 @@ func TestGetDocDbBackFileLinks(t *testing.T) {
 }
 
 func TestGetPatstatGlobalFileLinks(t *testing.T) {
+	skipWithoutCredentials(t)
 	ass := assert.New(t)
 	resToken, err := GetAuthorizationToken()
 	ass.NoError(err)
@@ -64,7 +65,7 @@ func printFiles(res EpoProductDeliveriesResponse) {
 	for _, d := range res.Deliveries {
 		fmt.Printf("%d \t %s\n", d.DeliveryID, d.DeliveryName)
 		for _, f := range d.Files {
-			size, err := parseFileSize(f.FileSize)
+			size, err := ParseFileSize(f.FileSize)
 			if err != nil {
 				fmt.Println(err)
 				break
@@ -76,32 +77,3 @@ func printFiles(res EpoProductDeliveriesResponse) {
 	}
 	fmt.Println("\n\nTotal file size in GB:", float64(totalFileSize)/1024/1024/1024)
 }
-
-func parseFileSize(size string) (result int64, err error) {
-	// e.g.  1.7 GB
-	// extract number and unit
-	re := regexp.MustCompile(`(\d+\.?\d*)\s*(\w+)`)
-	matches := re.FindStringSubmatch(size)
-	if len(matches) != 3 {
-		return 0, fmt.Errorf("could not parse size: %s", size)
-	}
-	// number
-	number, err := strconv.ParseFloat(matches[1], 64)
-	if err != nil {
-		return 0, err
-	}
-	// unit
-	unit := matches[2]
-
-	switch unit {
-	case "GB":
-		result = int64(number * 1024 * 1024 * 1024)
-		return
-	case "MB":
-		result = int64(number * 1024 * 1024)
-		return
-	case "KB":
-		result = int64(number * 1024)
-	}
-	return
-}
@@ -4,10 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"io"
-	"log/slog"
-	"net/http"
 	"strings"
-	"time"
 )
 
 // EpoProductsEndpoint is the endpoint for the products
@@ -22,41 +19,55 @@ type EpoProductItem struct {
 
 // GetProducts returns the products
 func GetProducts(token string) (response []EpoProductItem, err error) {
-	// create new http request with header and payload
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second*20)
+	return defaultClient().GetProducts(token)
+}
+
+// GetProductsContext returns the products
+func GetProductsContext(ctx context.Context, token string) (response []EpoProductItem, err error) {
+	return defaultClient().GetProductsContext(ctx, token)
+}
+
+// GetProducts returns the products
+// if the token is empty, the token source of the client is used
+// the request times out after DefaultRequestTimeout
+func (c *Client) GetProducts(token string) (response []EpoProductItem, err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultRequestTimeout)
 	defer cancel()
+	return c.GetProductsContext(ctx, token)
+}
 
-	req, err := http.NewRequestWithContext(ctx, "GET", EpoProductsEndpoint, strings.NewReader(""))
+// GetProductsContext returns the products
+// if the token is empty, the token source of the client is used
+func (c *Client) GetProductsContext(ctx context.Context, token string) (response []EpoProductItem, err error) {
+	// create new http request with header and payload
+	req, err := c.newRequest(ctx, "GET", c.productsEndpoint, strings.NewReader(""))
 	if err != nil {
-		slog.With("err", err).Error("failed to create new request")
 		return
 	}
-	// add header
-	req.Header.Set(AuthHeader, token)
 
 	// send request
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := c.do(req, token)
 	if err != nil {
-		slog.With("err", err).Error("failed to send request")
-		return
-	}
-	// check status code
-	if resp.StatusCode != 200 {
-		err = ErrNo200StatusCode
-		slog.With("err", err).Error("server responded with non 200 status code")
+		c.logger.With("err", err).Error("failed to send request")
 		return
 	}
 	// close response body
 	defer func(Body io.ReadCloser) {
 		err := Body.Close()
 		if err != nil {
-			slog.With("err", err).Error("failed to close body")
+			c.logger.With("err", err).Error("failed to close body")
 		}
 	}(resp.Body)
+	// check status code
+	if resp.StatusCode != 200 {
+		err = newAPIError(resp, ErrNo200StatusCode)
+		c.logger.With("err", err).With("statusCode", resp.StatusCode).Error("server responded with non 200 status code")
+		return
+	}
 	// parse response
 	err = json.NewDecoder(resp.Body).Decode(&response)
 	if err != nil {
-		slog.With("err", err).Error("failed to parse response")
+		c.logger.With("err", err).Error("failed to parse response")
 		return
 	}
 	return
@@ -7,6 +7,7 @@ import (
 )
 
 func TestGetProducts(t *testing.T) {
+	skipWithoutCredentials(t)
 	ass := assert.New(t)
 	resToken, err := GetAuthorizationToken()
 	ass.NoError(err)
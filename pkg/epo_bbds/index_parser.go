@@ -3,61 +3,136 @@ package epo_bbds
 import (
 	"archive/zip"
 	"bufio"
+	"bytes"
 	"encoding/xml"
 	"fmt"
 	"io"
 	"log"
 	"log/slog"
 	"os"
+	"path"
 	"path/filepath"
 	"regexp"
-	"strconv"
 	"strings"
 	"time"
 )
 
-// index struct
-
+// DocdbPackageIndex is the index (index.xml) of a DocDB bulk package,
+// it lists the files of the Root/DOC directory with their sizes and document ranges
 type DocdbPackageIndex struct {
-	XMLName          xml.Name `xml:"docdb-package-index"`
-	Text             string   `xml:",chardata"`
-	ID               string   `xml:"id,attr"`
-	DateProduced     string   `xml:"date-produced,attr"`
-	DtdVersion       string   `xml:"dtd-version,attr"`
-	File             string   `xml:"file,attr"`
-	ProducedBy       string   `xml:"produced-by,attr"`
-	VolumeID         string   `xml:"volume-id,attr"`
-	DocdbPackageFile []struct {
-		Text         string `xml:",chardata"`
-		ID           string `xml:"id,attr"`
-		Format       string `xml:"format,attr"`
-		Size         string `xml:"size,attr"`
-		Filename     string `xml:"filename"`
-		FileLocation struct {
-			Text     string `xml:",chardata"`
-			Relative string `xml:"relative,attr"`
-		} `xml:"file-location"`
-		DocdbDocRange struct {
-			Text                 string `xml:",chardata"`
-			Country              string `xml:"country,attr"`
-			DocdbFirstDocInRange struct {
-				Text      string `xml:",chardata"`
-				Country   string `xml:"country"`
-				DocNumber string `xml:"doc-number"`
-				Kind      string `xml:"kind"`
-				Date      string `xml:"date"`
-			} `xml:"docdb-first-doc-in-range"`
-			DocdbLastDocInRange struct {
-				Text      string `xml:",chardata"`
-				Country   string `xml:"country"`
-				DocNumber string `xml:"doc-number"`
-				Kind      string `xml:"kind"`
-				Date      string `xml:"date"`
-			} `xml:"docdb-last-doc-in-range"`
-		} `xml:"docdb-doc-range"`
-	} `xml:"docdb-package-file"`
+	XMLName          xml.Name           `xml:"docdb-package-index"`
+	ID               string             `xml:"id,attr"`
+	DateProduced     IndexDate          `xml:"date-produced,attr"`
+	DtdVersion       string             `xml:"dtd-version,attr"`
+	File             string             `xml:"file,attr"`
+	ProducedBy       string             `xml:"produced-by,attr"`
+	VolumeID         string             `xml:"volume-id,attr"`
+	DocdbPackageFile []DocdbPackageFile `xml:"docdb-package-file"`
+}
+
+// DocdbPackageFile is a file of the package index
+type DocdbPackageFile struct {
+	ID            string            `xml:"id,attr"`
+	Format        string            `xml:"format,attr"`
+	Size          int64             `xml:"size,attr"` // bytes
+	Filename      string            `xml:"filename"`
+	FileLocation  IndexFileLocation `xml:"file-location"`
+	DocdbDocRange DocdbDocRange     `xml:"docdb-doc-range"`
+}
+
+// Path returns the path of the file in the package, e.g. Root/DOC/DOCDB-202324-Amend-PubDate20230609AndBefore-AP-0001.zip
+func (f DocdbPackageFile) Path() string {
+	return path.Join(f.FileLocation.Dir(), f.Filename)
+}
+
+// IndexFileLocation is the directory of a file relative to the package
+type IndexFileLocation struct {
+	Relative string `xml:"relative,attr"` // e.g. Root/DOC or Root\DOC\
+}
+
+// Dir returns the directory with forward slashes and without a trailing slash, e.g. Root/DOC
+func (l IndexFileLocation) Dir() string {
+	return strings.Trim(strings.ReplaceAll(l.Relative, "\\", "/"), "/")
+}
+
+// DocdbDocRange is the range of the documents of a file
+type DocdbDocRange struct {
+	Country              string          `xml:"country,attr"`
+	DocdbFirstDocInRange DocdbDocInRange `xml:"docdb-first-doc-in-range"`
+	DocdbLastDocInRange  DocdbDocInRange `xml:"docdb-last-doc-in-range"`
+}
+
+// DocdbDocInRange is the first or last document of a range
+type DocdbDocInRange struct {
+	Country   string    `xml:"country"`
+	DocNumber string    `xml:"doc-number"`
+	Kind      string    `xml:"kind"`
+	Date      IndexDate `xml:"date"` // publication date
+}
+
+// PublicationNumber returns the publication number with the country and the kind, e.g. AP1206A
+func (d DocdbDocInRange) PublicationNumber() string {
+	return d.Country + d.DocNumber + d.Kind
+}
+
+// IndexDate is a date of the package index in the format YYYYMMDD
+type IndexDate struct {
+	time.Time
+}
+
+// parse sets the date, an empty value is the zero time
+func (d *IndexDate) parse(value string) (err error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		d.Time = time.Time{}
+		return
+	}
+	d.Time, err = time.Parse("20060102", value)
+	return
+}
+
+// UnmarshalXML parses the date of an element
+func (d *IndexDate) UnmarshalXML(dec *xml.Decoder, start xml.StartElement) (err error) {
+	var value string
+	err = dec.DecodeElement(&value, &start)
+	if err != nil {
+		return
+	}
+	return d.parse(value)
+}
+
+// UnmarshalXMLAttr parses the date of an attribute
+func (d *IndexDate) UnmarshalXMLAttr(attr xml.Attr) error {
+	return d.parse(attr.Value)
+}
+
+// String returns the date in the format YYYYMMDD
+func (d IndexDate) String() string {
+	if d.IsZero() {
+		return ""
+	}
+	return d.Format("20060102")
+}
+
+// TotalSize returns the sum of the sizes of the files
+func (idx DocdbPackageIndex) TotalSize() (size int64) {
+	for _, f := range idx.DocdbPackageFile {
+		size += f.Size
+	}
+	return
+}
+
+// FilesByCountry returns the files whose document range is of a country, e.g. AP
+func (idx DocdbPackageIndex) FilesByCountry(country string) (files []DocdbPackageFile) {
+	for _, f := range idx.DocdbPackageFile {
+		if strings.EqualFold(f.DocdbDocRange.Country, country) {
+			files = append(files, f)
+		}
+	}
+	return
 }
 
+// ParseIndexXML parses the index file of a package
 func ParseIndexXML(filename string) (indexObject DocdbPackageIndex, err error) {
 	// Read the XML file
 	data, err := os.ReadFile(filename)
@@ -65,7 +140,12 @@ func ParseIndexXML(filename string) (indexObject DocdbPackageIndex, err error) {
 		slog.With("err", err).Error("failed to read file")
 		return
 	}
-	err = xml.Unmarshal(data, &indexObject)
+	return ParseIndex(bytes.NewReader(data))
+}
+
+// ParseIndex parses a package index, e.g. the index.xml of a bulk zip
+func ParseIndex(r io.Reader) (indexObject DocdbPackageIndex, err error) {
+	err = xml.NewDecoder(r).Decode(&indexObject)
 	if err != nil {
 		slog.With("err", err).Error("failed to unmarshal xml")
 		return
@@ -86,19 +166,12 @@ func readCsv(filePath string) ([][]string, error) {
 		}
 	}(file)
 
-	scanner := bufio.NewScanner(file)
+	// the header record has less columns than the rest
+	reader := newStatisticsCSVReader(file)
 
 	//two dimensional slice
-	var data [][]string
-
-	for scanner.Scan() {
-		row := scanner.Text()
-		fields := strings.Split(row, ",")
-
-		data = append(data, fields)
-	}
-
-	if err := scanner.Err(); err != nil {
+	data, err := reader.ReadAll()
+	if err != nil {
 		return nil, fmt.Errorf("couldn't read csv file: %s", err)
 	}
 
@@ -149,60 +222,11 @@ func readCsv(filePath string) ([][]string, error) {
 	}
 */
 func readCsvToStruct(filePath string) ([]StatsStruct, error) {
-
-	file, err := os.Open(filePath)
+	stats, err := ParseStatisticsFile(filePath)
 	if err != nil {
-		return nil, fmt.Errorf("couldn't open file, %s", err)
-	}
-	defer func(file *os.File) {
-		err := file.Close()
-		if err != nil {
-
-		}
-	}(file)
-	// bufio bc csv reader can't handle the first line being different
-	scanner := bufio.NewScanner(file)
-
-	//skip first line (different amount of columns than rest)
-	if !scanner.Scan() {
-		if err := scanner.Err(); err != nil {
-			return nil, fmt.Errorf("couldn't read the first line, %s", err)
-		}
-	}
-
-	var data []StatsStruct
-
-	for scanner.Scan() {
-		row := scanner.Text()
-		fields := strings.Split(row, ",")
-		//string conversion
-		nrOfPNStatus, _ := strconv.Atoi(fields[1])
-		firstPN, _ := strconv.Atoi(fields[4])
-		lastPN, _ := strconv.Atoi(fields[5])
-		nrOfPN, _ := strconv.Atoi(fields[8])
-		firstPubDate, _ := time.Parse("20060102", fields[6])
-		lastPubDate, _ := time.Parse("20060102", fields[7])
-
-		csvRow := StatsStruct{
-			Status:       fields[0],
-			NrOfPNStatus: nrOfPNStatus,
-			CC:           fields[2],
-			KC:           fields[3],
-			FirstPN:      firstPN,
-			LastPN:       lastPN,
-			FirstPubDate: firstPubDate,
-			LastPubDate:  lastPubDate,
-			NrOfPN:       nrOfPN,
-		}
-
-		data = append(data, csvRow)
-	}
-
-	if err := scanner.Err(); err != nil {
-		return nil, fmt.Errorf("couldn't read CSV file, %s", err)
+		return nil, err
 	}
-
-	return data, nil
+	return stats.Rows, nil
 }
 
 type ExchangeDocuments struct {
@@ -288,32 +312,6 @@ func CountExchangeDocuments(filename string) (int, error) {
 	return count, nil
 }
 
-/*
-statistics csv:
-DOCA095,202324,DOCDB-202324,616732,478
-Status,NrOfPNStatus,CC,KC,FirstPN,LastPN,FirstPubDate,LastPubDate,NrOfPN
-A,616732,AP,A,36,4072,19881206,20170316,24
-A,616732,AP,A0,8500014,2017009807,19850801,20170331,63
-A,616732,AR,A1,000008,248143,19730919,20230419,863
-A,616732,AR,A2,023762,240811,19910228,20230426,90
-A,616732,AR,A4,067548,123822,20091014,20230118,2
-A,616732,AT,A,A4386,A13742003,19761215,20060115,32
-...
-
-*/
-
-type StatsStruct struct {
-	Status       string
-	NrOfPNStatus int
-	CC           string
-	KC           string
-	FirstPN      int
-	LastPN       int
-	FirstPubDate time.Time
-	LastPubDate  time.Time
-	NrOfPN       int
-}
-
 // CountZIPs unpacks zip file and counts DOCDB zip files within
 func CountZIPs() {
 	//
@@ -6,6 +6,7 @@ import (
 	"reflect"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestCountExchangeDocuments(t *testing.T) {
@@ -24,16 +25,39 @@ func TestParseIndexXML(t *testing.T) {
 	ass := assert.New(t)
 
 	response, err := ParseIndexXML("test-data/index.xml")
-
-	ass.NoError(err)
-	if err != nil {
-		t.Error(err)
+	if !ass.NoError(err) {
+		return
 	}
-	// ass.Equal("document-id", response.DocdbPackageFile)
-
-	fmt.Printf("ID: %s\n", response.ID)
-	fmt.Printf("Name: %s\n", response.DocdbPackageFile)
+	ass.Equal("docdb_xml_202324_Amend_001", response.ID)
+	ass.Equal("20230613", response.DateProduced.String())
+	ass.Equal(time.Date(2023, 6, 13, 0, 0, 0, 0, time.UTC), response.DateProduced.Time)
+	ass.Len(response.DocdbPackageFile, 2)
+	ass.Equal(int64(6144), response.TotalSize())
+
+	f := response.DocdbPackageFile[0]
+	ass.Equal(int64(4096), f.Size)
+	ass.Equal("Root/DOC/DOCDB-202324-Amend-PubDate20230609AndBefore-AP-0001.zip", f.Path())
+	ass.Equal("AP", f.DocdbDocRange.Country)
+	ass.Equal("AP1206A", f.DocdbDocRange.DocdbFirstDocInRange.PublicationNumber())
+	ass.Equal(time.Date(1988, 12, 6, 0, 0, 0, 0, time.UTC), f.DocdbDocRange.DocdbFirstDocInRange.Date.Time)
+	ass.Equal("AP2017009807A0", f.DocdbDocRange.DocdbLastDocInRange.PublicationNumber())
+	ass.Equal("000008", response.FilesByCountry("ar")[0].DocdbDocRange.DocdbFirstDocInRange.DocNumber)
+	ass.Empty(response.FilesByCountry("DE"))
+}
 
+func TestParseIndex(t *testing.T) {
+	ass := assert.New(t)
+	idx, err := ParseIndex(strings.NewReader(`<docdb-package-index><docdb-package-file size=""><filename>a.zip</filename></docdb-package-file></docdb-package-index>`))
+	ass.NoError(err)
+	ass.True(idx.DateProduced.IsZero())
+	ass.Equal("", idx.DateProduced.String())
+	ass.Equal(int64(0), idx.DocdbPackageFile[0].Size)
+	ass.Equal("a.zip", idx.DocdbPackageFile[0].Path())
+
+	_, err = ParseIndex(strings.NewReader(`<docdb-package-index date-produced="13.06.2023"/>`))
+	ass.Error(err)
+	_, err = ParseIndex(strings.NewReader(`<docdb-package-index><docdb-package-file size="1 KB"/></docdb-package-index>`))
+	ass.Error(err)
 }
 
 // funktionieren nicht weil datei nicht gelesen werden kann? (funktioniert aber mit beiden Dateien in python)
@@ -0,0 +1,217 @@
+package epo_bbds
+
+import (
+	"archive/zip"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"path"
+	"sort"
+	"strings"
+)
+
+// ErrIndexMismatch is returned if the files of a bulk zip do not match its package index
+var ErrIndexMismatch = errors.New("bulk zip does not match the package index")
+
+// ErrNoPackageIndex is returned if a bulk zip has no index.xml
+var ErrNoPackageIndex = errors.New("no package index")
+
+// IndexMismatchError is returned if the files of a bulk zip do not match its package index.
+// It matches ErrIndexMismatch with errors.Is.
+type IndexMismatchError struct {
+	Report IndexValidationReport
+}
+
+// Error returns the error message
+func (e *IndexMismatchError) Error() string {
+	return fmt.Sprintf("%s: %s has %d missing, %d extra and %d wrong-size files",
+		ErrIndexMismatch, e.Report.FilePath, len(e.Report.Missing), len(e.Report.Extra), len(e.Report.WrongSize))
+}
+
+// Is reports if the target is ErrIndexMismatch
+func (e *IndexMismatchError) Is(target error) bool {
+	return target == ErrIndexMismatch
+}
+
+// IndexSizeMismatch is a file of the bulk zip whose size does not match the package index
+type IndexSizeMismatch struct {
+	File       DocdbPackageFile
+	ActualSize int64 // uncompressed size in the bulk zip
+}
+
+// IndexValidationReport compares the Root/DOC files of a bulk zip with its package index
+type IndexValidationReport struct {
+	FilePath   string
+	ValidFiles int                 // files that match the index
+	Missing    []DocdbPackageFile  // files of the index that are not in the bulk zip
+	Extra      []string            // files of the bulk zip that are not in the index, ordered by path
+	WrongSize  []IndexSizeMismatch // files whose size does not match the index
+}
+
+// Valid reports if the bulk zip matches the package index
+func (r IndexValidationReport) Valid() bool {
+	return len(r.Missing) == 0 && len(r.Extra) == 0 && len(r.WrongSize) == 0
+}
+
+// Err returns an IndexMismatchError if the bulk zip does not match the package index
+func (r IndexValidationReport) Err() error {
+	if r.Valid() {
+		return nil
+	}
+	return &IndexMismatchError{Report: r}
+}
+
+// packagePath returns the path of an entry relative to the package, e.g. Root/DOC/file.zip
+// for docdb_xml_202331_Amend_001/Root/DOC/file.zip, or an empty string if it is not in Root
+func packagePath(name string) string {
+	name = strings.ReplaceAll(name, "\\", "/")
+	lower := strings.ToLower(name)
+	if strings.HasPrefix(lower, "root/") {
+		return name
+	}
+	if i := strings.Index(lower, "/root/"); i >= 0 {
+		return name[i+1:]
+	}
+	return ""
+}
+
+// ReadBulkZipIndex reads the package index (index.xml) of a bulk zip
+func ReadBulkZipIndex(filePath string) (idx DocdbPackageIndex, err error) {
+	reader, err := zip.OpenReader(filePath)
+	if err != nil {
+		return
+	}
+	defer reader.Close()
+	return readZipIndex(&reader.Reader)
+}
+
+// readZipIndex reads the index.xml that is closest to the root of the zip
+func readZipIndex(reader *zip.Reader) (idx DocdbPackageIndex, err error) {
+	var index *zip.File
+	for _, f := range reader.File {
+		if !strings.EqualFold(path.Base(f.Name), "index.xml") {
+			continue
+		}
+		if index == nil || strings.Count(f.Name, "/") < strings.Count(index.Name, "/") {
+			index = f
+		}
+	}
+	if index == nil {
+		err = ErrNoPackageIndex
+		return
+	}
+	r, err := index.Open()
+	if err != nil {
+		return
+	}
+	defer r.Close()
+	return ParseIndex(r)
+}
+
+// ValidateBulkZipFile compares the Root/DOC files of a bulk zip with the package index of the bulk zip.
+// If the files do not match the index, the error is an IndexMismatchError.
+func ValidateBulkZipFile(filePath string) (report IndexValidationReport, err error) {
+	logger := slog.With("filePath", filePath)
+	reader, err := zip.OpenReader(filePath)
+	if err != nil {
+		logger.With("err", err).Error("failed to open bulk zip file")
+		return
+	}
+	defer reader.Close()
+
+	idx, err := readZipIndex(&reader.Reader)
+	if err != nil {
+		logger.With("err", err).Error("failed to read package index")
+		return
+	}
+	report = idx.validate(filePath, reader.File)
+	return report, report.Err()
+}
+
+// ValidateBulkZipFile compares the Root/DOC files of a bulk zip with the index.
+// If the files do not match the index, the error is an IndexMismatchError.
+func (idx DocdbPackageIndex) ValidateBulkZipFile(filePath string) (report IndexValidationReport, err error) {
+	reader, err := zip.OpenReader(filePath)
+	if err != nil {
+		slog.With("err", err, "filePath", filePath).Error("failed to open bulk zip file")
+		return
+	}
+	defer reader.Close()
+	report = idx.validate(filePath, reader.File)
+	return report, report.Err()
+}
+
+// validate compares the files in the directories of the index, e.g. Root/DOC, with the files of the index.
+// The paths are compared case-insensitively.
+func (idx DocdbPackageIndex) validate(filePath string, files []*zip.File) (report IndexValidationReport) {
+	report.FilePath = filePath
+	dirs := map[string]struct{}{"root/doc": {}}
+	for _, f := range idx.DocdbPackageFile {
+		dirs[strings.ToLower(f.FileLocation.Dir())] = struct{}{}
+	}
+
+	entries := map[string]*zip.File{}
+	for _, f := range files {
+		if f.FileInfo().IsDir() {
+			continue
+		}
+		p := packagePath(f.Name)
+		if p == "" {
+			continue
+		}
+		if _, ok := dirs[strings.ToLower(path.Dir(p))]; ok {
+			entries[strings.ToLower(p)] = f
+		}
+	}
+
+	for _, f := range idx.DocdbPackageFile {
+		key := strings.ToLower(f.Path())
+		entry, ok := entries[key]
+		if !ok {
+			report.Missing = append(report.Missing, f)
+			continue
+		}
+		delete(entries, key)
+		if size := int64(entry.UncompressedSize64); size != f.Size {
+			report.WrongSize = append(report.WrongSize, IndexSizeMismatch{File: f, ActualSize: size})
+			continue
+		}
+		report.ValidFiles++
+	}
+	for _, entry := range entries {
+		report.Extra = append(report.Extra, packagePath(entry.Name))
+	}
+	sort.Strings(report.Extra)
+	return
+}
+
+// ValidateBulkFilesHook returns a hook that validates the zip files of a delivery with their package index,
+// so that a corrupt delivery is not processed by the following hooks.
+// Zip files without a package index are skipped.
+// The files must be downloaded to the local filesystem, otherwise ErrLocalFilesRequired is returned.
+func ValidateBulkFilesHook() DeliveryHook {
+	return func(ctx context.Context, event DeliveryEvent) (err error) {
+		if event.Storage != nil {
+			// the index is read from the central directory, which a stream does not provide
+			return fmt.Errorf("failed to validate delivery %s: %w", event.Delivery.DeliveryName, ErrLocalFilesRequired)
+		}
+		for _, filePath := range event.FilePaths() {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			if !strings.HasSuffix(strings.ToLower(filePath), ".zip") {
+				continue
+			}
+			_, err = ValidateBulkZipFile(filePath)
+			if errors.Is(err, ErrNoPackageIndex) {
+				slog.With("filePath", filePath).Debug("no package index - skipping validation")
+				continue
+			}
+			if err != nil {
+				return fmt.Errorf("failed to validate %s: %w", filePath, err)
+			}
+		}
+		return nil
+	}
+}
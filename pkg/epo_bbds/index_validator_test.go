@@ -0,0 +1,132 @@
+package epo_bbds
+
+import (
+	"archive/zip"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// writeBulkZip writes a bulk zip with the files in order
+func writeBulkZip(t *testing.T, filePath string, files map[string]int, order ...string) {
+	f, err := os.Create(filePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	zw := zip.NewWriter(f)
+	for _, name := range order {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		content := []byte(strings.Repeat("x", files[name]))
+		if name == "docdb_xml_202324_Amend_001/Root/index.xml" {
+			content, err = os.ReadFile("test-data/index.xml")
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		if _, err = w.Write(content); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+const (
+	testIndexPath = "docdb_xml_202324_Amend_001/Root/index.xml"
+	testDocAP     = "docdb_xml_202324_Amend_001/Root/DOC/DOCDB-202324-Amend-PubDate20230609AndBefore-AP-0001.zip"
+	testDocAR     = "docdb_xml_202324_Amend_001/Root/DOC/DOCDB-202324-Amend-PubDate20230609AndBefore-AR-0001.zip"
+)
+
+func TestValidateBulkZipFile(t *testing.T) {
+	ass := assert.New(t)
+	filePath := filepath.Join(t.TempDir(), "docdb_xml_202324_Amend_001.zip")
+
+	writeBulkZip(t, filePath, map[string]int{testDocAP: 4096, testDocAR: 2048, "docdb_xml_202324_Amend_001/Root/DTDS/docdb.dtd": 10},
+		testIndexPath, testDocAP, testDocAR, "docdb_xml_202324_Amend_001/Root/DTDS/docdb.dtd")
+	report, err := ValidateBulkZipFile(filePath)
+	ass.NoError(err)
+	ass.True(report.Valid())
+	ass.Equal(2, report.ValidFiles)
+
+	// a missing, an extra and a truncated file
+	extra := "docdb_xml_202324_Amend_001/Root/DOC/DOCDB-202324-Amend-PubDate20230609AndBefore-AT-0001.zip"
+	writeBulkZip(t, filePath, map[string]int{testDocAP: 4000, extra: 1},
+		testIndexPath, testDocAP, extra)
+	report, err = ValidateBulkZipFile(filePath)
+	ass.ErrorIs(err, ErrIndexMismatch)
+	ass.EqualError(err, ErrIndexMismatch.Error()+": "+filePath+" has 1 missing, 1 extra and 1 wrong-size files")
+	ass.False(report.Valid())
+	ass.Equal(0, report.ValidFiles)
+	if ass.Len(report.Missing, 1) {
+		ass.Equal("AR", report.Missing[0].DocdbDocRange.Country)
+	}
+	ass.Equal([]string{"Root/DOC/DOCDB-202324-Amend-PubDate20230609AndBefore-AT-0001.zip"}, report.Extra)
+	if ass.Len(report.WrongSize, 1) {
+		ass.Equal(int64(4096), report.WrongSize[0].File.Size)
+		ass.Equal(int64(4000), report.WrongSize[0].ActualSize)
+	}
+
+	// without an index
+	writeBulkZip(t, filePath, map[string]int{testDocAP: 4096}, testDocAP)
+	_, err = ValidateBulkZipFile(filePath)
+	ass.ErrorIs(err, ErrNoPackageIndex)
+
+	_, err = ValidateBulkZipFile(filepath.Join(t.TempDir(), "missing.zip"))
+	ass.Error(err)
+}
+
+func TestDocdbPackageIndexValidateBulkZipFile(t *testing.T) {
+	ass := assert.New(t)
+	idx, err := ParseIndexXML("test-data/index.xml")
+	if !ass.NoError(err) {
+		return
+	}
+	// the paths of the bulk zip can be without the volume directory
+	filePath := filepath.Join(t.TempDir(), "docdb_xml_202324_Amend_001.zip")
+	writeBulkZip(t, filePath, map[string]int{"Root/DOC/DOCDB-202324-Amend-PubDate20230609AndBefore-AP-0001.zip": 4096},
+		"Root/DOC/DOCDB-202324-Amend-PubDate20230609AndBefore-AP-0001.zip")
+
+	report, err := idx.ValidateBulkZipFile(filePath)
+	ass.ErrorIs(err, ErrIndexMismatch)
+	ass.Equal(1, report.ValidFiles)
+	ass.Len(report.Missing, 1)
+	ass.Empty(report.Extra)
+}
+
+func TestPackagePath(t *testing.T) {
+	ass := assert.New(t)
+	ass.Equal("Root/DOC/a.zip", packagePath("docdb_xml_202324_Amend_001/Root/DOC/a.zip"))
+	ass.Equal("Root/DOC/a.zip", packagePath("Root/DOC/a.zip"))
+	ass.Equal("root/doc/a.zip", packagePath(`vol\root\doc\a.zip`))
+	ass.Equal("", packagePath("vol/a.zip"))
+}
+
+func TestValidateBulkFilesHook(t *testing.T) {
+	ass := assert.New(t)
+	dir := t.TempDir()
+	valid := filepath.Join(dir, "valid.zip")
+	writeBulkZip(t, valid, map[string]int{testDocAP: 4096, testDocAR: 2048}, testIndexPath, testDocAP, testDocAR)
+	noIndex := filepath.Join(dir, "no-index.zip")
+	writeBulkZip(t, noIndex, map[string]int{"a.xml": 1}, "a.xml")
+
+	event := DeliveryEvent{Results: []DownloadResult{
+		{Job: DownloadJob{DestinationPath: dir, File: EpoDocDbFileItem{FileName: "valid.zip"}}},
+		{Job: DownloadJob{DestinationPath: dir, File: EpoDocDbFileItem{FileName: "no-index.zip"}}},
+	}}
+	hook := ValidateBulkFilesHook()
+	ass.NoError(hook(context.Background(), event))
+
+	writeBulkZip(t, valid, map[string]int{testDocAP: 4096}, testIndexPath, testDocAP)
+	err := hook(context.Background(), event)
+	ass.ErrorIs(err, ErrIndexMismatch)
+	ass.ErrorContains(err, "valid.zip")
+}
@@ -0,0 +1,56 @@
+package epo_bbds
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// ErrDeliveryDirCollision is returned if two deliveries of a catalog have the same directory in the mirror layout
+var ErrDeliveryDirCollision = errors.New("deliveries share a directory")
+
+// Layout returns the directory of the files of a delivery relative to the destination path
+type Layout func(productID EpoBddsBProductID, delivery EpoProductDelivery) string
+
+// FlatLayout saves the files of all deliveries directly in the destination path
+func FlatLayout(productID EpoBddsBProductID, delivery EpoProductDelivery) string {
+	return ""
+}
+
+// MirrorLayout saves the files in the directory <product>/<deliveryName>
+func MirrorLayout(productID EpoBddsBProductID, delivery EpoProductDelivery) string {
+	return filepath.Join(string(productID), DeliveryDirName(delivery))
+}
+
+// invalidPathChars are replaced in directory names
+var invalidPathChars = strings.NewReplacer(
+	"/", "_", "\\", "_", ":", "_", "*", "_", "?", "_",
+	"\"", "_", "<", "_", ">", "_", "|", "_",
+)
+
+// DeliveryDirName returns the name of the directory of a delivery in the mirror layout,
+// characters that are not allowed in file names are replaced by an underscore
+func DeliveryDirName(delivery EpoProductDelivery) string {
+	name := strings.Trim(invalidPathChars.Replace(delivery.DeliveryName), " .")
+	if name == "" {
+		return "delivery_" + strconv.Itoa(delivery.DeliveryID)
+	}
+	return name
+}
+
+// deliveryDirs maps the directory names of the mirror layout to the deliveries.
+// It returns an error that matches ErrDeliveryDirCollision if two deliveries have the same directory name,
+// because their files would be mixed in one directory.
+func deliveryDirs(deliveries []EpoProductDelivery) (dirs map[string]EpoProductDelivery, err error) {
+	dirs = map[string]EpoProductDelivery{}
+	for _, d := range deliveries {
+		name := DeliveryDirName(d)
+		if other, ok := dirs[name]; ok && other.DeliveryID != d.DeliveryID {
+			return nil, fmt.Errorf("%w: %q of the deliveries %d and %d", ErrDeliveryDirCollision, name, other.DeliveryID, d.DeliveryID)
+		}
+		dirs[name] = d
+	}
+	return
+}
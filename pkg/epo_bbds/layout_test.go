@@ -0,0 +1,16 @@
+package epo_bbds
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMirrorLayout(t *testing.T) {
+	ass := assert.New(t)
+	d := EpoProductDelivery{DeliveryID: 7, DeliveryName: "DOCDB 2024/02: Front files"}
+	ass.Equal("", FlatLayout(EpoDocDBFrontFilesProductID, d))
+	ass.Equal(filepath.Join(string(EpoDocDBFrontFilesProductID), "DOCDB 2024_02_ Front files"), MirrorLayout(EpoDocDBFrontFilesProductID, d))
+	ass.Equal("delivery_7", DeliveryDirName(EpoProductDelivery{DeliveryID: 7, DeliveryName: " .. "}))
+}
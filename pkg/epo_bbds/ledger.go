@@ -0,0 +1,329 @@
+package epo_bbds
+
+import (
+	"errors"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/logger"
+)
+
+// LedgerFileStatus is the download status of a file in the ledger
+type LedgerFileStatus string
+
+const (
+	// LedgerFileSeen is the status of a file that was seen in the catalog but not downloaded yet
+	LedgerFileSeen LedgerFileStatus = "seen"
+	// LedgerFileDownloaded is the status of a file that was downloaded and verified
+	LedgerFileDownloaded LedgerFileStatus = "downloaded"
+	// LedgerFileFailed is the status of a file whose last download failed
+	LedgerFileFailed LedgerFileStatus = "failed"
+)
+
+// LedgerProduct is a product that was seen in the catalog
+type LedgerProduct struct {
+	ProductID   string `gorm:"primaryKey"`
+	Name        string
+	Description string
+	FirstSeenAt time.Time
+	LastSeenAt  time.Time
+}
+
+// LedgerDelivery is a delivery that was seen in the catalog
+type LedgerDelivery struct {
+	DeliveryID                  int    `gorm:"primaryKey;autoIncrement:false"`
+	ProductID                   string `gorm:"index"`
+	DeliveryName                string
+	DeliveryPublicationDatetime time.Time
+	DeliveryExpiryDatetime      *time.Time
+	FirstSeenAt                 time.Time `gorm:"index"`
+	LastSeenAt                  time.Time
+}
+
+// LedgerFile is a file that was seen in the catalog including its download status
+type LedgerFile struct {
+	DeliveryID              int    `gorm:"primaryKey;autoIncrement:false"`
+	FileID                  int    `gorm:"primaryKey;autoIncrement:false"`
+	ProductID               string `gorm:"index"`
+	FileName                string
+	FileSize                string
+	FileChecksum            string
+	ItemPublicationDatetime time.Time
+	Status                  LedgerFileStatus `gorm:"index"`
+	LocalPath               string
+	Error                   string
+	DownloadedAt            *time.Time
+	FirstSeenAt             time.Time
+	LastSeenAt              time.Time
+}
+
+// LedgerCheckpoint is the time of the last run of a consumer of a product
+type LedgerCheckpoint struct {
+	Consumer  string `gorm:"primaryKey"`
+	ProductID string `gorm:"primaryKey"`
+	LastRunAt time.Time
+}
+
+// Ledger records the products, deliveries and files of the catalog
+// and the downloads in a database.
+// It keeps an auditable history of what was downloaded and when.
+type Ledger struct {
+	db  *gorm.DB
+	now func() time.Time // replaceable for tests
+}
+
+// OpenLedger opens or creates a ledger in a SQLite database file
+func OpenLedger(filePath string) (l *Ledger, err error) {
+	db, err := gorm.Open(sqlite.Open(filePath), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		slog.With("err", err, "filePath", filePath).Error("failed to open ledger database")
+		return
+	}
+	return NewLedger(db)
+}
+
+// NewLedger creates a ledger in an existing database and migrates the tables
+func NewLedger(db *gorm.DB) (l *Ledger, err error) {
+	err = db.AutoMigrate(&LedgerProduct{}, &LedgerDelivery{}, &LedgerFile{}, &LedgerCheckpoint{})
+	if err != nil {
+		slog.With("err", err).Error("failed to migrate ledger tables")
+		return
+	}
+	return &Ledger{db: db, now: time.Now}, nil
+}
+
+// DB returns the database of the ledger for custom queries
+func (l *Ledger) DB() *gorm.DB {
+	return l.db
+}
+
+// Close closes the database connection
+func (l *Ledger) Close() error {
+	sqlDB, err := l.db.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.Close()
+}
+
+// RecordProducts records the products of the catalog
+func (l *Ledger) RecordProducts(products []EpoProductItem) (err error) {
+	now := l.now()
+	for _, p := range products {
+		err = l.db.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "product_id"}},
+			DoUpdates: clause.AssignmentColumns([]string{"name", "description", "last_seen_at"}),
+		}).Create(&LedgerProduct{
+			ProductID:   strconv.Itoa(p.ID),
+			Name:        p.Name,
+			Description: p.Description,
+			FirstSeenAt: now,
+			LastSeenAt:  now,
+		}).Error
+		if err != nil {
+			slog.With("err", err).Error("failed to record product")
+			return
+		}
+	}
+	return
+}
+
+// RecordCatalog records the product, deliveries and files of the catalog of a product.
+// A file whose checksum or size changed is seen again and has to be downloaded again.
+// It returns the deliveries that were not in the ledger before.
+func (l *Ledger) RecordCatalog(productID EpoBddsBProductID, catalog EpoProductDeliveriesResponse) (newDeliveries []LedgerDelivery, err error) {
+	now := l.now()
+	err = l.db.Transaction(func(tx *gorm.DB) error {
+		errProduct := tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "product_id"}},
+			DoUpdates: clause.AssignmentColumns([]string{"name", "description", "last_seen_at"}),
+		}).Create(&LedgerProduct{
+			ProductID:   string(productID),
+			Name:        catalog.Name,
+			Description: catalog.Description,
+			FirstSeenAt: now,
+			LastSeenAt:  now,
+		}).Error
+		if errProduct != nil {
+			return errProduct
+		}
+
+		for _, d := range catalog.Deliveries {
+			delivery := LedgerDelivery{
+				DeliveryID:                  d.DeliveryID,
+				ProductID:                   string(productID),
+				DeliveryName:                d.DeliveryName,
+				DeliveryPublicationDatetime: d.DeliveryPublicationDatetime,
+				DeliveryExpiryDatetime:      d.DeliveryExpiryDatetime,
+				FirstSeenAt:                 now,
+				LastSeenAt:                  now,
+			}
+			// check if the delivery is new
+			var count int64
+			errCount := tx.Model(&LedgerDelivery{}).Where("delivery_id = ?", d.DeliveryID).Count(&count).Error
+			if errCount != nil {
+				return errCount
+			}
+			if count == 0 {
+				newDeliveries = append(newDeliveries, delivery)
+			}
+			errDelivery := tx.Clauses(clause.OnConflict{
+				Columns:   []clause.Column{{Name: "delivery_id"}},
+				DoUpdates: clause.AssignmentColumns([]string{"delivery_name", "delivery_publication_datetime", "delivery_expiry_datetime", "last_seen_at"}),
+			}).Create(&delivery).Error
+			if errDelivery != nil {
+				return errDelivery
+			}
+
+			for _, f := range d.Files {
+				errFile := tx.Clauses(clause.OnConflict{
+					Columns:   []clause.Column{{Name: "delivery_id"}, {Name: "file_id"}},
+					DoUpdates: append(clause.AssignmentColumns([]string{"file_name", "file_size", "file_checksum", "item_publication_datetime", "last_seen_at"}), fileChangedAssignments...),
+				}).Create(&LedgerFile{
+					DeliveryID:              d.DeliveryID,
+					FileID:                  f.FileID,
+					ProductID:               string(productID),
+					FileName:                f.FileName,
+					FileSize:                f.FileSize,
+					FileChecksum:            f.FileChecksum,
+					ItemPublicationDatetime: f.ItemPublicationDatetime,
+					Status:                  LedgerFileSeen,
+					FirstSeenAt:             now,
+					LastSeenAt:              now,
+				}).Error
+				if errFile != nil {
+					return errFile
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		slog.With("err", err, "productID", productID).Error("failed to record catalog")
+		return nil, err
+	}
+	return
+}
+
+// fileChangedAssignments reset the status and the download time of a file
+// whose checksum or size in the catalog changed, the values are compared before the update
+var fileChangedAssignments = []clause.Assignment{
+	{Column: clause.Column{Name: "status"}, Value: gorm.Expr(fileChangedCase, LedgerFileSeen, clause.Column{Name: "status"})},
+	{Column: clause.Column{Name: "downloaded_at"}, Value: gorm.Expr(fileChangedCase, nil, clause.Column{Name: "downloaded_at"})},
+}
+
+const fileChangedCase = "CASE WHEN file_checksum <> excluded.file_checksum OR file_size <> excluded.file_size THEN ? ELSE ? END"
+
+// RecordDownload records the result of a download job.
+// Skipped files are recorded as downloaded, since they exist and match the checksum,
+// and keep the time and the path of their download.
+// The path of a file in a storage is the name of the object.
+func (l *Ledger) RecordDownload(result DownloadResult) (err error) {
+	now := l.now()
+	file := LedgerFile{
+		DeliveryID:   result.Job.DeliveryID,
+		FileID:       result.Job.File.FileID,
+		ProductID:    string(result.Job.ProductID),
+		FileName:     result.Job.File.FileName,
+		FileSize:     result.Job.File.FileSize,
+		FileChecksum: result.Job.File.FileChecksum,
+		FirstSeenAt:  now,
+		LastSeenAt:   now,
+	}
+	if result.Err != nil {
+		file.Status = LedgerFileFailed
+		file.Error = result.Err.Error()
+	} else {
+		file.Status = LedgerFileDownloaded
+		file.LocalPath = result.Job.FilePath()
+		if result.Object != "" {
+			file.LocalPath = result.Object
+		}
+		file.DownloadedAt = &now
+	}
+	columns := []string{"status", "error"}
+	if result.Err == nil && !result.Skipped {
+		columns = append(columns, "local_path", "downloaded_at")
+	}
+	err = l.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "delivery_id"}, {Name: "file_id"}},
+		DoUpdates: clause.AssignmentColumns(columns),
+	}).Create(&file).Error
+	if err != nil {
+		slog.With("err", err, "file", file.FileName).Error("failed to record download")
+	}
+	return
+}
+
+// Deliveries returns the deliveries of a product ordered by publication date
+func (l *Ledger) Deliveries(productID EpoBddsBProductID) (deliveries []LedgerDelivery, err error) {
+	err = l.db.Where("product_id = ?", string(productID)).
+		Order("delivery_publication_datetime, delivery_id").
+		Find(&deliveries).Error
+	return
+}
+
+// NewDeliveriesSince returns the deliveries of a product that were first seen after the given time
+func (l *Ledger) NewDeliveriesSince(productID EpoBddsBProductID, since time.Time) (deliveries []LedgerDelivery, err error) {
+	err = l.db.Where("product_id = ? AND first_seen_at > ?", string(productID), since).
+		Order("delivery_publication_datetime, delivery_id").
+		Find(&deliveries).Error
+	return
+}
+
+// Files returns the files of a delivery
+func (l *Ledger) Files(deliveryID int) (files []LedgerFile, err error) {
+	err = l.db.Where("delivery_id = ?", deliveryID).Order("file_id").Find(&files).Error
+	return
+}
+
+// FilesWithStatus returns the files of a product with the given status
+func (l *Ledger) FilesWithStatus(productID EpoBddsBProductID, status LedgerFileStatus) (files []LedgerFile, err error) {
+	err = l.db.Where("product_id = ? AND status = ?", string(productID), status).
+		Order("delivery_id, file_id").
+		Find(&files).Error
+	return
+}
+
+// Checkpoint returns the time of the last run of a consumer of a product,
+// or the zero time if the consumer never ran
+func (l *Ledger) Checkpoint(consumer string, productID EpoBddsBProductID) (lastRunAt time.Time, err error) {
+	var checkpoint LedgerCheckpoint
+	err = l.db.Where("consumer = ? AND product_id = ?", consumer, string(productID)).First(&checkpoint).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return time.Time{}, nil
+	}
+	return checkpoint.LastRunAt, err
+}
+
+// SetCheckpoint sets the time of the last run of a consumer of a product
+func (l *Ledger) SetCheckpoint(consumer string, productID EpoBddsBProductID, lastRunAt time.Time) error {
+	return l.db.Clauses(clause.OnConflict{UpdateAll: true}).Create(&LedgerCheckpoint{
+		Consumer:  consumer,
+		ProductID: string(productID),
+		LastRunAt: lastRunAt,
+	}).Error
+}
+
+// NewDeliveriesForConsumer returns the deliveries of a product
+// that were first seen since the last checkpoint of the consumer
+// and moves the checkpoint to now
+func (l *Ledger) NewDeliveriesForConsumer(consumer string, productID EpoBddsBProductID) (deliveries []LedgerDelivery, err error) {
+	now := l.now()
+	since, err := l.Checkpoint(consumer, productID)
+	if err != nil {
+		return
+	}
+	deliveries, err = l.NewDeliveriesSince(productID, since)
+	if err != nil {
+		return
+	}
+	err = l.SetCheckpoint(consumer, productID, now)
+	return
+}
@@ -0,0 +1,161 @@
+package epo_bbds
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestLedger(t *testing.T) *Ledger {
+	l, err := OpenLedger(filepath.Join(t.TempDir(), "ledger.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = l.Close() })
+	return l
+}
+
+func TestLedgerRecordCatalog(t *testing.T) {
+	ass := assert.New(t)
+	l := newTestLedger(t)
+	now := time.Date(2024, 4, 2, 0, 0, 0, 0, time.UTC)
+	l.now = func() time.Time { return now }
+
+	catalog := testCatalog
+	catalog.Deliveries = catalog.Deliveries[:1]
+	newDeliveries, err := l.RecordCatalog(EpoPatstatGlobalProductID, catalog)
+	ass.NoError(err)
+	ass.Len(newDeliveries, 1)
+
+	// the second delivery appears a day later
+	now = now.Add(24 * time.Hour)
+	newDeliveries, err = l.RecordCatalog(EpoPatstatGlobalProductID, testCatalog)
+	ass.NoError(err)
+	ass.Len(newDeliveries, 1)
+	ass.Equal(2, newDeliveries[0].DeliveryID)
+
+	deliveries, err := l.Deliveries(EpoPatstatGlobalProductID)
+	ass.NoError(err)
+	ass.Len(deliveries, 2)
+	ass.Equal(now, deliveries[0].LastSeenAt.UTC())
+
+	deliveries, err = l.NewDeliveriesSince(EpoPatstatGlobalProductID, now.Add(-time.Hour))
+	ass.NoError(err)
+	ass.Len(deliveries, 1)
+
+	files, err := l.FilesWithStatus(EpoPatstatGlobalProductID, LedgerFileSeen)
+	ass.NoError(err)
+	ass.Len(files, 4)
+
+	// a downloaded file is seen again after it was republished with another checksum
+	job := DownloadJob{ProductID: EpoPatstatGlobalProductID, DeliveryID: 1, File: testCatalog.Deliveries[0].Files[0]}
+	ass.NoError(l.RecordDownload(DownloadResult{Job: job}))
+	_, err = l.RecordCatalog(EpoPatstatGlobalProductID, testCatalog)
+	ass.NoError(err)
+	files, err = l.FilesWithStatus(EpoPatstatGlobalProductID, LedgerFileDownloaded)
+	ass.NoError(err)
+	ass.Len(files, 1)
+
+	catalog = testCatalog
+	catalog.Deliveries = append([]EpoProductDelivery{}, testCatalog.Deliveries...)
+	catalog.Deliveries[0].Files = append([]EpoDocDbFileItem{}, testCatalog.Deliveries[0].Files...)
+	catalog.Deliveries[0].Files[0].FileChecksum = "republished"
+	_, err = l.RecordCatalog(EpoPatstatGlobalProductID, catalog)
+	ass.NoError(err)
+	files, err = l.FilesWithStatus(EpoPatstatGlobalProductID, LedgerFileSeen)
+	ass.NoError(err)
+	ass.Len(files, 4)
+	ass.Equal("republished", files[0].FileChecksum)
+	ass.Nil(files[0].DownloadedAt)
+}
+
+func TestLedgerRecordDownload(t *testing.T) {
+	ass := assert.New(t)
+	l := newTestLedger(t)
+	now := time.Date(2024, 4, 2, 0, 0, 0, 0, time.UTC)
+	l.now = func() time.Time { return now }
+	_, err := l.RecordCatalog(EpoPatstatGlobalProductID, testCatalog)
+	ass.NoError(err)
+
+	job := DownloadJob{ProductID: EpoPatstatGlobalProductID, DeliveryID: 1, File: testCatalog.Deliveries[0].Files[0], DestinationPath: "/data"}
+	ass.NoError(l.RecordDownload(DownloadResult{Job: job, Err: ErrCanNotDownload}))
+	files, err := l.FilesWithStatus(EpoPatstatGlobalProductID, LedgerFileFailed)
+	ass.NoError(err)
+	ass.Len(files, 1)
+	ass.Equal(ErrCanNotDownload.Error(), files[0].Error)
+
+	ass.NoError(l.RecordDownload(DownloadResult{Job: job}))
+	files, err = l.Files(1)
+	ass.NoError(err)
+	ass.Len(files, 2)
+	ass.Equal(LedgerFileDownloaded, files[0].Status)
+	ass.Equal("", files[0].Error)
+	ass.Equal(filepath.Join("/data", job.File.FileName), files[0].LocalPath)
+	if ass.NotNil(files[0].DownloadedAt) {
+		ass.Equal(now, files[0].DownloadedAt.UTC())
+	}
+
+	// a skipped file keeps the time of its download
+	l.now = func() time.Time { return now.Add(time.Hour) }
+	ass.NoError(l.RecordDownload(DownloadResult{Job: job, Skipped: true}))
+	files, err = l.Files(1)
+	ass.NoError(err)
+	ass.Equal(LedgerFileDownloaded, files[0].Status)
+	if ass.NotNil(files[0].DownloadedAt) {
+		ass.Equal(now, files[0].DownloadedAt.UTC())
+	}
+
+	// the path of a file in a storage is the name of the object
+	job.File = testCatalog.Deliveries[0].Files[1]
+	ass.NoError(l.RecordDownload(DownloadResult{Job: job, Object: job.ObjectName()}))
+	files, err = l.Files(1)
+	ass.NoError(err)
+	ass.Equal("/data/"+job.File.FileName, files[1].LocalPath)
+}
+
+func TestLedgerCheckpoint(t *testing.T) {
+	ass := assert.New(t)
+	l := newTestLedger(t)
+	now := time.Date(2024, 4, 2, 0, 0, 0, 0, time.UTC)
+	l.now = func() time.Time { return now }
+	_, err := l.RecordCatalog(EpoPatstatGlobalProductID, testCatalog)
+	ass.NoError(err)
+
+	now = now.Add(time.Minute)
+	deliveries, err := l.NewDeliveriesForConsumer("loader", EpoPatstatGlobalProductID)
+	ass.NoError(err)
+	ass.Len(deliveries, 2)
+
+	// nothing new since the last run
+	now = now.Add(time.Minute)
+	deliveries, err = l.NewDeliveriesForConsumer("loader", EpoPatstatGlobalProductID)
+	ass.NoError(err)
+	ass.Empty(deliveries)
+
+	// other consumers have their own checkpoint
+	deliveries, err = l.NewDeliveriesForConsumer("other", EpoPatstatGlobalProductID)
+	ass.NoError(err)
+	ass.Len(deliveries, 2)
+}
+
+func TestProductSyncLedger(t *testing.T) {
+	ass := assert.New(t)
+	_, c := newCatalogTestServer(t, testCatalog)
+	l := newTestLedger(t)
+
+	_, err := c.NewProductSync(EpoPatstatGlobalProductID, t.TempDir()).
+		SetLedger(l).
+		Select(FileNameGlob("data_*")).
+		Run(context.Background())
+	ass.NoError(err)
+
+	downloaded, err := l.FilesWithStatus(EpoPatstatGlobalProductID, LedgerFileDownloaded)
+	ass.NoError(err)
+	ass.Len(downloaded, 3)
+	seen, err := l.FilesWithStatus(EpoPatstatGlobalProductID, LedgerFileSeen)
+	ass.NoError(err)
+	ass.Len(seen, 1)
+}
@@ -3,6 +3,8 @@ package epo_bbds
 import (
 	"os"
 	"testing"
+
+	"github.com/max-planck-innovation-competition/go-epo-bdds/pkg/epo_bbdstest"
 )
 
 func init() {
@@ -15,6 +17,29 @@ func skipTest(t *testing.T) {
 	}
 }
 
+// skipWithoutCredentials skips tests against the live EPO endpoints
+// if no credentials are set
+func skipWithoutCredentials(t *testing.T) {
+	if os.Getenv("EPO_USERNAME") == "" {
+		t.Skip("Skipping live API test without EPO_USERNAME")
+	}
+}
+
+// newTestClient starts a fake of the bulk data service with the products
+// and creates a client that logs in with the default credentials of the fake.
+// The options are applied after the defaults.
+func newTestClient(t *testing.T, products []epo_bbdstest.Product, options ...ClientOption) (*epo_bbdstest.Server, *Client) {
+	srv := epo_bbdstest.NewServer(products...)
+	t.Cleanup(srv.Close)
+	options = append([]ClientOption{
+		WithBaseURL(srv.BaseURL()),
+		WithLoginURL(srv.LoginURL()),
+		WithCredentials(epo_bbdstest.DefaultUsername, epo_bbdstest.DefaultPassword),
+		WithRetryPolicy(fastRetries),
+	}, options...)
+	return srv, NewClient(options...)
+}
+
 func TestNewFeature(t *testing.T) {
 	skipTest(t)
 }
@@ -0,0 +1,200 @@
+package epo_bbds
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Mirror keeps a local directory in sync with a product.
+// The files are saved in the layout <root>/<product>/<deliveryName>/<file>.
+// Missing and changed files are downloaded, expired deliveries and deliveries
+// that are no longer in the catalog are reported and optionally pruned.
+type Mirror struct {
+	sync  *ProductSync
+	prune bool
+	now   func() time.Time // replaceable for tests
+}
+
+// MirrorResult is the result of a mirror run
+type MirrorResult struct {
+	SyncResult
+	Expired    []string // local delivery directories whose delivery has expired
+	Orphaned   []string // local delivery directories that are no longer in the catalog
+	Unexpected []string // local files in a delivery directory that are not in the catalog
+	Foreign    []string // local files in the product directory, e.g. a ledger, they are reported but never pruned
+	Pruned     bool     // the expired, orphaned and unexpected paths were removed
+}
+
+// NewMirror creates a new mirror of a product in the root path
+func NewMirror(productID EpoBddsBProductID, rootPath string) *Mirror {
+	return defaultClient().NewMirror(productID, rootPath)
+}
+
+// NewMirror creates a new mirror of a product in the root path
+func (c *Client) NewMirror(productID EpoBddsBProductID, rootPath string) *Mirror {
+	return &Mirror{
+		sync: c.NewProductSync(productID, rootPath).SetLayout(MirrorLayout),
+		now:  time.Now,
+	}
+}
+
+// Select adds selectors, a file is only downloaded if all selectors select it
+func (m *Mirror) Select(selectors ...Selector) *Mirror {
+	m.sync.Select(selectors...)
+	return m
+}
+
+// SetPrune sets if expired and orphaned deliveries and unexpected files are removed
+func (m *Mirror) SetPrune(prune bool) *Mirror {
+	m.prune = prune
+	return m
+}
+
+// SetLedger sets a ledger that records the catalog and the results of the downloads
+func (m *Mirror) SetLedger(ledger *Ledger) *Mirror {
+	m.sync.SetLedger(ledger)
+	return m
+}
+
+// SetDiskSpaceCheck sets what happens if the planned downloads do not fit on the destination filesystem,
+// the default is DiskSpaceRefuse
+func (m *Mirror) SetDiskSpaceCheck(check DiskSpaceCheck) *Mirror {
+	m.sync.SetDiskSpaceCheck(check)
+	return m
+}
+
+// DownloadManager returns the download manager of the mirror,
+// which can be used to set the parallelism and the rate limits
+func (m *Mirror) DownloadManager() *DownloadManager {
+	return m.sync.DownloadManager()
+}
+
+// ProductDir returns the local directory of the product
+func (m *Mirror) ProductDir() string {
+	return filepath.Join(m.sync.DestinationPath, string(m.sync.ProductID))
+}
+
+// Run fetches the catalog, downloads the missing and changed files
+// of the deliveries that have not expired and checks the local directory
+// for expired, orphaned and unexpected paths.
+// If a download fails, the local directory is neither checked nor pruned.
+// If two deliveries have the same directory name, nothing is downloaded
+// and an error that matches ErrDeliveryDirCollision is returned.
+func (m *Mirror) Run(ctx context.Context) (result MirrorResult, err error) {
+	s := m.sync
+	logger := s.client.logger.With("productID", s.ProductID)
+
+	catalog, err := s.client.GetEpoBddsFileItemsContext(ctx, "", s.ProductID)
+	if err != nil {
+		logger.With("err", err).Error("could not get files")
+		return
+	}
+	deliveries, err := deliveryDirs(catalog.Deliveries)
+	if err != nil {
+		logger.With("err", err).Error("could not map deliveries to directories")
+		return
+	}
+
+	// expired deliveries are not downloaded anymore
+	now := m.now()
+	active := catalog
+	active.Deliveries = nil
+	for _, d := range catalog.Deliveries {
+		if !deliveryExpired(d, now) {
+			active.Deliveries = append(active.Deliveries, d)
+		}
+	}
+	result.SyncResult, err = s.run(ctx, active)
+	if err != nil {
+		return
+	}
+
+	err = m.check(deliveries, now, &result)
+	if err != nil {
+		return
+	}
+	logger.With(
+		"expired", len(result.Expired),
+		"orphaned", len(result.Orphaned),
+		"unexpected", len(result.Unexpected),
+		"foreign", len(result.Foreign),
+	).Info("mirror checked")
+
+	// only delivery directories and files within them are pruned
+	if m.prune {
+		for _, paths := range [][]string{result.Expired, result.Orphaned, result.Unexpected} {
+			for _, p := range paths {
+				err = os.RemoveAll(p)
+				if err != nil {
+					logger.With("err", err, "path", p).Error("could not prune path")
+					return
+				}
+			}
+		}
+		result.Pruned = true
+	}
+	return
+}
+
+// check compares the local product directory with the deliveries of the catalog by their directory names
+func (m *Mirror) check(deliveries map[string]EpoProductDelivery, now time.Time, result *MirrorResult) (err error) {
+	productDir := m.ProductDir()
+	entries, err := os.ReadDir(productDir)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		m.sync.client.logger.With("err", err, "path", productDir).Error("could not read product directory")
+		return
+	}
+
+	for _, entry := range entries {
+		p := filepath.Join(productDir, entry.Name())
+		d, ok := deliveries[entry.Name()]
+		switch {
+		case !entry.IsDir():
+			result.Foreign = append(result.Foreign, p)
+		case !ok:
+			result.Orphaned = append(result.Orphaned, p)
+		case deliveryExpired(d, now):
+			result.Expired = append(result.Expired, p)
+		default:
+			var unexpected []string
+			unexpected, err = unexpectedFiles(p, d)
+			if err != nil {
+				m.sync.client.logger.With("err", err, "path", p).Error("could not read delivery directory")
+				return
+			}
+			result.Unexpected = append(result.Unexpected, unexpected...)
+		}
+	}
+	return
+}
+
+// unexpectedFiles returns the files of a delivery directory that are not in the delivery.
+// Partial downloads of files in the delivery are expected.
+func unexpectedFiles(dir string, delivery EpoProductDelivery) (paths []string, err error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return
+	}
+	files := map[string]struct{}{}
+	for _, f := range delivery.Files {
+		files[f.FileName] = struct{}{}
+	}
+	for _, entry := range entries {
+		if _, ok := files[strings.TrimSuffix(entry.Name(), PartFileSuffix)]; ok && !entry.IsDir() {
+			continue
+		}
+		paths = append(paths, filepath.Join(dir, entry.Name()))
+	}
+	return
+}
+
+// deliveryExpired reports if the delivery has expired at the given time
+func deliveryExpired(delivery EpoProductDelivery, now time.Time) bool {
+	return delivery.DeliveryExpiryDatetime != nil && !delivery.DeliveryExpiryDatetime.After(now)
+}
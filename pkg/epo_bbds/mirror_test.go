@@ -0,0 +1,80 @@
+package epo_bbds
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMirror(t *testing.T) {
+	ass := assert.New(t)
+	catalog := testCatalog
+	expiry := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	catalog.Deliveries = append([]EpoProductDelivery{}, testCatalog.Deliveries...)
+	catalog.Deliveries[0].DeliveryExpiryDatetime = &expiry
+	_, c := newCatalogTestServer(t, catalog)
+	root := t.TempDir()
+
+	m := c.NewMirror(EpoPatstatGlobalProductID, root)
+	m.now = func() time.Time { return time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC) }
+
+	// local state: an expired delivery, an orphaned delivery and an unexpected file
+	productDir := m.ProductDir()
+	expiredDir := filepath.Join(productDir, "PATSTAT Global 2023 Autumn")
+	orphanedDir := filepath.Join(productDir, "PATSTAT Global 2022 Autumn")
+	activeDir := filepath.Join(productDir, "PATSTAT Global 2024 Spring")
+	for _, dir := range []string{expiredDir, orphanedDir, activeDir} {
+		ass.NoError(os.MkdirAll(dir, 0755))
+	}
+	unexpected := filepath.Join(activeDir, "old.zip")
+	ass.NoError(os.WriteFile(unexpected, []byte("old"), 0644))
+	// a file of the user next to the deliveries
+	ledger := filepath.Join(productDir, "ledger.json")
+	ass.NoError(os.WriteFile(ledger, []byte("{}"), 0644))
+
+	result, err := m.Run(context.Background())
+	ass.NoError(err)
+	// only the files of the active delivery are downloaded
+	ass.Len(result.Downloaded(), 2)
+	ass.True(pathExists(filepath.Join(activeDir, "data_PATSTAT_Global_2024_Spring_01.zip")))
+	ass.Equal([]string{expiredDir}, result.Expired)
+	ass.Equal([]string{orphanedDir}, result.Orphaned)
+	ass.Equal([]string{unexpected}, result.Unexpected)
+	ass.Equal([]string{ledger}, result.Foreign)
+	ass.False(result.Pruned)
+	ass.True(pathExists(orphanedDir))
+
+	// prune the reported paths, the complete files are skipped
+	result, err = m.SetPrune(true).Run(context.Background())
+	ass.NoError(err)
+	ass.Len(result.Skipped(), 2)
+	ass.True(result.Pruned)
+	ass.False(pathExists(expiredDir))
+	ass.False(pathExists(orphanedDir))
+	ass.False(pathExists(unexpected))
+	// files outside of the delivery directories are not pruned
+	ass.True(pathExists(ledger))
+	ass.True(pathExists(filepath.Join(activeDir, "documentation_PATSTAT_Global_2024_Spring.zip")))
+}
+
+func TestMirrorDeliveryDirCollision(t *testing.T) {
+	ass := assert.New(t)
+	catalog := testCatalog
+	catalog.Deliveries = append([]EpoProductDelivery{}, testCatalog.Deliveries...)
+	// the names are the same after the invalid characters are replaced
+	catalog.Deliveries[0].DeliveryName = "PATSTAT Global 2024/Spring"
+	catalog.Deliveries[1].DeliveryName = "PATSTAT Global 2024:Spring"
+	_, c := newCatalogTestServer(t, catalog)
+	root := t.TempDir()
+
+	result, err := c.NewMirror(EpoPatstatGlobalProductID, root).Run(context.Background())
+	ass.ErrorIs(err, ErrDeliveryDirCollision)
+	ass.Empty(result.Downloaded())
+
+	_, err = ReconcileCatalog(context.Background(), catalog, root, ReconcileOptions{})
+	ass.ErrorIs(err, ErrDeliveryDirCollision)
+}
@@ -0,0 +1,238 @@
+package epo_bbds
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrUnknownProduct is returned if no product matches the name or id
+var ErrUnknownProduct = errors.New("unknown product")
+
+// ErrAmbiguousProduct is returned if more than one product matches the name
+var ErrAmbiguousProduct = errors.New("ambiguous product")
+
+// DefaultProductCatalogTTL is the duration the product list is cached
+const DefaultProductCatalogTTL = time.Hour
+
+// ProductID returns the id of the product, which can be used e.g. with NewProductSync
+func (p EpoProductItem) ProductID() EpoBddsBProductID {
+	return EpoBddsBProductID(strconv.Itoa(p.ID))
+}
+
+// KnownProduct describes a product that has a constant in this package
+type KnownProduct struct {
+	ID       EpoBddsBProductID
+	Constant string   // name of the constant
+	Keywords []string // words that the name of the product contains, case-insensitive
+}
+
+// KnownProducts are the products that have a constant in this package
+var KnownProducts = []KnownProduct{
+	{ID: EpoDocDBFrontFilesProductID, Constant: "EpoDocDBFrontFilesProductID", Keywords: []string{"docdb", "front"}},
+	{ID: EpoFullTextFrontFilesProductID, Constant: "EpoFullTextFrontFilesProductID", Keywords: []string{"full", "text", "front"}},
+	{ID: EpoDocDBBackFilesProductID, Constant: "EpoDocDBBackFilesProductID", Keywords: []string{"docdb", "back"}},
+	{ID: EpoPatstatGlobalProductID, Constant: "EpoPatstatGlobalProductID", Keywords: []string{"patstat", "global"}},
+	{ID: EpoPatstatEpRegisterProductID, Constant: "EpoPatstatEpRegisterProductID", Keywords: []string{"patstat", "register"}},
+}
+
+// Matches checks if the name of the product contains all keywords
+func (k KnownProduct) Matches(product EpoProductItem) bool {
+	return containsAllWords(product.Name, k.Keywords)
+}
+
+// ProductMismatch is a known product whose constant does not match the product list of the server
+type ProductMismatch struct {
+	Known      KnownProduct
+	Product    *EpoProductItem  // product with the id of the constant, nil if there is none
+	Candidates []EpoProductItem // products whose name matches the keywords of the known product
+}
+
+// String describes the mismatch
+func (m ProductMismatch) String() string {
+	var b strings.Builder
+	if m.Product == nil {
+		fmt.Fprintf(&b, "%s: no product with id %s", m.Known.Constant, m.Known.ID)
+	} else {
+		fmt.Fprintf(&b, "%s: product %s is named %q", m.Known.Constant, m.Known.ID, m.Product.Name)
+	}
+	for _, c := range m.Candidates {
+		fmt.Fprintf(&b, ", candidate %d %q", c.ID, c.Name)
+	}
+	return b.String()
+}
+
+// ProductCatalog resolves the products of the bulk data service by name or id.
+// The product list is fetched with the token source of the client and cached.
+type ProductCatalog struct {
+	client *Client
+	ttl    time.Duration
+	now    func() time.Time // replaceable for tests
+
+	mu        sync.Mutex
+	products  []EpoProductItem
+	fetchedAt time.Time
+}
+
+// NewProductCatalog creates a new product catalog
+func NewProductCatalog() *ProductCatalog {
+	return defaultClient().NewProductCatalog()
+}
+
+// NewProductCatalog creates a new product catalog
+func (c *Client) NewProductCatalog() *ProductCatalog {
+	return &ProductCatalog{
+		client: c,
+		ttl:    DefaultProductCatalogTTL,
+		now:    time.Now,
+	}
+}
+
+// SetTTL sets the duration the product list is cached,
+// the product list is fetched for every call if the duration is 0
+func (pc *ProductCatalog) SetTTL(ttl time.Duration) *ProductCatalog {
+	pc.ttl = ttl
+	return pc
+}
+
+// Products returns the cached product list
+// and fetches it if it is not cached or expired
+func (pc *ProductCatalog) Products(ctx context.Context) (products []EpoProductItem, err error) {
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+	if pc.products != nil && pc.now().Sub(pc.fetchedAt) < pc.ttl {
+		return append([]EpoProductItem{}, pc.products...), nil
+	}
+	products, err = pc.client.GetProductsContext(ctx, "")
+	if err != nil {
+		return
+	}
+	pc.products = products
+	pc.fetchedAt = pc.now()
+	return append([]EpoProductItem{}, products...), nil
+}
+
+// Refresh discards the cached product list
+func (pc *ProductCatalog) Refresh() {
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+	pc.products = nil
+}
+
+// Product returns the product with the id
+func (pc *ProductCatalog) Product(ctx context.Context, productID EpoBddsBProductID) (product EpoProductItem, err error) {
+	products, err := pc.Products(ctx)
+	if err != nil {
+		return
+	}
+	for _, p := range products {
+		if p.ProductID() == productID {
+			return p, nil
+		}
+	}
+	return product, fmt.Errorf("%w: %s", ErrUnknownProduct, productID)
+}
+
+// Resolve returns the product with the id or name.
+// The name is compared case-insensitive, if no name is equal
+// the product whose name contains all words of the name is returned,
+// e.g. "docdb back" resolves to the DOCDB back file product.
+// ErrAmbiguousProduct is returned if several products contain the words.
+func (pc *ProductCatalog) Resolve(ctx context.Context, nameOrID string) (product EpoProductItem, err error) {
+	nameOrID = strings.TrimSpace(nameOrID)
+	products, err := pc.Products(ctx)
+	if err != nil {
+		return
+	}
+	// id
+	if _, errAtoi := strconv.Atoi(nameOrID); errAtoi == nil {
+		for _, p := range products {
+			if string(p.ProductID()) == nameOrID {
+				return p, nil
+			}
+		}
+	}
+	// name
+	for _, p := range products {
+		if strings.EqualFold(strings.TrimSpace(p.Name), nameOrID) {
+			return p, nil
+		}
+	}
+	// words of the name
+	var matches []EpoProductItem
+	words := strings.Fields(nameOrID)
+	if len(words) > 0 {
+		for _, p := range products {
+			if containsAllWords(p.Name, words) {
+				matches = append(matches, p)
+			}
+		}
+	}
+	switch len(matches) {
+	case 0:
+		err = fmt.Errorf("%w: %q", ErrUnknownProduct, nameOrID)
+	case 1:
+		product = matches[0]
+	default:
+		names := make([]string, len(matches))
+		for i, m := range matches {
+			names[i] = strconv.Quote(m.Name)
+		}
+		err = fmt.Errorf("%w: %q matches %s", ErrAmbiguousProduct, nameOrID, strings.Join(names, ", "))
+	}
+	return
+}
+
+// CheckKnownProducts compares the constants of this package with the product list of the server
+// and logs a warning for every constant that no longer matches
+func (pc *ProductCatalog) CheckKnownProducts(ctx context.Context) (mismatches []ProductMismatch, err error) {
+	products, err := pc.Products(ctx)
+	if err != nil {
+		return
+	}
+	mismatches = checkKnownProducts(KnownProducts, products)
+	for _, m := range mismatches {
+		pc.client.logger.With("constant", m.Known.Constant, "productId", m.Known.ID).
+			Warn("product constant does not match the server: " + m.String())
+	}
+	return
+}
+
+// checkKnownProducts returns the known products that do not match the products
+func checkKnownProducts(known []KnownProduct, products []EpoProductItem) (mismatches []ProductMismatch) {
+	for _, k := range known {
+		var product *EpoProductItem
+		for i := range products {
+			if products[i].ProductID() == k.ID {
+				product = &products[i]
+				break
+			}
+		}
+		if product != nil && k.Matches(*product) {
+			continue
+		}
+		m := ProductMismatch{Known: k, Product: product}
+		for _, p := range products {
+			if k.Matches(p) {
+				m.Candidates = append(m.Candidates, p)
+			}
+		}
+		mismatches = append(mismatches, m)
+	}
+	return
+}
+
+// containsAllWords checks if the name contains all words, case-insensitive
+func containsAllWords(name string, words []string) bool {
+	name = strings.ToLower(name)
+	for _, w := range words {
+		if !strings.Contains(name, strings.ToLower(w)) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,114 @@
+package epo_bbds
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/max-planck-innovation-competition/go-epo-bdds/pkg/epo_bbdstest"
+	"github.com/stretchr/testify/assert"
+)
+
+var testProducts = []epo_bbdstest.Product{
+	{ID: 3, Name: "EP DOCDB front file"},
+	{ID: 4, Name: "EP full-text data - front file"},
+	{ID: 14, Name: "EP DOCDB back file"},
+	{ID: 17, Name: "PATSTAT Global"},
+	{ID: 18, Name: "PATSTAT EP Register"},
+	{ID: 32, Name: "EP full-text data - back file"},
+}
+
+func TestProductCatalogResolve(t *testing.T) {
+	ass := assert.New(t)
+	_, c := newTestClient(t, testProducts)
+	pc := c.NewProductCatalog()
+	ctx := context.Background()
+
+	for nameOrID, expected := range map[string]EpoBddsBProductID{
+		"14":                   EpoDocDBBackFilesProductID,
+		"PATSTAT Global":       EpoPatstatGlobalProductID,
+		" patstat ep register": EpoPatstatEpRegisterProductID,
+		"full-text back":       "32",
+		"docdb front":          EpoDocDBFrontFilesProductID,
+	} {
+		product, err := pc.Resolve(ctx, nameOrID)
+		if ass.NoError(err, nameOrID) {
+			ass.Equal(expected, product.ProductID(), nameOrID)
+		}
+	}
+
+	_, err := pc.Resolve(ctx, "full-text")
+	ass.ErrorIs(err, ErrAmbiguousProduct)
+	_, err = pc.Resolve(ctx, "99")
+	ass.ErrorIs(err, ErrUnknownProduct)
+	_, err = pc.Resolve(ctx, "")
+	ass.ErrorIs(err, ErrUnknownProduct)
+
+	product, err := pc.Product(ctx, EpoPatstatGlobalProductID)
+	ass.NoError(err)
+	ass.Equal("PATSTAT Global", product.Name)
+	_, err = pc.Product(ctx, "99")
+	ass.ErrorIs(err, ErrUnknownProduct)
+}
+
+func TestProductCatalogCache(t *testing.T) {
+	ass := assert.New(t)
+	srv, c := newTestClient(t, testProducts)
+	pc := c.NewProductCatalog()
+	ctx := context.Background()
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	pc.now = func() time.Time { return now }
+
+	_, err := pc.Products(ctx)
+	ass.NoError(err)
+	_, err = pc.Resolve(ctx, "17")
+	ass.NoError(err)
+	ass.Equal(1, srv.Requests(epo_bbdstest.EndpointProducts))
+
+	// a new product is only visible after the cache expired
+	srv.SetProducts(append(testProducts, epo_bbdstest.Product{ID: 40, Name: "EP Register"})...)
+	_, err = pc.Resolve(ctx, "40")
+	ass.ErrorIs(err, ErrUnknownProduct)
+	now = now.Add(DefaultProductCatalogTTL)
+	product, err := pc.Resolve(ctx, "40")
+	ass.NoError(err)
+	ass.Equal("EP Register", product.Name)
+	ass.Equal(2, srv.Requests(epo_bbdstest.EndpointProducts))
+
+	pc.Refresh()
+	_, err = pc.Products(ctx)
+	ass.NoError(err)
+	ass.Equal(3, srv.Requests(epo_bbdstest.EndpointProducts))
+}
+
+func TestProductCatalogCheckKnownProducts(t *testing.T) {
+	ass := assert.New(t)
+	_, c := newTestClient(t, testProducts)
+	pc := c.NewProductCatalog()
+	mismatches, err := pc.CheckKnownProducts(context.Background())
+	ass.NoError(err)
+	ass.Empty(mismatches)
+
+	// the docdb back files moved to another id and the id 17 was reused
+	_, c = newTestClient(t, []epo_bbdstest.Product{
+		{ID: 3, Name: "EP DOCDB front file"},
+		{ID: 4, Name: "EP full-text data - front file"},
+		{ID: 17, Name: "EP DOCDB back file"},
+		{ID: 18, Name: "PATSTAT EP Register"},
+	})
+	pc = c.NewProductCatalog()
+	mismatches, err = pc.CheckKnownProducts(context.Background())
+	ass.NoError(err)
+	if ass.Len(mismatches, 2) {
+		ass.Equal("EpoDocDBBackFilesProductID", mismatches[0].Known.Constant)
+		ass.Nil(mismatches[0].Product)
+		if ass.Len(mismatches[0].Candidates, 1) {
+			ass.Equal(17, mismatches[0].Candidates[0].ID)
+		}
+		ass.Equal(`EpoDocDBBackFilesProductID: no product with id 14, candidate 17 "EP DOCDB back file"`, mismatches[0].String())
+
+		ass.Equal("EpoPatstatGlobalProductID", mismatches[1].Known.Constant)
+		ass.Equal("EP DOCDB back file", mismatches[1].Product.Name)
+		ass.Empty(mismatches[1].Candidates)
+	}
+}
@@ -0,0 +1,225 @@
+package epo_bbds
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+)
+
+// ProductSync downloads the selected files of a product.
+// Without selectors all files of all deliveries are downloaded.
+type ProductSync struct {
+	client          *Client
+	ProductID       EpoBddsBProductID // product to synchronize
+	DestinationPath string            // directory the files are saved to
+	selectors       []Selector
+	layout          Layout
+	manager         *DownloadManager
+	ledger          *Ledger // optional ledger that records the catalog and the downloads
+	diskSpaceCheck  DiskSpaceCheck
+}
+
+// SyncResult is the result of a product sync
+type SyncResult struct {
+	ProductID EpoBddsBProductID
+	Results   []DownloadResult // results of all selected files
+}
+
+// NewProductSync creates a new sync of a product to the destination path
+func NewProductSync(productID EpoBddsBProductID, destinationPath string) *ProductSync {
+	return defaultClient().NewProductSync(productID, destinationPath)
+}
+
+// NewProductSync creates a new sync of a product to the destination path
+func (c *Client) NewProductSync(productID EpoBddsBProductID, destinationPath string) *ProductSync {
+	return &ProductSync{
+		client:          c,
+		ProductID:       productID,
+		DestinationPath: destinationPath,
+		layout:          FlatLayout,
+		manager:         NewDownloadManager(c),
+	}
+}
+
+// Select adds selectors, a file is only downloaded if all selectors select it
+func (s *ProductSync) Select(selectors ...Selector) *ProductSync {
+	s.selectors = append(s.selectors, selectors...)
+	return s
+}
+
+// SetLedger sets a ledger that records the catalog and the results of the downloads
+func (s *ProductSync) SetLedger(ledger *Ledger) *ProductSync {
+	s.ledger = ledger
+	return s
+}
+
+// SetLayout sets the layout of the files in the destination path, the default is FlatLayout
+func (s *ProductSync) SetLayout(layout Layout) *ProductSync {
+	s.layout = layout
+	return s
+}
+
+// SetDiskSpaceCheck sets what happens if the planned downloads do not fit on the destination filesystem,
+// the default is DiskSpaceRefuse
+func (s *ProductSync) SetDiskSpaceCheck(check DiskSpaceCheck) *ProductSync {
+	s.diskSpaceCheck = check
+	return s
+}
+
+// DownloadManager returns the download manager of the sync,
+// which can be used to set the parallelism and the rate limits
+func (s *ProductSync) DownloadManager() *DownloadManager {
+	return s.manager
+}
+
+// Plan fetches the catalog of the product and returns the jobs of the selected files
+func (s *ProductSync) Plan(ctx context.Context) (jobs []DownloadJob, err error) {
+	catalog, err := s.client.GetEpoBddsFileItemsContext(ctx, "", s.ProductID)
+	if err != nil {
+		s.client.logger.With("err", err, "productID", s.ProductID).Error("could not get files")
+		return
+	}
+	return s.plan(catalog), nil
+}
+
+// plan returns the jobs of the selected files of the catalog
+func (s *ProductSync) plan(catalog EpoProductDeliveriesResponse) (jobs []DownloadJob) {
+	for _, d := range catalog.Deliveries {
+		for _, f := range d.Files {
+			if !selectAll(s.selectors, d, f) {
+				continue
+			}
+			jobs = append(jobs, DownloadJob{
+				ProductID:       s.ProductID,
+				DeliveryID:      d.DeliveryID,
+				File:            f,
+				DestinationPath: filepath.Join(s.DestinationPath, s.layout(s.ProductID, d)),
+			})
+		}
+	}
+	return
+}
+
+// Run fetches the catalog and downloads the selected files.
+// The files that are already complete are skipped.
+// A failed download does not stop the other downloads,
+// the returned error summarizes the failed downloads.
+func (s *ProductSync) Run(ctx context.Context) (result SyncResult, err error) {
+	catalog, err := s.client.GetEpoBddsFileItemsContext(ctx, "", s.ProductID)
+	if err != nil {
+		s.client.logger.With("err", err, "productID", s.ProductID).Error("could not get files")
+		return
+	}
+	return s.run(ctx, catalog)
+}
+
+// run downloads the selected files of the catalog
+func (s *ProductSync) run(ctx context.Context, catalog EpoProductDeliveriesResponse) (result SyncResult, err error) {
+	logger := s.client.logger.With("productID", s.ProductID)
+	result.ProductID = s.ProductID
+
+	if s.ledger != nil {
+		newDeliveries, errLedger := s.ledger.RecordCatalog(s.ProductID, catalog)
+		if errLedger != nil {
+			err = errLedger
+			return
+		}
+		logger.With("newDeliveries", len(newDeliveries)).Info("recorded catalog in ledger")
+	}
+
+	jobs := s.plan(catalog)
+	err = s.checkDiskSpace(jobs)
+	if err != nil {
+		return
+	}
+	logger.With("files", len(jobs)).Info("start sync")
+	s.manager.Enqueue(jobs...)
+	result.Results = s.manager.Run(ctx)
+
+	if s.ledger != nil {
+		for _, res := range result.Results {
+			// cancelled jobs were not attempted
+			if res.Err != nil && ctx.Err() != nil {
+				continue
+			}
+			if errLedger := s.ledger.RecordDownload(res); errLedger != nil {
+				err = errLedger
+				return
+			}
+		}
+	}
+
+	err = result.Err()
+	if err != nil {
+		logger.With("err", err).Error("sync failed")
+		return
+	}
+	logger.With("downloaded", len(result.Downloaded()), "skipped", len(result.Skipped())).Info("sync done")
+	return
+}
+
+// checkDiskSpace compares the planned download size with the free space of the destination
+func (s *ProductSync) checkDiskSpace(jobs []DownloadJob) (err error) {
+	// the free space of a storage is unknown
+	if s.diskSpaceCheck == DiskSpaceIgnore || s.manager.storage != nil {
+		return
+	}
+	logger := s.client.logger.With("productID", s.ProductID, "path", s.DestinationPath)
+	required := PlannedDownloadSize(jobs)
+	available, errFree := FreeDiskSpace(s.DestinationPath)
+	if errFree != nil {
+		logger.With("err", errFree).Warn("could not determine free disk space")
+		return
+	}
+	logger = logger.With("required", FormatFileSize(required), "available", FormatFileSize(available))
+	if required <= available {
+		logger.Debug("enough disk space")
+		return
+	}
+	err = &DiskSpaceError{Path: s.DestinationPath, Required: required, Available: available}
+	if s.diskSpaceCheck == DiskSpaceWarn {
+		logger.Warn("planned downloads do not fit on the destination filesystem")
+		return nil
+	}
+	logger.With("err", err).Error("planned downloads do not fit on the destination filesystem")
+	return
+}
+
+// Downloaded returns the results of the downloaded files
+func (r SyncResult) Downloaded() (results []DownloadResult) {
+	for _, res := range r.Results {
+		if res.Err == nil && !res.Skipped {
+			results = append(results, res)
+		}
+	}
+	return
+}
+
+// Skipped returns the results of the files that were already complete
+func (r SyncResult) Skipped() (results []DownloadResult) {
+	for _, res := range r.Results {
+		if res.Skipped {
+			results = append(results, res)
+		}
+	}
+	return
+}
+
+// Failed returns the results of the failed downloads
+func (r SyncResult) Failed() (results []DownloadResult) {
+	for _, res := range r.Results {
+		if res.Err != nil {
+			results = append(results, res)
+		}
+	}
+	return
+}
+
+// Err returns an error that wraps the first failed download or nil
+func (r SyncResult) Err() error {
+	failed := r.Failed()
+	if len(failed) == 0 {
+		return nil
+	}
+	return fmt.Errorf("%d of %d downloads failed: %w", len(failed), len(r.Results), failed[0].Err)
+}
@@ -0,0 +1,146 @@
+package epo_bbds
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/max-planck-innovation-competition/go-epo-bdds/pkg/epo_bbdstest"
+	"github.com/stretchr/testify/assert"
+)
+
+// testCatalog is a catalog with two deliveries of the PATSTAT product
+var testCatalog = EpoProductDeliveriesResponse{
+	ID:   17,
+	Name: "PATSTAT Global",
+	Deliveries: []EpoProductDelivery{
+		{
+			DeliveryID:                  1,
+			DeliveryName:                "PATSTAT Global 2023 Autumn",
+			DeliveryPublicationDatetime: time.Date(2023, 10, 1, 0, 0, 0, 0, time.UTC),
+			Files: []EpoDocDbFileItem{
+				{FileID: 11, FileName: "data_PATSTAT_Global_2023_Autumn_01.zip", FileSize: "1 KB"},
+				{FileID: 12, FileName: "data_PATSTAT_Global_2023_Autumn_02.zip", FileSize: "1 KB"},
+			},
+		},
+		{
+			DeliveryID:                  2,
+			DeliveryName:                "PATSTAT Global 2024 Spring",
+			DeliveryPublicationDatetime: time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC),
+			Files: []EpoDocDbFileItem{
+				{FileID: 21, FileName: "data_PATSTAT_Global_2024_Spring_01.zip", FileSize: "1 KB"},
+				{FileID: 22, FileName: "documentation_PATSTAT_Global_2024_Spring.zip", FileSize: "1 KB"},
+			},
+		},
+	},
+}
+
+// newCatalogTestServer serves the catalog of a single product with the fake bulk data service,
+// the content of each file is its file name
+func newCatalogTestServer(t *testing.T, catalog EpoProductDeliveriesResponse) (*epo_bbdstest.Server, *Client) {
+	return newTestClient(t, []epo_bbdstest.Product{catalogTestProduct(catalog)})
+}
+
+// catalogTestProduct converts the catalog to a product of the fake bulk data service
+func catalogTestProduct(catalog EpoProductDeliveriesResponse) epo_bbdstest.Product {
+	product := epo_bbdstest.Product{ID: catalog.ID, Name: catalog.Name, Description: catalog.Description}
+	for _, d := range catalog.Deliveries {
+		delivery := epo_bbdstest.Delivery{
+			ID:                  d.DeliveryID,
+			Name:                d.DeliveryName,
+			PublicationDatetime: d.DeliveryPublicationDatetime,
+			ExpiryDatetime:      d.DeliveryExpiryDatetime,
+		}
+		for _, f := range d.Files {
+			delivery.Files = append(delivery.Files, epo_bbdstest.File{
+				ID:                  f.FileID,
+				Name:                f.FileName,
+				Size:                f.FileSize,
+				Checksum:            f.FileChecksum,
+				PublicationDatetime: f.ItemPublicationDatetime,
+				Content:             []byte(f.FileName),
+			})
+		}
+		product.Deliveries = append(product.Deliveries, delivery)
+	}
+	return product
+}
+
+func TestProductSync(t *testing.T) {
+	ass := assert.New(t)
+	_, c := newCatalogTestServer(t, testCatalog)
+	dir := t.TempDir()
+
+	s := c.NewProductSync(EpoPatstatGlobalProductID, dir).
+		Select(
+			DeliveryPublishedBetween(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), time.Time{}),
+			FileNameGlob("data_*"),
+		)
+	result, err := s.Run(context.Background())
+	ass.NoError(err)
+	ass.Equal(EpoPatstatGlobalProductID, result.ProductID)
+	ass.Len(result.Results, 1)
+	ass.Len(result.Downloaded(), 1)
+	ass.Equal("data_PATSTAT_Global_2024_Spring_01.zip", result.Results[0].Job.File.FileName)
+	ass.True(pathExists(result.Results[0].Job.FilePath()))
+
+	// the second run skips the existing file
+	result, err = s.Run(context.Background())
+	ass.NoError(err)
+	ass.Len(result.Skipped(), 1)
+}
+
+func TestDownloadAllFilesUsesProductID(t *testing.T) {
+	ass := assert.New(t)
+	_, c := newCatalogTestServer(t, testCatalog)
+
+	newFiles, err := c.DownloadAllFiles(EpoPatstatGlobalProductID, t.TempDir())
+	ass.NoError(err)
+	ass.Len(newFiles, 4)
+}
+
+func TestSyncResultErr(t *testing.T) {
+	ass := assert.New(t)
+	result := SyncResult{Results: []DownloadResult{{}, {Err: ErrCanNotDownload}, {Skipped: true}}}
+	ass.ErrorIs(result.Err(), ErrCanNotDownload)
+	ass.Len(result.Failed(), 1)
+	ass.Len(result.Downloaded(), 1)
+	ass.NoError(SyncResult{}.Err())
+}
+
+func TestProductSyncWithFaults(t *testing.T) {
+	ass := assert.New(t)
+	catalog := testCatalog
+	catalog.Deliveries = []EpoProductDelivery{testCatalog.Deliveries[1]}
+	srv, c := newCatalogTestServer(t, catalog)
+	dir := t.TempDir()
+
+	srv.AddFaults(
+		// temporary errors of the catalog are retried
+		epo_bbdstest.Fault{Endpoint: epo_bbdstest.EndpointDeliveries, StatusCode: http.StatusTooManyRequests, RetryAfter: "0"},
+		epo_bbdstest.Fault{Endpoint: epo_bbdstest.EndpointDeliveries, StatusCode: http.StatusInternalServerError},
+		// a dropped download is resumed with a range request
+		epo_bbdstest.Fault{Endpoint: epo_bbdstest.EndpointDownload, Drop: true, DropAfter: 5},
+	)
+	s := c.NewProductSync(EpoPatstatGlobalProductID, dir)
+	s.DownloadManager().SetParallelism(1)
+	result, err := s.Run(context.Background())
+	ass.NoError(err)
+	ass.Len(result.Downloaded(), 2)
+	for _, res := range result.Results {
+		content, errRead := os.ReadFile(res.Job.FilePath())
+		ass.NoError(errRead)
+		ass.Equal(res.Job.File.FileName, string(content))
+	}
+	ass.Equal(3, srv.Requests(epo_bbdstest.EndpointDeliveries))
+	ass.Equal(3, srv.Requests(epo_bbdstest.EndpointDownload))
+	ass.Equal(1, srv.Requests(epo_bbdstest.EndpointLogin))
+
+	// an expired token is refreshed
+	srv.ExpireTokens()
+	_, err = c.GetEpoBddsFileItems("", EpoPatstatGlobalProductID)
+	ass.NoError(err)
+	ass.Equal(2, srv.Requests(epo_bbdstest.EndpointLogin))
+}
@@ -0,0 +1,174 @@
+package epo_bbds
+
+import (
+	"sync"
+	"time"
+)
+
+// DefaultProgressInterval is the default minimum time between two progress events of a file
+const DefaultProgressInterval = time.Second
+
+// Progress is the progress of a single file or of all files of a run
+type Progress struct {
+	FileName       string        // name of the file, empty for the progress of all files
+	Bytes          int64         // bytes that are complete, including resumed parts and skipped files
+	Total          int64         // total bytes, 0 if unknown
+	BytesPerSecond float64       // throughput of the bytes transferred since the start
+	ETA            time.Duration // estimated remaining time, 0 if unknown
+	Done           bool          // the file or all files are done
+}
+
+// Percent returns the completed percentage or 0 if the total is unknown
+func (p Progress) Percent() float64 {
+	if p.Total <= 0 {
+		return 0
+	}
+	return float64(p.Bytes) / float64(p.Total) * 100
+}
+
+// ProgressEvent is sent while the files of a download manager are downloaded
+type ProgressEvent struct {
+	File      Progress // progress of the file that changed
+	Overall   Progress // progress of all files of the run
+	FilesDone int      // number of files that are done
+	Files     int      // number of files of the run
+}
+
+// ProgressFunc receives the progress events of a download manager.
+// It is called from the download workers and should return quickly.
+type ProgressFunc func(event ProgressEvent)
+
+// runProgress tracks the progress of all jobs of a run
+type runProgress struct {
+	fn       ProgressFunc
+	interval time.Duration
+	now      func() time.Time
+
+	mu          sync.Mutex
+	start       time.Time
+	files       int
+	filesDone   int
+	bytes       int64 // complete bytes of all files
+	total       int64 // total bytes of all files
+	transferred int64 // bytes transferred in this run
+}
+
+// newRunProgress creates the progress of the jobs, the total is taken from the catalog
+func newRunProgress(fn ProgressFunc, interval time.Duration, jobs []DownloadJob) *runProgress {
+	r := &runProgress{
+		fn:       fn,
+		interval: interval,
+		now:      time.Now,
+		files:    len(jobs),
+	}
+	for _, job := range jobs {
+		r.total += job.File.SizeBytes()
+	}
+	r.start = r.now()
+	return r
+}
+
+// file creates the progress of a single job, nil if progress is not tracked
+func (r *runProgress) file(job DownloadJob) *fileProgress {
+	if r == nil {
+		return nil
+	}
+	return &fileProgress{
+		run:   r,
+		name:  job.File.FileName,
+		total: job.File.SizeBytes(),
+		start: r.now(),
+	}
+}
+
+// fileProgress tracks the progress of a single file.
+// All methods can be called on nil.
+type fileProgress struct {
+	run         *runProgress
+	name        string
+	bytes       int64
+	total       int64
+	transferred int64
+	start       time.Time
+	lastEvent   time.Time
+}
+
+// begin is called when the server responds, offset is the size of the resumed part
+// and contentLength the length of the response body or -1 if unknown
+func (f *fileProgress) begin(offset, contentLength int64) {
+	if f == nil {
+		return
+	}
+	r := f.run
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if contentLength >= 0 {
+		r.total += offset + contentLength - f.total
+		f.total = offset + contentLength
+	}
+	r.bytes += offset - f.bytes
+	f.bytes = offset
+}
+
+// Write counts the transferred bytes
+func (f *fileProgress) Write(p []byte) (n int, err error) {
+	if f == nil {
+		return len(p), nil
+	}
+	n = len(p)
+	r := f.run
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	f.bytes += int64(n)
+	f.transferred += int64(n)
+	r.bytes += int64(n)
+	r.transferred += int64(n)
+	now := r.now()
+	if now.Sub(f.lastEvent) >= r.interval {
+		f.lastEvent = now
+		r.emit(f, false, now)
+	}
+	return
+}
+
+// finish marks the file as done, a skipped file counts as complete
+func (f *fileProgress) finish(complete bool) {
+	if f == nil {
+		return
+	}
+	r := f.run
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if complete && f.bytes < f.total {
+		r.bytes += f.total - f.bytes
+		f.bytes = f.total
+	}
+	r.filesDone++
+	r.emit(f, true, r.now())
+}
+
+// emit sends an event, the lock must be held
+func (r *runProgress) emit(f *fileProgress, done bool, now time.Time) {
+	file := newProgress(f.name, f.bytes, f.total, f.transferred, now.Sub(f.start))
+	file.Done = done
+	overall := newProgress("", r.bytes, r.total, r.transferred, now.Sub(r.start))
+	overall.Done = r.filesDone == r.files
+	r.fn(ProgressEvent{
+		File:      file,
+		Overall:   overall,
+		FilesDone: r.filesDone,
+		Files:     r.files,
+	})
+}
+
+// newProgress calculates the throughput and the estimated remaining time
+func newProgress(name string, bytes, total, transferred int64, elapsed time.Duration) (p Progress) {
+	p = Progress{FileName: name, Bytes: bytes, Total: total}
+	if elapsed > 0 {
+		p.BytesPerSecond = float64(transferred) / elapsed.Seconds()
+	}
+	if p.BytesPerSecond > 0 && total > bytes {
+		p.ETA = time.Duration(float64(total-bytes) / p.BytesPerSecond * float64(time.Second))
+	}
+	return
+}
@@ -0,0 +1,76 @@
+package epo_bbds
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewProgress(t *testing.T) {
+	ass := assert.New(t)
+	p := newProgress("a.zip", 300, 1000, 200, 2*time.Second)
+	ass.Equal(100.0, p.BytesPerSecond)
+	ass.Equal(7*time.Second, p.ETA)
+	ass.Equal(30.0, p.Percent())
+
+	// unknown total
+	p = newProgress("a.zip", 300, 0, 300, time.Second)
+	ass.Equal(time.Duration(0), p.ETA)
+	ass.Equal(0.0, p.Percent())
+}
+
+func TestDownloadManagerProgress(t *testing.T) {
+	ass := assert.New(t)
+	_, c := newCatalogTestServer(t, testCatalog)
+	dir := t.TempDir()
+
+	var mu sync.Mutex
+	var events []ProgressEvent
+	m := NewDownloadManager(c).
+		SetProgressInterval(0).
+		SetProgress(func(event ProgressEvent) {
+			mu.Lock()
+			defer mu.Unlock()
+			events = append(events, event)
+		})
+	var total int64
+	for _, f := range testCatalog.Deliveries[1].Files {
+		m.Enqueue(DownloadJob{ProductID: EpoPatstatGlobalProductID, DeliveryID: 2, File: f, DestinationPath: dir})
+		total += int64(len(f.FileName))
+	}
+	results := m.Run(context.Background())
+	for _, res := range results {
+		ass.NoError(res.Err)
+	}
+
+	// the total is taken from the content length instead of the catalog size
+	last := events[len(events)-1]
+	ass.True(last.Overall.Done)
+	ass.Equal(2, last.FilesDone)
+	ass.Equal(2, last.Files)
+	ass.Equal(total, last.Overall.Bytes)
+	ass.Equal(total, last.Overall.Total)
+	done := 0
+	for _, event := range events {
+		if event.File.Done {
+			done++
+			ass.Equal(int64(len(event.File.FileName)), event.File.Bytes)
+			ass.Equal(event.File.Total, event.File.Bytes)
+		}
+	}
+	ass.Equal(2, done)
+
+	// skipped files count as complete with the catalog size
+	events = nil
+	for _, f := range testCatalog.Deliveries[1].Files {
+		m.Enqueue(DownloadJob{ProductID: EpoPatstatGlobalProductID, DeliveryID: 2, File: f, DestinationPath: dir})
+	}
+	m.Run(context.Background())
+	last = events[len(events)-1]
+	ass.True(last.Overall.Done)
+	ass.Equal(int64(2048), last.Overall.Bytes)
+	ass.Equal(time.Duration(0), last.Overall.ETA)
+}
@@ -0,0 +1,111 @@
+package epo_bbds
+
+import (
+	"context"
+	"io"
+	"sync"
+	"time"
+)
+
+// RateLimiter is a token bucket rate limiter.
+// The bucket is filled with rate tokens per second up to the burst size.
+// It is safe for concurrent use.
+type RateLimiter struct {
+	mu     sync.Mutex
+	rate   float64 // tokens per second
+	burst  float64 // size of the bucket
+	tokens float64 // available tokens, negative if reserved in advance
+	last   time.Time
+	now    func() time.Time // replaceable for tests
+}
+
+// NewRateLimiter creates a new rate limiter with a full bucket
+func NewRateLimiter(rate float64, burst int) *RateLimiter {
+	if burst < 1 {
+		burst = 1
+	}
+	return &RateLimiter{
+		rate:   rate,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   time.Now(),
+		now:    time.Now,
+	}
+}
+
+// Wait blocks until a token is available or the context is cancelled
+func (l *RateLimiter) Wait(ctx context.Context) error {
+	return l.WaitN(ctx, 1)
+}
+
+// WaitN blocks until n tokens are available or the context is cancelled.
+// n may be larger than the burst size, the tokens are then reserved in advance.
+func (l *RateLimiter) WaitN(ctx context.Context, n int) error {
+	if l == nil || l.rate <= 0 {
+		return ctx.Err()
+	}
+	delay := l.reserve(float64(n))
+	if delay <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		// give the tokens back
+		l.reserve(-float64(n))
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// reserve takes n tokens from the bucket
+// and returns the time until they are available
+func (l *RateLimiter) reserve(n float64) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	// fill the bucket
+	l.tokens += now.Sub(l.last).Seconds() * l.rate
+	if l.tokens > l.burst {
+		l.tokens = l.burst
+	}
+	l.last = now
+	l.tokens -= n
+	if l.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-l.tokens / l.rate * float64(time.Second))
+}
+
+// newRateLimitedReader limits the bytes per second read from the reader,
+// the reader is returned as is if the limiter has no rate
+func newRateLimitedReader(ctx context.Context, reader io.Reader, limiter *RateLimiter) io.Reader {
+	if limiter == nil || limiter.rate <= 0 {
+		return reader
+	}
+	return &rateLimitedReader{ctx: ctx, reader: reader, limiter: limiter}
+}
+
+// rateLimitedReader limits the bytes per second read from a reader
+type rateLimitedReader struct {
+	ctx     context.Context
+	reader  io.Reader
+	limiter *RateLimiter
+}
+
+// Read reads from the underlying reader and waits for the read bytes
+func (r *rateLimitedReader) Read(p []byte) (n int, err error) {
+	// read at most one burst at once
+	if burst := int(r.limiter.burst); len(p) > burst {
+		p = p[:burst]
+	}
+	n, err = r.reader.Read(p)
+	if n > 0 {
+		if errWait := r.limiter.WaitN(r.ctx, n); errWait != nil {
+			return n, errWait
+		}
+	}
+	return
+}
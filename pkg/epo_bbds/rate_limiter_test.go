@@ -0,0 +1,60 @@
+package epo_bbds
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRateLimiterReserve(t *testing.T) {
+	ass := assert.New(t)
+	now := time.Now()
+	l := NewRateLimiter(2, 2)
+	l.now = func() time.Time { return now }
+	l.last = now
+
+	// the bucket is full
+	ass.Equal(time.Duration(0), l.reserve(1))
+	ass.Equal(time.Duration(0), l.reserve(1))
+	// the bucket is empty, wait for half a second per token
+	ass.Equal(500*time.Millisecond, l.reserve(1))
+	ass.Equal(time.Second, l.reserve(1))
+
+	// refill
+	now = now.Add(10 * time.Second)
+	ass.Equal(time.Duration(0), l.reserve(2))
+}
+
+func TestRateLimiterWaitCancelled(t *testing.T) {
+	ass := assert.New(t)
+	l := NewRateLimiter(0.001, 1)
+	ass.NoError(l.Wait(context.Background()))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	ass.ErrorIs(l.Wait(ctx), context.DeadlineExceeded)
+
+	// no limit
+	var noLimit *RateLimiter
+	ass.NoError(noLimit.Wait(context.Background()))
+}
+
+func TestNewRateLimitedReader(t *testing.T) {
+	ass := assert.New(t)
+	r := strings.NewReader("content")
+
+	// no limit reads the reader directly
+	ass.Equal(io.Reader(r), newRateLimitedReader(context.Background(), r, nil))
+	ass.Equal(io.Reader(r), newRateLimitedReader(context.Background(), r, NewRateLimiter(0, 0)))
+
+	limited := newRateLimitedReader(context.Background(), r, NewRateLimiter(1000, 4))
+	p := make([]byte, 10)
+	n, err := limited.Read(p)
+	ass.NoError(err)
+	// one burst is read at once
+	ass.Equal(4, n)
+}
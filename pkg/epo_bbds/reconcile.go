@@ -0,0 +1,309 @@
+package epo_bbds
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// ReconcileStatus is the status of a path in a reconcile report
+type ReconcileStatus string
+
+const (
+	// ReconcileMissing is a file of the catalog that does not exist locally
+	ReconcileMissing ReconcileStatus = "missing"
+	// ReconcilePartial is a file of the catalog of which only a part file exists locally
+	ReconcilePartial ReconcileStatus = "partial"
+	// ReconcileSizeMismatch is a local file whose size does not match the catalog
+	ReconcileSizeMismatch ReconcileStatus = "size_mismatch"
+	// ReconcileChecksumMismatch is a local file whose checksum does not match the catalog
+	ReconcileChecksumMismatch ReconcileStatus = "checksum_mismatch"
+	// ReconcileUnexpected is a local file or directory that is not in the catalog
+	ReconcileUnexpected ReconcileStatus = "unexpected"
+	// ReconcileExpired is a local delivery directory whose delivery has expired
+	ReconcileExpired ReconcileStatus = "expired"
+	// ReconcileForeign is a local file in the product directory, e.g. a ledger,
+	// like the Foreign files of a mirror it is reported but not out of sync
+	ReconcileForeign ReconcileStatus = "foreign"
+)
+
+// ReconcileEntry is a path that is out of sync with the catalog
+type ReconcileEntry struct {
+	Status           ReconcileStatus `json:"status"`
+	Path             string          `json:"path"`
+	DeliveryID       int             `json:"deliveryId,omitempty"`
+	DeliveryName     string          `json:"deliveryName,omitempty"`
+	FileID           int             `json:"fileId,omitempty"`
+	FileName         string          `json:"fileName,omitempty"`
+	ExpectedSize     string          `json:"expectedSize,omitempty"` // size of the catalog e.g. "1.7 GB"
+	ActualSize       int64           `json:"actualSize,omitempty"`
+	ExpectedChecksum string          `json:"expectedChecksum,omitempty"`
+	ActualChecksum   string          `json:"actualChecksum,omitempty"`
+	ExpiredAt        *time.Time      `json:"expiredAt,omitempty"`
+}
+
+// ReconcileReport compares the catalog of a product with a local directory in the MirrorLayout
+type ReconcileReport struct {
+	ProductID   EpoBddsBProductID `json:"productId"`
+	ProductDir  string            `json:"productDir"`
+	CheckedAt   time.Time         `json:"checkedAt"`
+	InSyncFiles int               `json:"inSyncFiles"` // files that match the catalog
+	Entries     []ReconcileEntry  `json:"entries"`     // paths that are out of sync or foreign, ordered by path
+}
+
+// ReconcileOptions are the options of a reconciliation
+type ReconcileOptions struct {
+	Selectors       []Selector // only the selected files of the catalog are expected locally
+	VerifyChecksums bool       // hash the local files, otherwise only the sizes are compared
+	Now             time.Time  // time to check the expiry of the deliveries, time.Now() if zero
+}
+
+// InSync reports if the local directory matches the catalog,
+// foreign files do not count
+func (r ReconcileReport) InSync() bool {
+	for _, e := range r.Entries {
+		if e.Status != ReconcileForeign {
+			return false
+		}
+	}
+	return true
+}
+
+// ByStatus returns the entries with the status
+func (r ReconcileReport) ByStatus(status ReconcileStatus) (entries []ReconcileEntry) {
+	for _, e := range r.Entries {
+		if e.Status == status {
+			entries = append(entries, e)
+		}
+	}
+	return
+}
+
+// Summary counts the entries per status
+func (r ReconcileReport) Summary() map[ReconcileStatus]int {
+	summary := map[ReconcileStatus]int{}
+	for _, e := range r.Entries {
+		summary[e.Status]++
+	}
+	return summary
+}
+
+// WriteJSON writes the report as indented JSON
+func (r ReconcileReport) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
+
+// WriteText writes the report as a human-readable table
+func (r ReconcileReport) WriteText(w io.Writer) (err error) {
+	summary := r.Summary()
+	statuses := []ReconcileStatus{
+		ReconcileMissing, ReconcilePartial, ReconcileSizeMismatch,
+		ReconcileChecksumMismatch, ReconcileUnexpected, ReconcileExpired, ReconcileForeign,
+	}
+	counts := []string{fmt.Sprintf("%d in sync", r.InSyncFiles)}
+	for _, status := range statuses {
+		if summary[status] > 0 {
+			counts = append(counts, fmt.Sprintf("%d %s", summary[status], status))
+		}
+	}
+	_, err = fmt.Fprintf(w, "product %s in %s: %s\n", r.ProductID, r.ProductDir, strings.Join(counts, ", "))
+	if err != nil || len(r.Entries) == 0 {
+		return
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, e := range r.Entries {
+		path := e.Path
+		if rel, errRel := filepath.Rel(r.ProductDir, e.Path); errRel == nil {
+			path = rel
+		}
+		_, err = fmt.Fprintf(tw, "%s\t%s\t%s\n", e.Status, path, e.details())
+		if err != nil {
+			return
+		}
+	}
+	return tw.Flush()
+}
+
+// details describes the difference of the entry
+func (e ReconcileEntry) details() string {
+	switch e.Status {
+	case ReconcileMissing:
+		return "expected " + e.ExpectedSize
+	case ReconcilePartial, ReconcileSizeMismatch:
+		return fmt.Sprintf("expected %s, actual %s", e.ExpectedSize, FormatFileSize(e.ActualSize))
+	case ReconcileChecksumMismatch:
+		return fmt.Sprintf("expected %s, actual %s", e.ExpectedChecksum, e.ActualChecksum)
+	case ReconcileExpired:
+		if e.ExpiredAt != nil {
+			return "expired at " + e.ExpiredAt.Format(time.RFC3339)
+		}
+	}
+	return ""
+}
+
+// ReconcileContext fetches the catalog of the product and compares it with the local directory
+// <rootPath>/<product>/<deliveryName>/<file> of the MirrorLayout
+func ReconcileContext(ctx context.Context, productID EpoBddsBProductID, rootPath string, opts ReconcileOptions) (report ReconcileReport, err error) {
+	return defaultClient().ReconcileContext(ctx, productID, rootPath, opts)
+}
+
+// ReconcileContext fetches the catalog of the product and compares it with the local directory
+// <rootPath>/<product>/<deliveryName>/<file> of the MirrorLayout
+func (c *Client) ReconcileContext(ctx context.Context, productID EpoBddsBProductID, rootPath string, opts ReconcileOptions) (report ReconcileReport, err error) {
+	catalog, err := c.GetEpoBddsFileItemsContext(ctx, "", productID)
+	if err != nil {
+		c.logger.With("err", err, "productID", productID).Error("could not get files")
+		return
+	}
+	return ReconcileCatalog(ctx, catalog, rootPath, opts)
+}
+
+// Reconcile fetches the catalog and compares it with the local directory of the mirror
+func (m *Mirror) Reconcile(ctx context.Context, verifyChecksums bool) (report ReconcileReport, err error) {
+	return m.sync.client.ReconcileContext(ctx, m.sync.ProductID, m.sync.DestinationPath, ReconcileOptions{
+		Selectors:       m.sync.selectors,
+		VerifyChecksums: verifyChecksums,
+		Now:             m.now(),
+	})
+}
+
+// ReconcileCatalog compares the catalog with the local directory
+// <rootPath>/<product>/<deliveryName>/<file> of the MirrorLayout.
+// The files of expired deliveries are not expected locally,
+// existing directories of expired deliveries are reported as expired.
+// If two deliveries have the same directory name, an error that matches ErrDeliveryDirCollision is returned.
+func ReconcileCatalog(ctx context.Context, catalog EpoProductDeliveriesResponse, rootPath string, opts ReconcileOptions) (report ReconcileReport, err error) {
+	now := opts.Now
+	if now.IsZero() {
+		now = time.Now()
+	}
+	report.ProductID = EpoBddsBProductID(strconv.Itoa(catalog.ID))
+	report.ProductDir = filepath.Join(rootPath, string(report.ProductID))
+	report.CheckedAt = now
+
+	deliveries, err := deliveryDirs(catalog.Deliveries)
+	if err != nil {
+		return
+	}
+	for _, d := range catalog.Deliveries {
+		if deliveryExpired(d, now) {
+			continue
+		}
+		for _, f := range d.Files {
+			if !selectAll(opts.Selectors, d, f) {
+				continue
+			}
+			if ctx.Err() != nil {
+				return report, ctx.Err()
+			}
+			var entry *ReconcileEntry
+			entry, err = reconcileFile(filepath.Join(report.ProductDir, DeliveryDirName(d), f.FileName), d, f, opts.VerifyChecksums)
+			if err != nil {
+				return
+			}
+			if entry == nil {
+				report.InSyncFiles++
+				continue
+			}
+			report.Entries = append(report.Entries, *entry)
+		}
+	}
+
+	// local paths that are not in the catalog
+	entries, err := os.ReadDir(report.ProductDir)
+	if errors.Is(err, os.ErrNotExist) {
+		err = nil
+	}
+	if err != nil {
+		return
+	}
+	for _, entry := range entries {
+		p := filepath.Join(report.ProductDir, entry.Name())
+		d, ok := deliveries[entry.Name()]
+		switch {
+		case !entry.IsDir():
+			report.Entries = append(report.Entries, ReconcileEntry{Status: ReconcileForeign, Path: p})
+		case !ok:
+			report.Entries = append(report.Entries, ReconcileEntry{Status: ReconcileUnexpected, Path: p})
+		case deliveryExpired(d, now):
+			report.Entries = append(report.Entries, ReconcileEntry{
+				Status:       ReconcileExpired,
+				Path:         p,
+				DeliveryID:   d.DeliveryID,
+				DeliveryName: d.DeliveryName,
+				ExpiredAt:    d.DeliveryExpiryDatetime,
+			})
+		default:
+			var unexpected []string
+			unexpected, err = unexpectedFiles(p, d)
+			if err != nil {
+				return
+			}
+			for _, u := range unexpected {
+				report.Entries = append(report.Entries, ReconcileEntry{Status: ReconcileUnexpected, Path: u, DeliveryID: d.DeliveryID, DeliveryName: d.DeliveryName})
+			}
+		}
+	}
+
+	sort.SliceStable(report.Entries, func(i, j int) bool {
+		return report.Entries[i].Path < report.Entries[j].Path
+	})
+	return
+}
+
+// reconcileFile compares a local file with the file of the catalog,
+// nil is returned if the file is in sync
+func reconcileFile(filePath string, delivery EpoProductDelivery, file EpoDocDbFileItem, verifyChecksum bool) (entry *ReconcileEntry, err error) {
+	entry = &ReconcileEntry{
+		Path:         filePath,
+		DeliveryID:   delivery.DeliveryID,
+		DeliveryName: delivery.DeliveryName,
+		FileID:       file.FileID,
+		FileName:     file.FileName,
+		ExpectedSize: file.FileSize,
+	}
+	info, err := os.Stat(filePath)
+	if errors.Is(err, os.ErrNotExist) {
+		entry.Status = ReconcileMissing
+		if part, errPart := os.Stat(filePath + PartFileSuffix); errPart == nil {
+			entry.Status = ReconcilePartial
+			entry.Path = filePath + PartFileSuffix
+			entry.ActualSize = part.Size()
+		}
+		return entry, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	entry.ActualSize = info.Size()
+	// sizes that can not be parsed are not compared
+	if _, errParse := ParseFileSize(file.FileSize); errParse == nil && !FileSizeMatches(file.FileSize, info.Size()) {
+		entry.Status = ReconcileSizeMismatch
+		return entry, nil
+	}
+	if verifyChecksum && file.FileChecksum != "" {
+		errVerify := VerifyFileChecksum(filePath, file.FileChecksum)
+		var checksumErr *ChecksumError
+		if errors.As(errVerify, &checksumErr) {
+			entry.Status = ReconcileChecksumMismatch
+			entry.ExpectedChecksum = checksumErr.Expected
+			entry.ActualChecksum = checksumErr.Actual
+			return entry, nil
+		}
+		if errVerify != nil {
+			return nil, errVerify
+		}
+	}
+	return nil, nil
+}
@@ -0,0 +1,196 @@
+package epo_bbds
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// sizedCatalog returns the checksum catalog with the exact sizes of the file contents
+func sizedCatalog() EpoProductDeliveriesResponse {
+	catalog := checksumCatalog()
+	for _, d := range catalog.Deliveries {
+		for i, f := range d.Files {
+			d.Files[i].FileSize = fmt.Sprintf("%d B", len(f.FileName))
+		}
+	}
+	return catalog
+}
+
+func writeTestFile(t *testing.T, path, content string) {
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReconcileCatalog(t *testing.T) {
+	ass := assert.New(t)
+	root := t.TempDir()
+	catalog := sizedCatalog()
+	expiry := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	catalog.Deliveries[0].DeliveryExpiryDatetime = &expiry
+	productDir := filepath.Join(root, "17")
+	spring := filepath.Join(productDir, "PATSTAT Global 2024 Spring")
+
+	// expired delivery
+	writeTestFile(t, filepath.Join(productDir, "PATSTAT Global 2023 Autumn", "data_PATSTAT_Global_2023_Autumn_01.zip"), "old")
+	// in sync
+	writeTestFile(t, filepath.Join(spring, "data_PATSTAT_Global_2024_Spring_01.zip"), "data_PATSTAT_Global_2024_Spring_01.zip")
+	// checksum mismatch with the same size
+	writeTestFile(t, filepath.Join(spring, "documentation_PATSTAT_Global_2024_Spring.zip"), strings.Repeat("x", len("documentation_PATSTAT_Global_2024_Spring.zip")))
+	// unexpected
+	writeTestFile(t, filepath.Join(spring, "notes.txt"), "notes")
+	writeTestFile(t, filepath.Join(productDir, "PATSTAT Global 2019 Autumn", "a.zip"), "a")
+	// foreign
+	writeTestFile(t, filepath.Join(productDir, "ledger.db"), "ledger")
+	opts := ReconcileOptions{Now: time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)}
+
+	report, err := ReconcileCatalog(context.Background(), catalog, root, opts)
+	ass.NoError(err)
+	ass.Equal(EpoPatstatGlobalProductID, report.ProductID)
+	ass.Equal(productDir, report.ProductDir)
+	ass.Equal(2, report.InSyncFiles)
+	ass.False(report.InSync())
+	if ass.Len(report.Entries, 4) {
+		ass.Equal(filepath.Join(productDir, "PATSTAT Global 2019 Autumn"), report.Entries[0].Path)
+		ass.Equal(ReconcileUnexpected, report.Entries[0].Status)
+		ass.Equal(ReconcileExpired, report.Entries[1].Status)
+		ass.Equal(&expiry, report.Entries[1].ExpiredAt)
+		ass.Equal(filepath.Join(spring, "notes.txt"), report.Entries[2].Path)
+		ass.Equal(filepath.Join(productDir, "ledger.db"), report.Entries[3].Path)
+		ass.Equal(ReconcileForeign, report.Entries[3].Status)
+	}
+
+	// checksums
+	opts.VerifyChecksums = true
+	report, err = ReconcileCatalog(context.Background(), catalog, root, opts)
+	ass.NoError(err)
+	ass.Equal(1, report.InSyncFiles)
+	if mismatches := report.ByStatus(ReconcileChecksumMismatch); ass.Len(mismatches, 1) {
+		ass.Equal("documentation_PATSTAT_Global_2024_Spring.zip", mismatches[0].FileName)
+		ass.Equal(catalog.Deliveries[1].Files[1].FileChecksum, mismatches[0].ExpectedChecksum)
+		ass.NotEmpty(mismatches[0].ActualChecksum)
+	}
+
+	// missing, partial and size mismatch
+	ass.NoError(os.Remove(filepath.Join(spring, "documentation_PATSTAT_Global_2024_Spring.zip")))
+	writeTestFile(t, filepath.Join(spring, "documentation_PATSTAT_Global_2024_Spring.zip.part"), "doc")
+	writeTestFile(t, filepath.Join(spring, "data_PATSTAT_Global_2024_Spring_01.zip"), "short")
+	opts.Now = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	report, err = ReconcileCatalog(context.Background(), catalog, root, opts)
+	ass.NoError(err)
+	ass.Equal(map[ReconcileStatus]int{
+		ReconcileMissing:      1,
+		ReconcilePartial:      1,
+		ReconcileSizeMismatch: 2,
+		ReconcileUnexpected:   2,
+		ReconcileForeign:      1,
+	}, report.Summary())
+	partial := report.ByStatus(ReconcilePartial)
+	if ass.Len(partial, 1) {
+		ass.Equal(int64(3), partial[0].ActualSize)
+	}
+
+	// the selected files only
+	opts.Selectors = []Selector{FileNameGlob("documentation_*")}
+	report, err = ReconcileCatalog(context.Background(), catalog, root, opts)
+	ass.NoError(err)
+	ass.Len(report.ByStatus(ReconcileSizeMismatch), 0)
+	ass.Len(report.ByStatus(ReconcileMissing), 0)
+	ass.Len(report.ByStatus(ReconcilePartial), 1)
+}
+
+func TestReconcileReportWrite(t *testing.T) {
+	ass := assert.New(t)
+	expiry := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	report := ReconcileReport{
+		ProductID:   EpoPatstatGlobalProductID,
+		ProductDir:  "/data/17",
+		CheckedAt:   time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC),
+		InSyncFiles: 4,
+		Entries: []ReconcileEntry{
+			{Status: ReconcileExpired, Path: "/data/17/2023 Autumn", DeliveryID: 1, ExpiredAt: &expiry},
+			{Status: ReconcileMissing, Path: "/data/17/2024 Spring/a.zip", FileName: "a.zip", ExpectedSize: "1.5 GB"},
+			{Status: ReconcileSizeMismatch, Path: "/data/17/2024 Spring/b.zip", ExpectedSize: "1 KB", ActualSize: 10},
+		},
+	}
+
+	var text bytes.Buffer
+	ass.NoError(report.WriteText(&text))
+	ass.Equal(`product 17 in /data/17: 4 in sync, 1 missing, 1 size_mismatch, 1 expired
+expired        2023 Autumn        expired at 2024-03-01T00:00:00Z
+missing        2024 Spring/a.zip  expected 1.5 GB
+size_mismatch  2024 Spring/b.zip  expected 1 KB, actual 10 B
+`, text.String())
+
+	var buf bytes.Buffer
+	ass.NoError(report.WriteJSON(&buf))
+	var decoded ReconcileReport
+	ass.NoError(json.Unmarshal(buf.Bytes(), &decoded))
+	ass.Equal(report, decoded)
+	ass.Contains(buf.String(), `"status": "size_mismatch"`)
+
+	text.Reset()
+	ass.NoError(ReconcileReport{ProductID: "3", ProductDir: "/data/3"}.WriteText(&text))
+	ass.Equal("product 3 in /data/3: 0 in sync\n", text.String())
+}
+
+func TestMirrorReconcile(t *testing.T) {
+	ass := assert.New(t)
+	_, c := newCatalogTestServer(t, sizedCatalog())
+	root := t.TempDir()
+	ctx := context.Background()
+
+	m := c.NewMirror(EpoPatstatGlobalProductID, root).Select(FileNameGlob("data_*"))
+	report, err := m.Reconcile(ctx, true)
+	ass.NoError(err)
+	ass.Len(report.ByStatus(ReconcileMissing), 3)
+
+	_, err = m.Run(ctx)
+	ass.NoError(err)
+	// a ledger next to the deliveries is foreign like in the mirror
+	ledger := filepath.Join(m.ProductDir(), "ledger.db")
+	writeTestFile(t, ledger, "ledger")
+	report, err = m.Reconcile(ctx, true)
+	ass.NoError(err)
+	ass.True(report.InSync())
+	ass.Equal(3, report.InSyncFiles)
+	if ass.Len(report.ByStatus(ReconcileForeign), 1) {
+		ass.Equal(ledger, report.Entries[0].Path)
+	}
+}
+
+func TestFileSizeMatches(t *testing.T) {
+	ass := assert.New(t)
+	for _, tc := range []struct {
+		size  string
+		bytes int64
+		match bool
+	}{
+		{"10 B", 10, true},
+		{"10 B", 11, false},
+		{"1 KB", 1024, true},
+		{"1 KB", 1000, true},
+		{"1 KB", 1400, true},
+		{"1 KB", 1600, false},
+		{"1.5 GB", 3 << 29, true},
+		{"1.5 GB", 1_500_000_000, true},
+		{"1.5 GB", 1_400_000_000, false},
+		{"1.53 MB", 1_604_321, true},
+		{"1.53 MB", 1_700_000, false},
+		{"unknown", 10, false},
+	} {
+		ass.Equal(tc.match, FileSizeMatches(tc.size, tc.bytes), "%s %d", tc.size, tc.bytes)
+	}
+}
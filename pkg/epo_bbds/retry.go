@@ -0,0 +1,114 @@
+package epo_bbds
+
+import (
+	"io"
+	"math"
+	"math/rand"
+	"net/http"
+	"time"
+)
+
+// RetryPolicy configures the retries of failed requests.
+// Requests are retried on network errors and on the status codes 429, 500, 502, 503 and 504.
+type RetryPolicy struct {
+	MaxAttempts   int           // attempts including the first request, 1 disables retries
+	BaseDelay     time.Duration // delay before the first retry, doubled for every retry
+	MaxDelay      time.Duration // maximum backoff between two attempts, 0 for no maximum, a longer Retry-After of the server is honoured
+	MaxRetryAfter time.Duration // longest Retry-After of the server that is waited for, 0 for no maximum, a longer one is returned as APIError
+}
+
+// DefaultRetryPolicy is the retry policy of a new client
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts:   5,
+	BaseDelay:     time.Second,
+	MaxDelay:      time.Minute,
+	MaxRetryAfter: 5 * time.Minute,
+}
+
+// WithRetryPolicy sets the retry policy of the client
+func WithRetryPolicy(policy RetryPolicy) ClientOption {
+	return func(c *Client) {
+		c.retryPolicy = policy
+	}
+}
+
+// backoff returns the delay before the given retry with exponential backoff and jitter
+func (p RetryPolicy) backoff(retry int) time.Duration {
+	delay := p.BaseDelay
+	for i := 1; i < retry && delay > 0 && delay <= math.MaxInt64/2; i++ {
+		delay *= 2
+		if p.MaxDelay > 0 && delay >= p.MaxDelay {
+			break
+		}
+	}
+	if p.MaxDelay > 0 && delay > p.MaxDelay {
+		delay = p.MaxDelay
+	}
+	if delay <= 0 {
+		return 0
+	}
+	// random delay between half and the full delay
+	return delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1))
+}
+
+// send sends the request and retries it according to the retry policy.
+// The response of the last attempt is returned.
+func (c *Client) send(req *http.Request) (resp *http.Response, err error) {
+	ctx := req.Context()
+	for attempt := 1; ; attempt++ {
+		try := req
+		if attempt > 1 {
+			try = req.Clone(ctx)
+			if req.GetBody != nil {
+				try.Body, err = req.GetBody()
+				if err != nil {
+					c.logger.With("err", err).Error("failed to rewind request body")
+					return nil, err
+				}
+			}
+		}
+		resp, err = c.httpClient.Do(try)
+
+		// check if the request can be retried
+		var retryAfter time.Duration
+		switch {
+		case err != nil && ctx.Err() != nil:
+			return
+		case err != nil:
+		case retryableStatusCode(resp.StatusCode):
+			retryAfter = parseRetryAfter(resp.Header.Get("Retry-After"), time.Now())
+		default:
+			return
+		}
+		if attempt >= c.retryPolicy.MaxAttempts {
+			return
+		}
+		// the caller decides whether to wait that long, e.g. with the RetryAfter of the APIError
+		if c.retryPolicy.MaxRetryAfter > 0 && retryAfter > c.retryPolicy.MaxRetryAfter {
+			c.logger.With("url", req.URL.String(), "retryAfter", retryAfter).Warn("server asked to retry later than the maximum, give up")
+			return
+		}
+
+		// the Retry-After of the server is the minimum delay
+		delay := c.retryPolicy.backoff(attempt)
+		if retryAfter > delay {
+			delay = retryAfter
+		}
+		logger := c.logger.With("url", req.URL.String(), "attempt", attempt, "delay", delay)
+		if err != nil {
+			logger.With("err", err).Warn("request failed, retry")
+		} else {
+			logger.With("statusCode", resp.StatusCode).Warn("server responded with temporary error, retry")
+			_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, maxErrorBodySize))
+			_ = resp.Body.Close()
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
@@ -0,0 +1,149 @@
+package epo_bbds
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fastRetries is a retry policy without noticeable delays for tests
+var fastRetries = RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: 5 * time.Millisecond}
+
+func TestRetryTemporaryErrors(t *testing.T) {
+	ass := assert.New(t)
+	var requests int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch atomic.AddInt32(&requests, 1) {
+		case 1:
+			w.WriteHeader(http.StatusServiceUnavailable)
+		case 2:
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+		default:
+			_ = json.NewEncoder(w).Encode([]EpoProductItem{{ID: 3}})
+		}
+	}))
+	defer srv.Close()
+
+	c := NewClient(WithBaseURL(srv.URL), WithRetryPolicy(fastRetries))
+	products, err := c.GetProducts("Bearer x")
+	ass.NoError(err)
+	ass.Len(products, 1)
+	ass.Equal(int32(3), atomic.LoadInt32(&requests))
+}
+
+func TestRetryGivesUp(t *testing.T) {
+	ass := assert.New(t)
+	var requests int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.Header().Set("Retry-After", "1")
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer srv.Close()
+
+	c := NewClient(WithBaseURL(srv.URL), WithRetryPolicy(RetryPolicy{MaxAttempts: 2, BaseDelay: time.Millisecond, MaxDelay: 5 * time.Millisecond}))
+	start := time.Now()
+	_, err := c.GetProducts("Bearer x")
+	// the Retry-After is longer than the maximum delay and is honoured
+	ass.GreaterOrEqual(time.Since(start), time.Second)
+	ass.ErrorIs(err, ErrNo200StatusCode)
+	var apiErr *APIError
+	ass.True(errors.As(err, &apiErr))
+	ass.Equal(http.StatusBadGateway, apiErr.StatusCode)
+	ass.Equal(time.Second, apiErr.RetryAfter)
+	ass.True(apiErr.Temporary())
+	ass.Equal(int32(2), atomic.LoadInt32(&requests))
+}
+
+func TestRetryAfterLongerThanMaximum(t *testing.T) {
+	ass := assert.New(t)
+	var requests int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.Header().Set("Retry-After", "86400")
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	policy := fastRetries
+	policy.MaxRetryAfter = time.Minute
+	c := NewClient(WithBaseURL(srv.URL), WithRetryPolicy(policy))
+	start := time.Now()
+	_, err := c.GetProducts("Bearer x")
+	// the client does not wait a day, the caller gets the Retry-After
+	ass.Less(time.Since(start), time.Minute)
+	var apiErr *APIError
+	if ass.ErrorAs(err, &apiErr) {
+		ass.Equal(http.StatusServiceUnavailable, apiErr.StatusCode)
+		ass.Equal(24*time.Hour, apiErr.RetryAfter)
+	}
+	ass.Equal(int32(1), atomic.LoadInt32(&requests))
+}
+
+func TestRetryNotOnClientErrors(t *testing.T) {
+	ass := assert.New(t)
+	var requests int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer srv.Close()
+
+	c := NewClient(WithBaseURL(srv.URL), WithRetryPolicy(fastRetries))
+	_, err := c.GetProducts("Bearer x")
+	ass.ErrorIs(err, ErrNo200StatusCode)
+	ass.Equal(int32(1), atomic.LoadInt32(&requests))
+}
+
+func TestRetryNetworkErrors(t *testing.T) {
+	ass := assert.New(t)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	url := srv.URL
+	srv.Close()
+
+	c := NewClient(WithBaseURL(url), WithRetryPolicy(fastRetries))
+	_, err := c.GetProducts("Bearer x")
+	ass.Error(err)
+}
+
+func TestRetryCancelledDuringBackoff(t *testing.T) {
+	ass := assert.New(t)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	c := NewClient(WithBaseURL(srv.URL), WithRetryPolicy(RetryPolicy{MaxAttempts: 5, BaseDelay: time.Hour}))
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err := c.GetProductsContext(ctx, "Bearer x")
+	ass.ErrorIs(err, context.DeadlineExceeded)
+}
+
+func TestRetryBackoff(t *testing.T) {
+	ass := assert.New(t)
+	p := RetryPolicy{BaseDelay: time.Second, MaxDelay: 10 * time.Second}
+	for retry, max := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second, 10 * time.Second, 10 * time.Second} {
+		delay := p.backoff(retry + 1)
+		ass.GreaterOrEqual(delay, max/2)
+		ass.LessOrEqual(delay, max)
+	}
+
+	// without a maximum the delay is doubled for every retry
+	p = RetryPolicy{BaseDelay: time.Second}
+	for retry, max := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second, 16 * time.Second, 32 * time.Second} {
+		delay := p.backoff(retry + 1)
+		ass.GreaterOrEqual(delay, max/2)
+		ass.LessOrEqual(delay, max)
+	}
+	// no overflow
+	ass.Greater(p.backoff(100), time.Duration(0))
+}
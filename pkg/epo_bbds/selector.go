@@ -0,0 +1,91 @@
+package epo_bbds
+
+import (
+	"path"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Selector decides if a file of a delivery is selected for a sync
+type Selector func(delivery EpoProductDelivery, file EpoDocDbFileItem) bool
+
+// DeliveryPublishedBetween selects the deliveries that were published in the range [from, to).
+// A zero time leaves the range open on that side.
+func DeliveryPublishedBetween(from, to time.Time) Selector {
+	return func(delivery EpoProductDelivery, file EpoDocDbFileItem) bool {
+		published := delivery.DeliveryPublicationDatetime
+		if !from.IsZero() && published.Before(from) {
+			return false
+		}
+		if !to.IsZero() && !published.Before(to) {
+			return false
+		}
+		return true
+	}
+}
+
+// DeliveryNameContains selects the deliveries whose name contains the given text,
+// the comparison is case-insensitive
+func DeliveryNameContains(text string) Selector {
+	text = strings.ToLower(text)
+	return func(delivery EpoProductDelivery, file EpoDocDbFileItem) bool {
+		return strings.Contains(strings.ToLower(delivery.DeliveryName), text)
+	}
+}
+
+// DeliveryNames selects the deliveries with exactly one of the given names
+func DeliveryNames(names ...string) Selector {
+	set := map[string]struct{}{}
+	for _, name := range names {
+		set[name] = struct{}{}
+	}
+	return func(delivery EpoProductDelivery, file EpoDocDbFileItem) bool {
+		_, ok := set[delivery.DeliveryName]
+		return ok
+	}
+}
+
+// FileNameGlob selects the files whose name matches one of the glob patterns
+// e.g. "*CreateDelete*" or "docdb_xml_bck_*_A.zip"
+func FileNameGlob(patterns ...string) Selector {
+	return func(delivery EpoProductDelivery, file EpoDocDbFileItem) bool {
+		for _, pattern := range patterns {
+			if ok, err := path.Match(pattern, file.FileName); err == nil && ok {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// regexFileNameToken splits a file name into its tokens
+var regexFileNameToken = regexp.MustCompile(`[-_.]`)
+
+// FileAuthorities selects the files whose name contains one of the authorities e.g. EP, WO, etc.
+// The authority must be a separate part of the file name,
+// e.g. DOCDB-202402-CreateDelete-PubDate20240105AndBefore-EP-0001.zip
+func FileAuthorities(authorities ...string) Selector {
+	set := map[string]struct{}{}
+	for _, authority := range authorities {
+		set[strings.ToUpper(authority)] = struct{}{}
+	}
+	return func(delivery EpoProductDelivery, file EpoDocDbFileItem) bool {
+		for _, token := range regexFileNameToken.Split(file.FileName, -1) {
+			if _, ok := set[strings.ToUpper(token)]; ok {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// selectAll reports if all selectors select the file
+func selectAll(selectors []Selector, delivery EpoProductDelivery, file EpoDocDbFileItem) bool {
+	for _, selector := range selectors {
+		if !selector(delivery, file) {
+			return false
+		}
+	}
+	return true
+}
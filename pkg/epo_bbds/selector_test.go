@@ -0,0 +1,40 @@
+package epo_bbds
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSelectors(t *testing.T) {
+	ass := assert.New(t)
+	d := EpoProductDelivery{
+		DeliveryName:                "DOCDB 2024 week 02",
+		DeliveryPublicationDatetime: time.Date(2024, 1, 10, 0, 0, 0, 0, time.UTC),
+	}
+	createDelete := EpoDocDbFileItem{FileName: "docdb_xml_202402_CreateDelete_001.zip"}
+	amend := EpoDocDbFileItem{FileName: "docdb_xml_202402_Amend_001.zip"}
+	ep := EpoDocDbFileItem{FileName: "DOCDB-202402-CreateDelete-PubDate20240105AndBefore-EP-0001.zip"}
+
+	ass.True(DeliveryPublishedBetween(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), time.Time{})(d, amend))
+	ass.True(DeliveryPublishedBetween(time.Time{}, time.Date(2024, 1, 11, 0, 0, 0, 0, time.UTC))(d, amend))
+	ass.False(DeliveryPublishedBetween(time.Date(2024, 1, 11, 0, 0, 0, 0, time.UTC), time.Time{})(d, amend))
+	ass.False(DeliveryPublishedBetween(time.Time{}, time.Date(2024, 1, 10, 0, 0, 0, 0, time.UTC))(d, amend))
+
+	ass.True(DeliveryNameContains("week 02")(d, amend))
+	ass.False(DeliveryNameContains("back file")(d, amend))
+	ass.True(DeliveryNames("DOCDB 2024 week 02")(d, amend))
+	ass.False(DeliveryNames("DOCDB")(d, amend))
+
+	ass.True(FileNameGlob("*CreateDelete*")(d, createDelete))
+	ass.False(FileNameGlob("*CreateDelete*")(d, amend))
+	ass.True(FileNameGlob("*CreateDelete*", "*Amend*")(d, amend))
+
+	ass.True(FileAuthorities("ep", "WO")(d, ep))
+	ass.False(FileAuthorities("US")(d, ep))
+	ass.False(FileAuthorities("EP")(d, createDelete))
+
+	ass.True(selectAll(nil, d, amend))
+	ass.False(selectAll([]Selector{FileNameGlob("*Amend*"), DeliveryNameContains("back file")}, d, amend))
+}
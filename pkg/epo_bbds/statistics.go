@@ -0,0 +1,386 @@
+package epo_bbds
+
+import (
+	"archive/zip"
+	"bufio"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+/*
+statistics csv:
+DOCA095,202324,DOCDB-202324,616732,478
+Status,NrOfPNStatus,CC,KC,FirstPN,LastPN,FirstPubDate,LastPubDate,NrOfPN
+A,616732,AP,A,36,4072,19881206,20170316,24
+A,616732,AP,A0,8500014,2017009807,19850801,20170331,63
+A,616732,AR,A1,000008,248143,19730919,20230419,863
+A,616732,AR,A2,023762,240811,19910228,20230426,90
+A,616732,AR,A4,067548,123822,20091014,20230118,2
+A,616732,AT,A,A4386,A13742003,19761215,20060115,32
+...
+
+*/
+
+// ErrInvalidStatistics is returned if a statistics file can not be parsed
+var ErrInvalidStatistics = errors.New("invalid statistics")
+
+// ErrNoStatistics is returned if a bulk zip has no statistics file
+var ErrNoStatistics = errors.New("no statistics")
+
+// ErrStatisticsMismatch is returned if the processed documents do not match the statistics
+var ErrStatisticsMismatch = errors.New("processed documents do not match the statistics")
+
+// statisticsColumns are the columns of the rows of a statistics file
+var statisticsColumns = []string{"Status", "NrOfPNStatus", "CC", "KC", "FirstPN", "LastPN", "FirstPubDate", "LastPubDate", "NrOfPN"}
+
+// StatisticsHeader is the first record of a statistics file, e.g. DOCA095,202324,DOCDB-202324,616732,478
+type StatisticsHeader struct {
+	ProductCode string // e.g. DOCA095
+	Week        int    // publication week YYYYWW, e.g. 202324
+	PackageName string // e.g. DOCDB-202324
+	NrOfPN      int    // publications of the package
+	NrOfRows    int    // rows of the statistics
+}
+
+// StatsStruct is a row of a statistics file with the publications of a country and a kind
+type StatsStruct struct {
+	Status       string // e.g. A
+	NrOfPNStatus int    // publications with the status
+	CC           string // country code
+	KC           string // kind code
+	FirstPN      string // first publication number, e.g. 000008 or A4386
+	LastPN       string // last publication number
+	FirstPubDate time.Time
+	LastPubDate  time.Time
+	NrOfPN       int // publications of the country and the kind
+}
+
+// Statistics is a statistics file of a DocDB bulk package
+type Statistics struct {
+	Header StatisticsHeader
+	Rows   []StatsStruct
+}
+
+// StatisticsKey is a country and a kind code
+type StatisticsKey struct {
+	CC string
+	KC string
+}
+
+// ExpectedCounts sums the publications per country and kind
+func (s Statistics) ExpectedCounts() map[StatisticsKey]int {
+	counts := map[StatisticsKey]int{}
+	for _, row := range s.Rows {
+		counts[StatisticsKey{CC: row.CC, KC: row.KC}] += row.NrOfPN
+	}
+	return counts
+}
+
+// crReader converts the line breaks of old Mac files (CR) and Windows files (CRLF) to LF,
+// the statistics files are delivered with CR line breaks
+type crReader struct {
+	r  *bufio.Reader
+	cr bool // last byte was CR
+}
+
+func (c *crReader) Read(p []byte) (n int, err error) {
+	for n < len(p) {
+		var b byte
+		b, err = c.r.ReadByte()
+		if err != nil {
+			if n > 0 {
+				err = nil
+			}
+			return
+		}
+		if b == '\n' && c.cr {
+			c.cr = false
+			continue
+		}
+		c.cr = b == '\r'
+		if c.cr {
+			b = '\n'
+		}
+		p[n] = b
+		n++
+	}
+	return
+}
+
+// newStatisticsCSVReader creates a csv reader that accepts records with different numbers of fields
+func newStatisticsCSVReader(r io.Reader) *csv.Reader {
+	reader := csv.NewReader(&crReader{r: bufio.NewReader(r)})
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	return reader
+}
+
+// ParseStatisticsFile parses a statistics file, e.g. statistics_202324_Amend_001.csv
+func ParseStatisticsFile(filePath string) (stats Statistics, err error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		slog.With("err", err, "filePath", filePath).Error("failed to open statistics file")
+		return
+	}
+	defer f.Close()
+	return ParseStatisticsCSV(f)
+}
+
+// ParseStatisticsCSV parses the header record, the column record and the rows of a statistics file.
+// The publication numbers are kept as strings, because they can have leading zeros or letters.
+// The number of rows must match the header record.
+func ParseStatisticsCSV(r io.Reader) (stats Statistics, err error) {
+	reader := newStatisticsCSVReader(r)
+
+	record, err := reader.Read()
+	if err != nil {
+		return stats, fmt.Errorf("%w: header record: %v", ErrInvalidStatistics, err)
+	}
+	stats.Header, err = parseStatisticsHeader(record)
+	if err != nil {
+		return
+	}
+
+	record, err = reader.Read()
+	if err != nil {
+		return stats, fmt.Errorf("%w: column record: %v", ErrInvalidStatistics, err)
+	}
+	columns, err := mapStatisticsColumns(record)
+	if err != nil {
+		return
+	}
+
+	for {
+		record, err = reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return stats, fmt.Errorf("%w: %v", ErrInvalidStatistics, err)
+		}
+		line, _ := reader.FieldPos(0)
+		var row StatsStruct
+		row, err = parseStatisticsRow(record, columns)
+		if err != nil {
+			return stats, fmt.Errorf("%w: line %d %v", ErrInvalidStatistics, line, err)
+		}
+		stats.Rows = append(stats.Rows, row)
+	}
+	err = nil
+	if len(stats.Rows) != stats.Header.NrOfRows {
+		return stats, fmt.Errorf("%w: %d rows, expected %d", ErrInvalidStatistics, len(stats.Rows), stats.Header.NrOfRows)
+	}
+	return
+}
+
+// parseStatisticsHeader parses the header record
+func parseStatisticsHeader(record []string) (header StatisticsHeader, err error) {
+	if len(record) != 5 {
+		err = fmt.Errorf("%w: header record has %d fields, expected 5", ErrInvalidStatistics, len(record))
+		return
+	}
+	header.ProductCode = record[0]
+	header.PackageName = record[2]
+	for i, dst := range map[int]*int{1: &header.Week, 3: &header.NrOfPN, 4: &header.NrOfRows} {
+		*dst, err = strconv.Atoi(record[i])
+		if err != nil {
+			err = fmt.Errorf("%w: header record field %d: %v", ErrInvalidStatistics, i+1, err)
+			return
+		}
+	}
+	return
+}
+
+// mapStatisticsColumns maps the columns of the rows to their index in the record
+func mapStatisticsColumns(record []string) (columns map[string]int, err error) {
+	columns = map[string]int{}
+	for i, name := range record {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, name := range statisticsColumns {
+		if _, ok := columns[strings.ToLower(name)]; !ok {
+			return nil, fmt.Errorf("%w: missing column %s", ErrInvalidStatistics, name)
+		}
+	}
+	return
+}
+
+// parseStatisticsRow parses a row of the statistics
+func parseStatisticsRow(record []string, columns map[string]int) (row StatsStruct, err error) {
+	value := func(name string) (string, error) {
+		i := columns[strings.ToLower(name)]
+		if i >= len(record) {
+			return "", fmt.Errorf("column %s: missing value", name)
+		}
+		return strings.TrimSpace(record[i]), nil
+	}
+	for _, name := range statisticsColumns {
+		var v string
+		v, err = value(name)
+		if err != nil {
+			return
+		}
+		switch name {
+		case "Status":
+			row.Status = v
+		case "CC":
+			row.CC = v
+		case "KC":
+			row.KC = v
+		case "FirstPN":
+			row.FirstPN = v
+		case "LastPN":
+			row.LastPN = v
+		case "NrOfPNStatus":
+			row.NrOfPNStatus, err = strconv.Atoi(v)
+		case "NrOfPN":
+			row.NrOfPN, err = strconv.Atoi(v)
+		case "FirstPubDate":
+			row.FirstPubDate, err = parseStatisticsDate(v)
+		case "LastPubDate":
+			row.LastPubDate, err = parseStatisticsDate(v)
+		}
+		if err != nil {
+			return row, fmt.Errorf("column %s: %v", name, err)
+		}
+	}
+	return
+}
+
+// parseStatisticsDate parses a date in the format YYYYMMDD, an empty value is the zero time
+func parseStatisticsDate(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	return time.Parse("20060102", value)
+}
+
+// ReadBulkZipStatistics reads the statistics file (statistics_*.csv) of a bulk zip
+func ReadBulkZipStatistics(filePath string) (stats Statistics, err error) {
+	reader, err := zip.OpenReader(filePath)
+	if err != nil {
+		return
+	}
+	defer reader.Close()
+
+	for _, f := range reader.File {
+		name := strings.ToLower(path.Base(f.Name))
+		if !strings.HasPrefix(name, "statistics") || path.Ext(name) != ".csv" {
+			continue
+		}
+		var r io.ReadCloser
+		r, err = f.Open()
+		if err != nil {
+			return
+		}
+		defer r.Close()
+		return ParseStatisticsCSV(r)
+	}
+	err = ErrNoStatistics
+	return
+}
+
+// PublicationCounter counts the processed publications per country and kind.
+// It is safe for concurrent use, e.g. by the content handler of an epo_docdb.Processor.
+type PublicationCounter struct {
+	mu     sync.Mutex
+	counts map[StatisticsKey]int
+}
+
+// NewPublicationCounter creates a new publication counter
+func NewPublicationCounter() *PublicationCounter {
+	return &PublicationCounter{counts: map[StatisticsKey]int{}}
+}
+
+// Count counts a publication of a country and a kind
+func (c *PublicationCounter) Count(country, kind string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.counts[StatisticsKey{CC: country, KC: kind}]++
+}
+
+// Counts returns a copy of the counts
+func (c *PublicationCounter) Counts() map[StatisticsKey]int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	counts := make(map[StatisticsKey]int, len(c.counts))
+	for k, v := range c.counts {
+		counts[k] = v
+	}
+	return counts
+}
+
+// StatisticsDiscrepancy is a country and a kind whose processed publications do not match the statistics
+type StatisticsDiscrepancy struct {
+	StatisticsKey
+	Expected int // publications of the statistics
+	Observed int // processed publications
+}
+
+// StatisticsReport compares the statistics of a bulk package with the processed publications
+type StatisticsReport struct {
+	Header        StatisticsHeader
+	Matched       int                     // countries and kinds that match the statistics
+	ExpectedTotal int                     // publications of the statistics
+	ObservedTotal int                     // processed publications
+	Discrepancies []StatisticsDiscrepancy // ordered by country and kind
+}
+
+// Consistent reports if the processed publications match the statistics
+func (r StatisticsReport) Consistent() bool {
+	return len(r.Discrepancies) == 0
+}
+
+// Err returns an error that matches ErrStatisticsMismatch if the processed publications do not match the statistics
+func (r StatisticsReport) Err() error {
+	if r.Consistent() {
+		return nil
+	}
+	return fmt.Errorf("%w: %s has %d discrepancies, %d publications processed, %d expected",
+		ErrStatisticsMismatch, r.Header.PackageName, len(r.Discrepancies), r.ObservedTotal, r.ExpectedTotal)
+}
+
+// Reconcile compares the publications per country and kind with the publications that were observed
+// while processing the bulk package, e.g. the counts of a PublicationCounter
+func (s Statistics) Reconcile(observed map[StatisticsKey]int) (report StatisticsReport) {
+	report.Header = s.Header
+	expected := s.ExpectedCounts()
+	keys := map[StatisticsKey]struct{}{}
+	for k, v := range expected {
+		keys[k] = struct{}{}
+		report.ExpectedTotal += v
+	}
+	for k, v := range observed {
+		keys[k] = struct{}{}
+		report.ObservedTotal += v
+	}
+	for k := range keys {
+		if expected[k] == observed[k] {
+			report.Matched++
+			continue
+		}
+		report.Discrepancies = append(report.Discrepancies, StatisticsDiscrepancy{
+			StatisticsKey: k,
+			Expected:      expected[k],
+			Observed:      observed[k],
+		})
+	}
+	sort.Slice(report.Discrepancies, func(i, j int) bool {
+		a, b := report.Discrepancies[i], report.Discrepancies[j]
+		if a.CC != b.CC {
+			return a.CC < b.CC
+		}
+		return a.KC < b.KC
+	})
+	return
+}
@@ -0,0 +1,161 @@
+package epo_bbds
+
+import (
+	"archive/zip"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testStatistics = "DOCA095,202324,DOCDB-202324,616732,3\r" +
+	"Status,NrOfPNStatus,CC,KC,FirstPN,LastPN,FirstPubDate,LastPubDate,NrOfPN\r" +
+	"A,616732,AR,A1,000008,248143,19730919,20230419,863\r" +
+	"A,616732,AT,A,A4386,A13742003,19761215,20060115,32\r" +
+	"D,12,AT,A,A4390,A4391,19761215,19761215,2\r"
+
+func TestParseStatisticsCSV(t *testing.T) {
+	ass := assert.New(t)
+	stats, err := ParseStatisticsCSV(strings.NewReader(testStatistics))
+	if !ass.NoError(err) {
+		return
+	}
+	ass.Equal(StatisticsHeader{ProductCode: "DOCA095", Week: 202324, PackageName: "DOCDB-202324", NrOfPN: 616732, NrOfRows: 3}, stats.Header)
+	ass.Len(stats.Rows, 3)
+	ass.Equal(StatsStruct{
+		Status:       "A",
+		NrOfPNStatus: 616732,
+		CC:           "AT",
+		KC:           "A",
+		FirstPN:      "A4386",
+		LastPN:       "A13742003",
+		FirstPubDate: time.Date(1976, 12, 15, 0, 0, 0, 0, time.UTC),
+		LastPubDate:  time.Date(2006, 1, 15, 0, 0, 0, 0, time.UTC),
+		NrOfPN:       32,
+	}, stats.Rows[1])
+	ass.Equal("000008", stats.Rows[0].FirstPN)
+	ass.Equal(map[StatisticsKey]int{{CC: "AR", KC: "A1"}: 863, {CC: "AT", KC: "A"}: 34}, stats.ExpectedCounts())
+
+	// CRLF and LF line breaks
+	for _, lineBreak := range []string{"\r\n", "\n"} {
+		stats, err = ParseStatisticsCSV(strings.NewReader(strings.ReplaceAll(testStatistics, "\r", lineBreak)))
+		ass.NoError(err)
+		ass.Len(stats.Rows, 3)
+	}
+}
+
+func TestParseStatisticsCSVErrors(t *testing.T) {
+	ass := assert.New(t)
+	lines := strings.Split(strings.TrimSuffix(testStatistics, "\r"), "\r")
+	for name, content := range map[string]string{
+		"empty":          "",
+		"header fields":  "DOCA095,202324\r" + strings.Join(lines[1:], "\r"),
+		"header week":    "DOCA095,2023-24,DOCDB-202324,616732,3\r" + strings.Join(lines[1:], "\r"),
+		"missing column": lines[0] + "\rStatus,CC,KC\r" + strings.Join(lines[2:], "\r"),
+		"rows":           strings.Join(lines[:4], "\r"),
+		"number":         strings.Join(lines[:4], "\r") + "\rA,616732,AT,B,1,2,19761215,19761215,x",
+		"date":           strings.Join(lines[:4], "\r") + "\rA,616732,AT,B,1,2,15.12.1976,19761215,1",
+		"short row":      strings.Join(lines[:4], "\r") + "\rA,616732,AT",
+	} {
+		_, err := ParseStatisticsCSV(strings.NewReader(content))
+		ass.ErrorIs(err, ErrInvalidStatistics, name)
+	}
+
+	_, err := ParseStatisticsCSV(strings.NewReader(strings.Join(lines[:4], "\r") + "\rA,616732,AT,B,1,2,19761215,19761215,x"))
+	ass.ErrorContains(err, "line 5 column NrOfPN")
+}
+
+func TestParseStatisticsFile(t *testing.T) {
+	ass := assert.New(t)
+	stats, err := ParseStatisticsFile("test-data/statistics_202324_Amend_001.csv")
+	if !ass.NoError(err) {
+		return
+	}
+	ass.Equal("DOCDB-202324", stats.Header.PackageName)
+	ass.Len(stats.Rows, stats.Header.NrOfRows)
+	ass.Equal(StatsStruct{
+		Status:       "A",
+		NrOfPNStatus: 616732,
+		CC:           "AP",
+		KC:           "A",
+		FirstPN:      "36",
+		LastPN:       "4072",
+		FirstPubDate: time.Date(1988, 12, 6, 0, 0, 0, 0, time.UTC),
+		LastPubDate:  time.Date(2017, 3, 16, 0, 0, 0, 0, time.UTC),
+		NrOfPN:       24,
+	}, stats.Rows[0])
+
+	_, err = ParseStatisticsFile("test-data/missing.csv")
+	ass.Error(err)
+}
+
+func TestReadBulkZipStatistics(t *testing.T) {
+	ass := assert.New(t)
+	filePath := filepath.Join(t.TempDir(), "docdb_xml_202324_Amend_001.zip")
+	f, err := os.Create(filePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zw := zip.NewWriter(f)
+	w, _ := zw.Create("docdb_xml_202324_Amend_001/Root/statistics_202324_Amend_001.csv")
+	_, _ = w.Write([]byte(testStatistics))
+	ass.NoError(zw.Close())
+	ass.NoError(f.Close())
+
+	stats, err := ReadBulkZipStatistics(filePath)
+	ass.NoError(err)
+	ass.Len(stats.Rows, 3)
+
+	writeBulkZip(t, filePath, map[string]int{testDocAP: 1}, testDocAP)
+	_, err = ReadBulkZipStatistics(filePath)
+	ass.ErrorIs(err, ErrNoStatistics)
+}
+
+func TestStatisticsReconcile(t *testing.T) {
+	ass := assert.New(t)
+	stats, err := ParseStatisticsCSV(strings.NewReader(testStatistics))
+	if !ass.NoError(err) {
+		return
+	}
+
+	counter := NewPublicationCounter()
+	var wg sync.WaitGroup
+	for i := 0; i < 863; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			counter.Count("AR", "A1")
+		}()
+	}
+	wg.Wait()
+	for i := 0; i < 34; i++ {
+		counter.Count("AT", "A")
+	}
+	report := stats.Reconcile(counter.Counts())
+	ass.True(report.Consistent())
+	ass.NoError(report.Err())
+	ass.Equal(2, report.Matched)
+	ass.Equal(897, report.ExpectedTotal)
+	ass.Equal(897, report.ObservedTotal)
+
+	// a missing publication and an unexpected kind
+	counter.Count("BE", "A1")
+	counts := counter.Counts()
+	counts[StatisticsKey{CC: "AT", KC: "A"}]--
+	report = stats.Reconcile(counts)
+	ass.False(report.Consistent())
+	ass.Equal(1, report.Matched)
+	ass.Equal([]StatisticsDiscrepancy{
+		{StatisticsKey: StatisticsKey{CC: "AT", KC: "A"}, Expected: 34, Observed: 33},
+		{StatisticsKey: StatisticsKey{CC: "BE", KC: "A1"}, Expected: 0, Observed: 1},
+	}, report.Discrepancies)
+	ass.ErrorIs(report.Err(), ErrStatisticsMismatch)
+	ass.ErrorContains(report.Err(), "DOCDB-202324 has 2 discrepancies, 897 publications processed, 897 expected")
+
+	// the counts of the counter are a copy
+	ass.Equal(34, counter.Counts()[StatisticsKey{CC: "AT", KC: "A"}])
+}
@@ -0,0 +1,146 @@
+package epo_bbds
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// DefaultTokenRefreshMargin is the time before the expiry of a token
+// at which the token source already requests a new token
+const DefaultTokenRefreshMargin = time.Minute
+
+// TokenSource caches the authorization token of a client
+// and refreshes it shortly before it expires.
+// It is safe for concurrent use.
+type TokenSource struct {
+	client        *Client
+	refreshMargin time.Duration
+	now           func() time.Time // replaceable for tests
+
+	mu     sync.Mutex
+	token  string
+	expiry time.Time // zero if the token does not expire
+}
+
+// NewTokenSource creates a new token source that logs in with the given client
+func NewTokenSource(c *Client) *TokenSource {
+	return &TokenSource{
+		client:        c,
+		refreshMargin: DefaultTokenRefreshMargin,
+		now:           time.Now,
+	}
+}
+
+// Token returns a valid authorization token.
+// A new token is requested if there is no cached token
+// or the cached token expires within the refresh margin.
+// The login request times out after DefaultRequestTimeout.
+func (ts *TokenSource) Token() (token string, err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultRequestTimeout)
+	defer cancel()
+	return ts.TokenContext(ctx)
+}
+
+// TokenContext returns a valid authorization token, see Token
+func (ts *TokenSource) TokenContext(ctx context.Context) (token string, err error) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	if ts.token != "" && (ts.expiry.IsZero() || ts.now().Add(ts.refreshMargin).Before(ts.expiry)) {
+		return ts.token, nil
+	}
+
+	ts.client.logger.Debug("request new authorization token")
+	response, err := ts.client.requestToken(ctx)
+	if err != nil {
+		return
+	}
+	token, err = buildAuthToken(response)
+	if err != nil {
+		return
+	}
+	ts.token = token
+	ts.expiry = time.Time{}
+	if response.ExpiresIn > 0 {
+		ts.expiry = ts.now().Add(time.Duration(response.ExpiresIn) * time.Second)
+	}
+	return
+}
+
+// Invalidate drops the cached token,
+// the next call of Token requests a new one
+func (ts *TokenSource) Invalidate() {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	ts.token = ""
+	ts.expiry = time.Time{}
+}
+
+// invalidateToken drops the cached token only if it is still the given token.
+// This prevents concurrent requests from dropping a token that was just refreshed.
+func (ts *TokenSource) invalidateToken(token string) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	if ts.token == token {
+		ts.token = ""
+		ts.expiry = time.Time{}
+	}
+}
+
+// WithTokenRefreshMargin sets the time before the expiry of a token
+// at which the client already requests a new token
+func WithTokenRefreshMargin(margin time.Duration) ClientOption {
+	return func(c *Client) {
+		c.tokens.refreshMargin = margin
+	}
+}
+
+// TokenSource returns the token source of the client
+func (c *Client) TokenSource() *TokenSource {
+	return c.tokens
+}
+
+// do sends the request with the given authorization token.
+// If the token is empty, the token of the client's token source is used
+// and the request is retried once with a new token if the server responds with 401.
+// Temporary errors are retried according to the retry policy of the client.
+func (c *Client) do(req *http.Request, token string) (resp *http.Response, err error) {
+	if token != "" {
+		req.Header.Set(AuthHeader, token)
+		return c.send(req)
+	}
+
+	token, err = c.tokens.TokenContext(req.Context())
+	if err != nil {
+		return
+	}
+	req.Header.Set(AuthHeader, token)
+	resp, err = c.send(req)
+	if err != nil || resp.StatusCode != http.StatusUnauthorized {
+		return
+	}
+
+	// the token was rejected, retry once with a new token
+	c.logger.Debug("authorization token rejected, retry with new token")
+	_, _ = io.Copy(io.Discard, resp.Body)
+	_ = resp.Body.Close()
+	c.tokens.invalidateToken(token)
+
+	retry := req.Clone(req.Context())
+	if req.GetBody != nil {
+		retry.Body, err = req.GetBody()
+		if err != nil {
+			c.logger.With("err", err).Error("failed to rewind request body")
+			return nil, err
+		}
+	}
+	token, err = c.tokens.TokenContext(req.Context())
+	if err != nil {
+		return nil, err
+	}
+	retry.Header.Set(AuthHeader, token)
+	return c.send(retry)
+}
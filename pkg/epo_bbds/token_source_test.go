@@ -0,0 +1,72 @@
+package epo_bbds
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/max-planck-innovation-competition/go-epo-bdds/pkg/epo_bbdstest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTokenSourceCachesToken(t *testing.T) {
+	ass := assert.New(t)
+	srv, c := newTestClient(t, []epo_bbdstest.Product{{ID: 3}})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			token, err := c.TokenSource().Token()
+			ass.NoError(err)
+			ass.Equal("Bearer fake-token-1", token)
+		}()
+	}
+	wg.Wait()
+	ass.Equal(1, srv.Requests(epo_bbdstest.EndpointLogin))
+}
+
+func TestTokenSourceRefreshesBeforeExpiry(t *testing.T) {
+	ass := assert.New(t)
+	srv, c := newTestClient(t, []epo_bbdstest.Product{{ID: 3}})
+	ts := c.TokenSource()
+	now := time.Now()
+	ts.now = func() time.Time { return now }
+
+	token, err := ts.Token()
+	ass.NoError(err)
+	ass.Equal("Bearer fake-token-1", token)
+
+	// still valid
+	now = now.Add(58 * time.Minute)
+	token, err = ts.Token()
+	ass.NoError(err)
+	ass.Equal("Bearer fake-token-1", token)
+
+	// within the refresh margin
+	now = now.Add(90 * time.Second)
+	token, err = ts.Token()
+	ass.NoError(err)
+	ass.Equal("Bearer fake-token-2", token)
+	ass.Equal(2, srv.Requests(epo_bbdstest.EndpointLogin))
+}
+
+func TestClientRetriesOnUnauthorized(t *testing.T) {
+	ass := assert.New(t)
+	srv, c := newTestClient(t, []epo_bbdstest.Product{{ID: 3}})
+
+	_, err := c.TokenSource().Token()
+	ass.NoError(err)
+	// the server invalidates the cached token
+	srv.ExpireTokens()
+
+	products, err := c.GetProducts("")
+	ass.NoError(err)
+	ass.Len(products, 1)
+	ass.Equal(2, srv.Requests(epo_bbdstest.EndpointLogin))
+
+	// explicit tokens are not retried
+	_, err = c.GetProducts("Bearer fake-token-1")
+	ass.ErrorIs(err, ErrNo200StatusCode)
+}
@@ -0,0 +1,224 @@
+package epo_bbds
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/max-planck-innovation-competition/go-epo-bdds/pkg/epo_bbdstest"
+	"github.com/stretchr/testify/assert"
+)
+
+// writePEM writes a PEM block to a file in the directory
+func writePEM(t *testing.T, dir, name, blockType string, der []byte) string {
+	path := filepath.Join(dir, name)
+	err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// writeClientCertificate writes a self-signed client certificate and its key
+func writeClientCertificate(t *testing.T, dir string) (certPath, keyPath string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "go-epo-bdds test client"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certPath = writePEM(t, dir, "client.crt", "CERTIFICATE", der)
+	keyPath = writePEM(t, dir, "client.key", "EC PRIVATE KEY", keyDer)
+	return
+}
+
+func TestTransportConfigCABundleAndClientCertificate(t *testing.T) {
+	ass := assert.New(t)
+	dir := t.TempDir()
+
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if len(r.TLS.PeerCertificates) > 0 {
+			_, _ = io.WriteString(w, r.TLS.PeerCertificates[0].Subject.CommonName)
+		}
+	}))
+	srv.TLS = &tls.Config{ClientAuth: tls.RequestClientCert}
+	srv.StartTLS()
+	defer srv.Close()
+	caPath := writePEM(t, dir, "ca.pem", "CERTIFICATE", srv.Certificate().Raw)
+
+	get := func(cfg TransportConfig) (string, error) {
+		httpClient, err := cfg.NewHTTPClient()
+		if err != nil {
+			return "", err
+		}
+		resp, err := httpClient.Get(srv.URL)
+		if err != nil {
+			return "", err
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		return string(body), err
+	}
+
+	// the certificate of the server is not trusted by default
+	_, err := get(TransportConfig{})
+	ass.Error(err)
+
+	body, err := get(TransportConfig{CABundlePath: caPath})
+	ass.NoError(err)
+	ass.Empty(body)
+
+	certPath, keyPath := writeClientCertificate(t, dir)
+	body, err = get(TransportConfig{CABundlePath: caPath, ClientCertPath: certPath, ClientKeyPath: keyPath})
+	ass.NoError(err)
+	ass.Equal("go-epo-bdds test client", body)
+}
+
+func TestTransportConfigInvalid(t *testing.T) {
+	ass := assert.New(t)
+	dir := t.TempDir()
+	certPath, keyPath := writeClientCertificate(t, dir)
+	emptyPath := filepath.Join(dir, "empty.pem")
+	_ = os.WriteFile(emptyPath, []byte("no certificates"), 0o600)
+
+	for name, cfg := range map[string]TransportConfig{
+		"proxy without host": {ProxyURL: "proxy.example.com:3128"},
+		"invalid proxy":      {ProxyURL: "http://[::1"},
+		"missing ca bundle":  {CABundlePath: filepath.Join(dir, "missing.pem")},
+		"empty ca bundle":    {CABundlePath: emptyPath},
+		"only certificate":   {ClientCertPath: certPath},
+		"swapped key":        {ClientCertPath: keyPath, ClientKeyPath: certPath},
+	} {
+		_, err := cfg.NewHTTPClient()
+		ass.ErrorIs(err, ErrInvalidTransportConfig, name)
+	}
+
+	transport, err := TransportConfig{
+		ResponseHeaderTimeout: time.Minute,
+		MaxIdleConnsPerHost:   8,
+		MaxConnsPerHost:       16,
+	}.NewTransport()
+	ass.NoError(err)
+	ass.Equal(time.Minute, transport.ResponseHeaderTimeout)
+	ass.Equal(8, transport.MaxIdleConnsPerHost)
+	ass.Equal(16, transport.MaxConnsPerHost)
+	// the defaults are kept
+	ass.NotNil(transport.Proxy)
+	ass.Equal(http.DefaultTransport.(*http.Transport).MaxIdleConns, transport.MaxIdleConns)
+}
+
+// testProxy is a forward proxy for http requests that records the requested urls
+type testProxy struct {
+	mu    sync.Mutex
+	paths []string
+	auth  []string
+}
+
+func (p *testProxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	p.mu.Lock()
+	p.paths = append(p.paths, r.URL.Path)
+	p.auth = append(p.auth, r.Header.Get("Proxy-Authorization"))
+	p.mu.Unlock()
+
+	out := r.Clone(r.Context())
+	out.RequestURI = ""
+	out.Header.Del("Proxy-Authorization")
+	resp, err := http.DefaultTransport.RoundTrip(out)
+	if err != nil {
+		w.WriteHeader(http.StatusBadGateway)
+		return
+	}
+	defer resp.Body.Close()
+	for k, v := range resp.Header {
+		w.Header()[k] = v
+	}
+	w.WriteHeader(resp.StatusCode)
+	_, _ = io.Copy(w, resp.Body)
+}
+
+func TestTransportConfigProxy(t *testing.T) {
+	ass := assert.New(t)
+	proxy := &testProxy{}
+	proxySrv := httptest.NewServer(proxy)
+	defer proxySrv.Close()
+
+	httpClient, err := TransportConfig{
+		ProxyURL: strings.Replace(proxySrv.URL, "http://", "http://proxy-user:proxy-secret@", 1),
+	}.NewHTTPClient()
+	ass.NoError(err)
+	_, c := newTestClient(t, []epo_bbdstest.Product{catalogTestProduct(testCatalog)}, WithHTTPClient(httpClient))
+	ctx := context.Background()
+
+	// login, catalog and download are sent through the proxy
+	_, err = c.GetProductsContext(ctx, "")
+	ass.NoError(err)
+	_, err = c.GetEpoBddsFileItemsContext(ctx, "", EpoPatstatGlobalProductID)
+	ass.NoError(err)
+	err = c.DownloadFileContext(ctx, "", EpoPatstatGlobalProductID, 2, 21, t.TempDir(), "a.zip")
+	ass.NoError(err)
+
+	ass.Equal([]string{
+		"/login",
+		"/api/products/",
+		"/api/products/17",
+		"/api/products/17/delivery/2/file/21/download",
+	}, proxy.paths)
+	basic := "Basic " + base64.StdEncoding.EncodeToString([]byte("proxy-user:proxy-secret"))
+	for _, auth := range proxy.auth {
+		ass.Equal(basic, auth)
+	}
+}
+
+func TestSetDefaultClientOptions(t *testing.T) {
+	ass := assert.New(t)
+	srv := epo_bbdstest.NewServer()
+	defer srv.Close()
+	proxy := &testProxy{}
+	proxySrv := httptest.NewServer(proxy)
+	defer proxySrv.Close()
+
+	httpClient, err := TransportConfig{ProxyURL: proxySrv.URL}.NewHTTPClient()
+	ass.NoError(err)
+	SetDefaultClientOptions(
+		WithLoginURL(srv.LoginURL()),
+		WithCredentials(epo_bbdstest.DefaultUsername, epo_bbdstest.DefaultPassword),
+		WithHTTPClient(httpClient),
+	)
+	t.Cleanup(func() {
+		SetDefaultClientOptions()
+	})
+
+	token, err := GetAuthorizationTokenContext(context.Background())
+	ass.NoError(err)
+	ass.NotEmpty(token)
+	ass.Equal([]string{"/login"}, proxy.paths)
+}
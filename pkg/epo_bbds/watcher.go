@@ -0,0 +1,386 @@
+package epo_bbds
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/max-planck-innovation-competition/go-epo-bdds/pkg/storage"
+)
+
+// ErrLocalFilesRequired is returned by a hook that can only handle files on the local filesystem
+// if the files of the delivery were downloaded to a storage
+var ErrLocalFilesRequired = errors.New("hook requires the files on the local filesystem")
+
+// DefaultWatchInterval is the default interval between two polls of a watcher
+const DefaultWatchInterval = time.Hour
+
+// WatcherCursor is the persisted state of a watcher
+type WatcherCursor struct {
+	ProductID  EpoBddsBProductID `json:"productId"`
+	Done       []int             `json:"doneDeliveryIds"` // deliveries that were processed by all hooks or expired before
+	LastPollAt time.Time         `json:"lastPollAt"`
+}
+
+// IsDone checks if the delivery was processed by all hooks or expired before
+func (c WatcherCursor) IsDone(deliveryID int) bool {
+	for _, id := range c.Done {
+		if id == deliveryID {
+			return true
+		}
+	}
+	return false
+}
+
+// LoadWatcherCursor loads the cursor of a watcher, an empty cursor is returned if the file does not exist
+func LoadWatcherCursor(filePath string) (cursor WatcherCursor, err error) {
+	content, err := os.ReadFile(filePath)
+	if errors.Is(err, os.ErrNotExist) {
+		return cursor, nil
+	}
+	if err != nil {
+		return
+	}
+	err = json.Unmarshal(content, &cursor)
+	if err != nil {
+		err = fmt.Errorf("failed to parse watcher cursor %s: %w", filePath, err)
+	}
+	return
+}
+
+// saveWatcherCursor saves the cursor atomically,
+// a crash while saving leaves the previous cursor
+func saveWatcherCursor(filePath string, cursor WatcherCursor) (err error) {
+	content, err := json.MarshalIndent(cursor, "", "  ")
+	if err != nil {
+		return
+	}
+	err = os.MkdirAll(filepath.Dir(filePath), os.ModePerm)
+	if err != nil {
+		return
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(filePath), filepath.Base(filePath)+".tmp-*")
+	if err != nil {
+		return
+	}
+	defer func() {
+		if err != nil {
+			_ = os.Remove(tmp.Name())
+		}
+	}()
+	_, err = tmp.Write(content)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if errClose := tmp.Close(); err == nil {
+		err = errClose
+	}
+	if err != nil {
+		return
+	}
+	return os.Rename(tmp.Name(), filePath)
+}
+
+// DeliveryEvent is passed to the hooks after the files of a new delivery were downloaded
+type DeliveryEvent struct {
+	ProductID EpoBddsBProductID
+	Delivery  EpoProductDelivery
+	Results   []DownloadResult // downloaded and skipped files of the delivery
+	Storage   storage.Storage  // storage the files were downloaded to, nil for the local filesystem
+}
+
+// FilePaths returns the local paths of the files of the delivery,
+// or the names of the objects if the files were downloaded to a storage
+func (e DeliveryEvent) FilePaths() (paths []string) {
+	for _, res := range e.Results {
+		if e.Storage != nil {
+			paths = append(paths, res.Job.ObjectName())
+		} else {
+			paths = append(paths, res.Job.FilePath())
+		}
+	}
+	return
+}
+
+// DeliveryHook is called after the files of a new delivery were downloaded.
+// A delivery is only marked as done if all hooks succeed,
+// otherwise all hooks are called again for the delivery with the next poll,
+// so hooks should be idempotent.
+type DeliveryHook func(ctx context.Context, event DeliveryEvent) error
+
+// BulkFileProcessor processes a downloaded bulk zip file, e.g. an epo_docdb.Processor
+type BulkFileProcessor interface {
+	ProcessBulkZipFileContext(ctx context.Context, filePath string) error
+}
+
+// BulkStreamProcessor processes a bulk zip file that is read from a stream, e.g. an epo_docdb.Processor
+type BulkStreamProcessor interface {
+	ProcessBulkZipStreamContext(ctx context.Context, name string, r io.Reader) error
+}
+
+// ProcessBulkFilesHook returns a hook that processes the zip files of a delivery with the processor.
+// If the files were downloaded to a storage, the objects are read as streams,
+// which requires a BulkStreamProcessor, otherwise ErrLocalFilesRequired is returned.
+func ProcessBulkFilesHook(processor BulkFileProcessor) DeliveryHook {
+	return func(ctx context.Context, event DeliveryEvent) (err error) {
+		for _, filePath := range event.FilePaths() {
+			if !strings.HasSuffix(strings.ToLower(filePath), ".zip") {
+				continue
+			}
+			if event.Storage != nil {
+				err = processBulkObject(ctx, processor, event.Storage, filePath)
+			} else {
+				err = processor.ProcessBulkZipFileContext(ctx, filePath)
+			}
+			if err != nil {
+				return fmt.Errorf("failed to process %s: %w", filePath, err)
+			}
+		}
+		return
+	}
+}
+
+// processBulkObject processes a zip file of the storage as a stream
+func processBulkObject(ctx context.Context, processor BulkFileProcessor, store storage.Storage, name string) (err error) {
+	streamProcessor, ok := processor.(BulkStreamProcessor)
+	if !ok {
+		return ErrLocalFilesRequired
+	}
+	rc, err := store.Open(ctx, name)
+	if err != nil {
+		return
+	}
+	defer func(rc io.ReadCloser) {
+		_ = rc.Close()
+	}(rc)
+	return streamProcessor.ProcessBulkZipStreamContext(ctx, name, rc)
+}
+
+// WatchResult is the result of a poll of a watcher
+type WatchResult struct {
+	Deliveries []DeliveryEvent // new deliveries that were downloaded and processed by all hooks
+}
+
+// Watcher polls the catalog of a product, downloads new deliveries and calls the hooks.
+// The deliveries that were downloaded and processed by all hooks are saved in a cursor file,
+// after a restart the remaining deliveries are continued, complete files are not downloaded again.
+type Watcher struct {
+	sync       *ProductSync
+	cursorPath string
+	interval   time.Duration
+	hooks      []DeliveryHook
+	now        func() time.Time // replaceable for tests
+}
+
+// NewWatcher creates a new watcher of a product that downloads to the destination path
+// and saves its cursor to the cursor path
+func NewWatcher(productID EpoBddsBProductID, destinationPath, cursorPath string) *Watcher {
+	return defaultClient().NewWatcher(productID, destinationPath, cursorPath)
+}
+
+// NewWatcher creates a new watcher of a product that downloads to the destination path
+// and saves its cursor to the cursor path.
+// The files are saved in the MirrorLayout by default.
+func (c *Client) NewWatcher(productID EpoBddsBProductID, destinationPath, cursorPath string) *Watcher {
+	return &Watcher{
+		sync:       c.NewProductSync(productID, destinationPath).SetLayout(MirrorLayout),
+		cursorPath: cursorPath,
+		interval:   DefaultWatchInterval,
+		now:        time.Now,
+	}
+}
+
+// Select adds selectors, a file is only downloaded if all selectors select it.
+// Deliveries without selected files are marked as done without calling the hooks.
+func (w *Watcher) Select(selectors ...Selector) *Watcher {
+	w.sync.Select(selectors...)
+	return w
+}
+
+// SetInterval sets the interval between two polls, the default is DefaultWatchInterval
+func (w *Watcher) SetInterval(interval time.Duration) *Watcher {
+	if interval > 0 {
+		w.interval = interval
+	}
+	return w
+}
+
+// SetLayout sets the layout of the files in the destination path, the default is MirrorLayout
+func (w *Watcher) SetLayout(layout Layout) *Watcher {
+	w.sync.SetLayout(layout)
+	return w
+}
+
+// SetLedger sets a ledger that records the catalog and the results of the downloads
+func (w *Watcher) SetLedger(ledger *Ledger) *Watcher {
+	w.sync.SetLedger(ledger)
+	return w
+}
+
+// SetDiskSpaceCheck sets what happens if the planned downloads do not fit on the destination filesystem,
+// the default is DiskSpaceRefuse
+func (w *Watcher) SetDiskSpaceCheck(check DiskSpaceCheck) *Watcher {
+	w.sync.SetDiskSpaceCheck(check)
+	return w
+}
+
+// AddHook adds a hook that is called after the files of a new delivery were downloaded,
+// the hooks are called in the order they were added
+func (w *Watcher) AddHook(hooks ...DeliveryHook) *Watcher {
+	w.hooks = append(w.hooks, hooks...)
+	return w
+}
+
+// DownloadManager returns the download manager of the watcher,
+// which can be used to set the parallelism and the rate limits
+func (w *Watcher) DownloadManager() *DownloadManager {
+	return w.sync.DownloadManager()
+}
+
+// Run polls the catalog immediately and then after every interval until the context is cancelled.
+// A failed poll is logged and repeated with the next poll.
+func (w *Watcher) Run(ctx context.Context) error {
+	logger := w.sync.client.logger.With("productID", w.sync.ProductID)
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+	for {
+		result, err := w.Poll(ctx)
+		if err != nil && ctx.Err() == nil {
+			logger.With("err", err).Error("poll failed, retry with the next poll")
+		} else if err == nil {
+			logger.With("deliveries", len(result.Deliveries)).Info("poll done")
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// Poll fetches the catalog once, downloads the new deliveries in the order of their publication
+// and calls the hooks for each of them. The poll stops at the first delivery that fails,
+// so the deliveries are processed in order.
+func (w *Watcher) Poll(ctx context.Context) (result WatchResult, err error) {
+	logger := w.sync.client.logger.With("productID", w.sync.ProductID, "cursor", w.cursorPath)
+
+	cursor, err := LoadWatcherCursor(w.cursorPath)
+	if err != nil {
+		logger.With("err", err).Error("failed to load cursor")
+		return
+	}
+	if cursor.ProductID == "" {
+		cursor.ProductID = w.sync.ProductID
+	}
+	if cursor.ProductID != w.sync.ProductID {
+		err = fmt.Errorf("cursor %s belongs to product %s", w.cursorPath, cursor.ProductID)
+		logger.With("err", err).Error("wrong cursor")
+		return
+	}
+
+	catalog, err := w.sync.client.GetEpoBddsFileItemsContext(ctx, "", w.sync.ProductID)
+	if err != nil {
+		logger.With("err", err).Error("could not get files")
+		return
+	}
+
+	// new deliveries in the order of their publication
+	now := w.now()
+	var deliveries []EpoProductDelivery
+	expired := 0
+	for _, d := range catalog.Deliveries {
+		if cursor.IsDone(d.DeliveryID) {
+			continue
+		}
+		if deliveryExpired(d, now) {
+			// an expired delivery can not be downloaded anymore, it is only reported once
+			logger.With("delivery", d.DeliveryName).Warn("skipping expired delivery")
+			cursor.Done = append(cursor.Done, d.DeliveryID)
+			expired++
+			continue
+		}
+		deliveries = append(deliveries, d)
+	}
+	if expired > 0 {
+		err = saveWatcherCursor(w.cursorPath, cursor)
+		if err != nil {
+			logger.With("err", err).Error("failed to save cursor")
+			return
+		}
+	}
+	sort.SliceStable(deliveries, func(i, j int) bool {
+		if !deliveries[i].DeliveryPublicationDatetime.Equal(deliveries[j].DeliveryPublicationDatetime) {
+			return deliveries[i].DeliveryPublicationDatetime.Before(deliveries[j].DeliveryPublicationDatetime)
+		}
+		return deliveries[i].DeliveryID < deliveries[j].DeliveryID
+	})
+
+	for _, d := range deliveries {
+		var event DeliveryEvent
+		var selected bool
+		event, selected, err = w.processDelivery(ctx, catalog, d)
+		if err != nil {
+			logger.With("err", err, "delivery", d.DeliveryName).Error("failed to process delivery")
+			return
+		}
+		cursor.Done = append(cursor.Done, d.DeliveryID)
+		err = saveWatcherCursor(w.cursorPath, cursor)
+		if err != nil {
+			logger.With("err", err).Error("failed to save cursor")
+			return
+		}
+		if selected {
+			result.Deliveries = append(result.Deliveries, event)
+		}
+	}
+
+	cursor.LastPollAt = now
+	err = saveWatcherCursor(w.cursorPath, cursor)
+	if err != nil {
+		logger.With("err", err).Error("failed to save cursor")
+		return
+	}
+	return
+}
+
+// processDelivery downloads the selected files of the delivery and calls the hooks.
+// selected reports if the delivery has selected files.
+func (w *Watcher) processDelivery(ctx context.Context, catalog EpoProductDeliveriesResponse, delivery EpoProductDelivery) (event DeliveryEvent, selected bool, err error) {
+	logger := w.sync.client.logger.With("productID", w.sync.ProductID, "delivery", delivery.DeliveryName)
+
+	single := catalog
+	single.Deliveries = []EpoProductDelivery{delivery}
+	if len(w.sync.plan(single)) == 0 {
+		logger.Debug("no selected files in delivery")
+		return
+	}
+	selected = true
+
+	logger.Info("new delivery")
+	syncResult, err := w.sync.run(ctx, single)
+	if err != nil {
+		return
+	}
+	event = DeliveryEvent{
+		ProductID: w.sync.ProductID,
+		Delivery:  delivery,
+		Results:   syncResult.Results,
+		Storage:   w.sync.DownloadManager().storage,
+	}
+	for i, hook := range w.hooks {
+		err = hook(ctx, event)
+		if err != nil {
+			logger.With("err", err, "hook", i).Error("hook failed")
+			return
+		}
+	}
+	logger.Info("delivery done")
+	return
+}
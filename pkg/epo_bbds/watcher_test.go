@@ -0,0 +1,282 @@
+package epo_bbds
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/max-planck-innovation-competition/go-epo-bdds/pkg/epo_bbdstest"
+	"github.com/max-planck-innovation-competition/go-epo-bdds/pkg/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+// recordingProcessor records the processed bulk files
+type recordingProcessor struct {
+	mu    sync.Mutex
+	files []string
+	err   error
+}
+
+func (p *recordingProcessor) ProcessBulkZipFileContext(ctx context.Context, filePath string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.err != nil {
+		return p.err
+	}
+	p.files = append(p.files, filepath.Base(filePath))
+	return nil
+}
+
+func TestWatcherPoll(t *testing.T) {
+	ass := assert.New(t)
+	catalog := checksumCatalog()
+	srv, c := newCatalogTestServer(t, catalog)
+	dir := t.TempDir()
+	cursorPath := filepath.Join(dir, "state", "cursor.json")
+	ctx := context.Background()
+
+	var deliveries []string
+	processor := &recordingProcessor{}
+	newWatcher := func() *Watcher {
+		return c.NewWatcher(EpoPatstatGlobalProductID, dir, cursorPath).
+			Select(FileNameGlob("data_*")).
+			AddHook(func(ctx context.Context, event DeliveryEvent) error {
+				deliveries = append(deliveries, event.Delivery.DeliveryName)
+				for _, p := range event.FilePaths() {
+					ass.FileExists(p)
+				}
+				return nil
+			}, ProcessBulkFilesHook(processor))
+	}
+
+	// the deliveries are processed in the order of their publication
+	result, err := newWatcher().Poll(ctx)
+	ass.NoError(err)
+	ass.Len(result.Deliveries, 2)
+	ass.Equal([]string{"PATSTAT Global 2023 Autumn", "PATSTAT Global 2024 Spring"}, deliveries)
+	ass.Equal([]string{
+		"data_PATSTAT_Global_2023_Autumn_01.zip",
+		"data_PATSTAT_Global_2023_Autumn_02.zip",
+		"data_PATSTAT_Global_2024_Spring_01.zip",
+	}, processor.files)
+	ass.FileExists(filepath.Join(dir, "17", "PATSTAT Global 2024 Spring", "data_PATSTAT_Global_2024_Spring_01.zip"))
+	cursor, err := LoadWatcherCursor(cursorPath)
+	ass.NoError(err)
+	ass.Equal(EpoPatstatGlobalProductID, cursor.ProductID)
+	ass.Equal([]int{1, 2}, cursor.Done)
+	ass.False(cursor.LastPollAt.IsZero())
+
+	// after a restart the deliveries are not processed again
+	result, err = newWatcher().Poll(ctx)
+	ass.NoError(err)
+	ass.Empty(result.Deliveries)
+	ass.Len(deliveries, 2)
+	ass.Equal(3, srv.Requests(epo_bbdstest.EndpointDownload))
+
+	// a new delivery is detected
+	catalog.Deliveries = append(catalog.Deliveries, EpoProductDelivery{
+		DeliveryID:                  3,
+		DeliveryName:                "PATSTAT Global 2024 Autumn",
+		DeliveryPublicationDatetime: time.Date(2024, 10, 1, 0, 0, 0, 0, time.UTC),
+		Files: []EpoDocDbFileItem{
+			{FileID: 31, FileName: "data_PATSTAT_Global_2024_Autumn_01.zip", FileSize: "1 KB"},
+		},
+	})
+	srv.SetProducts(catalogTestProduct(catalog))
+	result, err = newWatcher().Poll(ctx)
+	ass.NoError(err)
+	if ass.Len(result.Deliveries, 1) {
+		ass.Equal(3, result.Deliveries[0].Delivery.DeliveryID)
+	}
+	ass.Equal("PATSTAT Global 2024 Autumn", deliveries[2])
+	ass.Equal(4, srv.Requests(epo_bbdstest.EndpointDownload))
+}
+
+func TestWatcherExpiredDelivery(t *testing.T) {
+	ass := assert.New(t)
+	catalog := checksumCatalog()
+	expiry := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	catalog.Deliveries[0].DeliveryExpiryDatetime = &expiry
+	var logs bytes.Buffer
+	_, c := newTestClient(t, []epo_bbdstest.Product{catalogTestProduct(catalog)},
+		WithLogger(slog.New(slog.NewTextHandler(&logs, nil))))
+	dir := t.TempDir()
+	cursorPath := filepath.Join(dir, "cursor.json")
+
+	var deliveries []int
+	w := c.NewWatcher(EpoPatstatGlobalProductID, dir, cursorPath).
+		AddHook(func(ctx context.Context, event DeliveryEvent) error {
+			deliveries = append(deliveries, event.Delivery.DeliveryID)
+			return nil
+		})
+	w.now = func() time.Time { return time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC) }
+	for i := 0; i < 3; i++ {
+		_, err := w.Poll(context.Background())
+		ass.NoError(err)
+	}
+	// the expired delivery is marked as done and only reported once
+	ass.Equal([]int{2}, deliveries)
+	cursor, err := LoadWatcherCursor(cursorPath)
+	ass.NoError(err)
+	ass.Equal([]int{1, 2}, cursor.Done)
+	ass.Equal(1, strings.Count(logs.String(), "skipping expired delivery"))
+}
+
+// streamProcessor records the bulk files that are processed as streams
+type streamProcessor struct {
+	recordingProcessor
+	streams []string
+}
+
+func (p *streamProcessor) ProcessBulkZipStreamContext(ctx context.Context, name string, r io.Reader) error {
+	content, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	p.streams = append(p.streams, name+"="+string(content))
+	return nil
+}
+
+func TestWatcherStorage(t *testing.T) {
+	ass := assert.New(t)
+	_, c := newCatalogTestServer(t, checksumCatalog())
+	store := storage.NewLocal(t.TempDir())
+	dir := t.TempDir()
+	ctx := context.Background()
+
+	var paths []string
+	processor := &streamProcessor{}
+	w := c.NewWatcher(EpoPatstatGlobalProductID, "patstat", filepath.Join(dir, "cursor.json")).
+		Select(FileNameGlob("data_*2024*")).
+		AddHook(func(ctx context.Context, event DeliveryEvent) error {
+			ass.Equal(store, event.Storage)
+			paths = append(paths, event.FilePaths()...)
+			return nil
+		}, ProcessBulkFilesHook(processor))
+	w.DownloadManager().SetStorage(store)
+	_, err := w.Poll(ctx)
+	ass.NoError(err)
+	// the paths are the names of the objects
+	name := "patstat/17/PATSTAT Global 2024 Spring/data_PATSTAT_Global_2024_Spring_01.zip"
+	ass.Equal([]string{name}, paths)
+	_, err = store.Stat(ctx, name)
+	ass.NoError(err)
+	ass.Equal([]string{name + "=data_PATSTAT_Global_2024_Spring_01.zip"}, processor.streams)
+	ass.Empty(processor.files)
+
+	// hooks that need local files fail
+	event := DeliveryEvent{Results: []DownloadResult{{Job: DownloadJob{DestinationPath: "patstat", File: EpoDocDbFileItem{FileName: "a.zip"}}}}, Storage: store}
+	ass.ErrorIs(ProcessBulkFilesHook(&recordingProcessor{})(ctx, event), ErrLocalFilesRequired)
+	ass.ErrorIs(ValidateBulkFilesHook()(ctx, event), ErrLocalFilesRequired)
+}
+
+func TestWatcherHookFailure(t *testing.T) {
+	ass := assert.New(t)
+	_, c := newCatalogTestServer(t, checksumCatalog())
+	dir := t.TempDir()
+	cursorPath := filepath.Join(dir, "cursor.json")
+	ctx := context.Background()
+
+	errHook := errors.New("database unavailable")
+	calls := 0
+	w := c.NewWatcher(EpoPatstatGlobalProductID, dir, cursorPath).
+		AddHook(func(ctx context.Context, event DeliveryEvent) error {
+			calls++
+			if event.Delivery.DeliveryID == 2 && calls == 2 {
+				return errHook
+			}
+			return nil
+		})
+
+	// the poll stops at the failed delivery
+	_, err := w.Poll(ctx)
+	ass.ErrorIs(err, errHook)
+	cursor, err := LoadWatcherCursor(cursorPath)
+	ass.NoError(err)
+	ass.Equal([]int{1}, cursor.Done)
+
+	// the failed delivery is processed again, the files are already complete
+	result, err := w.Poll(ctx)
+	ass.NoError(err)
+	if ass.Len(result.Deliveries, 1) {
+		ass.Equal(2, result.Deliveries[0].Delivery.DeliveryID)
+		for _, res := range result.Deliveries[0].Results {
+			ass.True(res.Skipped)
+		}
+	}
+	ass.Equal(3, calls)
+}
+
+func TestWatcherProcessBulkFilesHookFailure(t *testing.T) {
+	ass := assert.New(t)
+	_, c := newCatalogTestServer(t, checksumCatalog())
+	dir := t.TempDir()
+	errProcess := errors.New("broken zip")
+
+	w := c.NewWatcher(EpoPatstatGlobalProductID, dir, filepath.Join(dir, "cursor.json")).
+		AddHook(ProcessBulkFilesHook(&recordingProcessor{err: errProcess}))
+	_, err := w.Poll(context.Background())
+	ass.ErrorIs(err, errProcess)
+}
+
+func TestWatcherCursor(t *testing.T) {
+	ass := assert.New(t)
+	_, c := newCatalogTestServer(t, checksumCatalog())
+	dir := t.TempDir()
+	cursorPath := filepath.Join(dir, "cursor.json")
+
+	// a missing cursor is empty
+	cursor, err := LoadWatcherCursor(cursorPath)
+	ass.NoError(err)
+	ass.Empty(cursor.Done)
+
+	// the cursor of another product is not used
+	ass.NoError(saveWatcherCursor(cursorPath, WatcherCursor{ProductID: EpoDocDBFrontFilesProductID, Done: []int{1}}))
+	_, err = c.NewWatcher(EpoPatstatGlobalProductID, dir, cursorPath).Poll(context.Background())
+	ass.Error(err)
+
+	// no temporary files remain
+	entries, err := os.ReadDir(dir)
+	ass.NoError(err)
+	ass.Len(entries, 1)
+
+	ass.NoError(os.WriteFile(cursorPath, []byte("{"), 0o644))
+	_, err = LoadWatcherCursor(cursorPath)
+	ass.Error(err)
+}
+
+func TestWatcherRun(t *testing.T) {
+	ass := assert.New(t)
+	srv, c := newCatalogTestServer(t, checksumCatalog())
+	dir := t.TempDir()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// the first poll fails and is repeated
+	srv.AddFaults(epo_bbdstest.Fault{Endpoint: epo_bbdstest.EndpointDeliveries, StatusCode: 404})
+	var mu sync.Mutex
+	var deliveries []int
+	w := c.NewWatcher(EpoPatstatGlobalProductID, dir, filepath.Join(dir, "cursor.json")).
+		SetInterval(10 * time.Millisecond).
+		AddHook(func(ctx context.Context, event DeliveryEvent) error {
+			mu.Lock()
+			defer mu.Unlock()
+			deliveries = append(deliveries, event.Delivery.DeliveryID)
+			if len(deliveries) == 2 {
+				cancel()
+			}
+			return nil
+		})
+	err := w.Run(ctx)
+	ass.ErrorIs(err, context.Canceled)
+	ass.Equal([]int{1, 2}, deliveries)
+	ass.GreaterOrEqual(srv.Requests(epo_bbdstest.EndpointDeliveries), 2)
+}
@@ -0,0 +1,110 @@
+package epo_bbdstest
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"time"
+)
+
+// Product is a product of the fake catalog
+type Product struct {
+	ID          int
+	Name        string
+	Description string
+	Deliveries  []Delivery
+}
+
+// Delivery is a delivery of a product
+type Delivery struct {
+	ID                  int
+	Name                string
+	PublicationDatetime time.Time
+	ExpiryDatetime      *time.Time // nil if the delivery does not expire
+	Files               []File
+}
+
+// File is a file of a delivery, the content is served by the download endpoint
+type File struct {
+	ID                  int
+	Name                string
+	Size                string // size in the catalog e.g. "1.7 GB"
+	Checksum            string // checksum in the catalog
+	PublicationDatetime time.Time
+	Content             []byte
+}
+
+// NewFile creates a file whose catalog size and checksum match the content
+func NewFile(id int, name string, content []byte) File {
+	sum := md5.Sum(content)
+	return File{
+		ID:       id,
+		Name:     name,
+		Size:     fmt.Sprintf("%d B", len(content)),
+		Checksum: hex.EncodeToString(sum[:]),
+		Content:  content,
+	}
+}
+
+// json models of the api responses
+
+type productJSON struct {
+	ID          int    `json:"id"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+type deliveriesJSON struct {
+	ID          int            `json:"id"`
+	Name        string         `json:"name"`
+	Description string         `json:"description"`
+	Deliveries  []deliveryJSON `json:"deliveries"`
+}
+
+type deliveryJSON struct {
+	DeliveryID                  int        `json:"deliveryId"`
+	DeliveryName                string     `json:"deliveryName"`
+	DeliveryPublicationDatetime time.Time  `json:"deliveryPublicationDatetime"`
+	DeliveryExpiryDatetime      *time.Time `json:"deliveryExpiryDatetime"`
+	Files                       []fileJSON `json:"files"`
+}
+
+type fileJSON struct {
+	FileID                  int       `json:"fileId"`
+	FileName                string    `json:"fileName"`
+	FileSize                string    `json:"fileSize"`
+	FileChecksum            string    `json:"fileChecksum"`
+	ItemPublicationDatetime time.Time `json:"itemPublicationDatetime"`
+}
+
+type tokenJSON struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int    `json:"expires_in"`
+	Scope       string `json:"scope"`
+}
+
+// toJSON returns the catalog of the product as returned by the api
+func (p Product) toJSON() (res deliveriesJSON) {
+	res = deliveriesJSON{ID: p.ID, Name: p.Name, Description: p.Description, Deliveries: []deliveryJSON{}}
+	for _, d := range p.Deliveries {
+		delivery := deliveryJSON{
+			DeliveryID:                  d.ID,
+			DeliveryName:                d.Name,
+			DeliveryPublicationDatetime: d.PublicationDatetime,
+			DeliveryExpiryDatetime:      d.ExpiryDatetime,
+			Files:                       []fileJSON{},
+		}
+		for _, f := range d.Files {
+			delivery.Files = append(delivery.Files, fileJSON{
+				FileID:                  f.ID,
+				FileName:                f.Name,
+				FileSize:                f.Size,
+				FileChecksum:            f.Checksum,
+				ItemPublicationDatetime: f.PublicationDatetime,
+			})
+		}
+		res.Deliveries = append(res.Deliveries, delivery)
+	}
+	return
+}
@@ -0,0 +1,443 @@
+// Package epo_bbdstest provides an in-process fake of the EPO bulk data service for tests.
+//
+// The server implements the login, products, product deliveries and file download endpoints.
+// It serves fixture catalogs and files and can simulate error responses,
+// slow streams and dropped connections.
+// The package does not import epo_bbds, so it can be used by the tests of epo_bbds.
+package epo_bbdstest
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultUsername is the username that the server accepts by default
+const DefaultUsername = "user@example.com"
+
+// DefaultPassword is the password that the server accepts by default
+const DefaultPassword = "secret"
+
+// DefaultTokenLifetime is the lifetime of the issued tokens
+const DefaultTokenLifetime = time.Hour
+
+// Endpoint identifies an endpoint of the server
+type Endpoint string
+
+const (
+	// EndpointLogin is the login endpoint that issues the tokens
+	EndpointLogin Endpoint = "login"
+	// EndpointProducts is the endpoint that lists the products
+	EndpointProducts Endpoint = "products"
+	// EndpointDeliveries is the endpoint that lists the deliveries and files of a product
+	EndpointDeliveries Endpoint = "deliveries"
+	// EndpointDownload is the endpoint that downloads a file
+	EndpointDownload Endpoint = "download"
+)
+
+// Fault is an error that the server simulates for the next requests of an endpoint
+type Fault struct {
+	Endpoint   Endpoint      // endpoint of the fault, empty for all endpoints
+	StatusCode int           // respond with the status code e.g. 401, 429 or 500, 0 to respond normally
+	RetryAfter string        // optional Retry-After header of the error response
+	Delay      time.Duration // delay before the response
+	Drop       bool          // drop the connection of a download after DropAfter bytes of the body
+	DropAfter  int64         // bytes of the body that are sent before the connection is dropped
+	Times      int           // number of requests the fault applies to, 1 if 0
+}
+
+// Server is a fake of the bulk data service
+type Server struct {
+	*httptest.Server
+	Username      string        // accepted username
+	Password      string        // accepted password
+	TokenLifetime time.Duration // lifetime of the issued tokens
+	ClientID      string        // accepted OAuth client id of the Basic header, any client id if empty
+
+	mu             sync.Mutex
+	products       []Product
+	tokens         map[string]time.Time // expiry of the issued tokens
+	tokenCount     int
+	faults         []Fault
+	requests       map[Endpoint]int
+	ranges         []string // Range headers of the download requests
+	bytesPerSecond int64
+	noRanges       bool
+}
+
+// NewServer starts a server that serves the products.
+// The server must be closed with Close.
+func NewServer(products ...Product) *Server {
+	s := &Server{
+		Username:      DefaultUsername,
+		Password:      DefaultPassword,
+		TokenLifetime: DefaultTokenLifetime,
+		products:      products,
+		tokens:        map[string]time.Time{},
+		requests:      map[Endpoint]int{},
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.handle))
+	return s
+}
+
+// BaseURL returns the base url of the api, see epo_bbds.WithBaseURL
+func (s *Server) BaseURL() string {
+	return s.URL + "/api"
+}
+
+// LoginURL returns the url of the login endpoint, see epo_bbds.WithLoginURL
+func (s *Server) LoginURL() string {
+	return s.URL + "/login"
+}
+
+// SetProducts replaces the products of the catalog
+func (s *Server) SetProducts(products ...Product) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.products = products
+}
+
+// AddFaults adds faults that are applied to the next requests in the order they were added
+func (s *Server) AddFaults(faults ...Fault) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, f := range faults {
+		if f.Times < 1 {
+			f.Times = 1
+		}
+		s.faults = append(s.faults, f)
+	}
+}
+
+// SetBytesPerSecond limits the speed of the downloads to simulate slow streams, 0 disables the limit
+func (s *Server) SetBytesPerSecond(bytesPerSecond int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.bytesPerSecond = bytesPerSecond
+}
+
+// SetRangeSupport sets if the download endpoint supports range requests, the default is true
+func (s *Server) SetRangeSupport(supported bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.noRanges = !supported
+}
+
+// Token issues a valid token and returns the value of the Authorization header
+func (s *Server) Token() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return "Bearer " + s.issueToken()
+}
+
+// ExpireTokens expires all issued tokens, the next requests with these tokens are unauthorized
+func (s *Server) ExpireTokens() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tokens = map[string]time.Time{}
+}
+
+// Requests returns the number of requests of an endpoint
+func (s *Server) Requests(endpoint Endpoint) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests[endpoint]
+}
+
+// Ranges returns the Range headers of the download requests in their order,
+// a request without a range has an empty header
+func (s *Server) Ranges() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string{}, s.ranges...)
+}
+
+// issueToken creates a new token, the lock must be held
+func (s *Server) issueToken() string {
+	s.tokenCount++
+	token := fmt.Sprintf("fake-token-%d", s.tokenCount)
+	s.tokens[token] = time.Now().Add(s.TokenLifetime)
+	return token
+}
+
+// handle routes the requests and applies the faults
+func (s *Server) handle(w http.ResponseWriter, r *http.Request) {
+	endpoint, parts, ok := route(r.URL.Path)
+	if !ok {
+		writeError(w, http.StatusNotFound, "not found")
+		return
+	}
+
+	s.mu.Lock()
+	s.requests[endpoint]++
+	if endpoint == EndpointDownload {
+		s.ranges = append(s.ranges, r.Header.Get("Range"))
+	}
+	fault, hasFault := s.takeFault(endpoint)
+	s.mu.Unlock()
+
+	if hasFault && fault.Delay > 0 {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-time.After(fault.Delay):
+		}
+	}
+	if hasFault && fault.StatusCode != 0 {
+		if fault.RetryAfter != "" {
+			w.Header().Set("Retry-After", fault.RetryAfter)
+		}
+		writeError(w, fault.StatusCode, http.StatusText(fault.StatusCode))
+		return
+	}
+
+	if endpoint == EndpointLogin {
+		s.handleLogin(w, r)
+		return
+	}
+	if !s.authorized(r) {
+		writeError(w, http.StatusUnauthorized, "invalid token")
+		return
+	}
+	switch endpoint {
+	case EndpointProducts:
+		s.handleProducts(w)
+	case EndpointDeliveries:
+		s.handleDeliveries(w, parts)
+	case EndpointDownload:
+		s.handleDownload(w, r, parts, fault)
+	}
+}
+
+// route returns the endpoint and the ids of the path
+func route(path string) (endpoint Endpoint, ids []int, ok bool) {
+	if path == "/login" {
+		return EndpointLogin, nil, true
+	}
+	if path != "/api/products" && !strings.HasPrefix(path, "/api/products/") {
+		return "", nil, false
+	}
+	path = strings.Trim(strings.TrimPrefix(path, "/api/products"), "/")
+	if path == "" {
+		return EndpointProducts, nil, true
+	}
+	var productID, deliveryID, fileID int
+	var rest string
+	if n, _ := fmt.Sscanf(path, "%d/delivery/%d/file/%d/%s", &productID, &deliveryID, &fileID, &rest); n == 4 && rest == "download" {
+		return EndpointDownload, []int{productID, deliveryID, fileID}, true
+	}
+	if n, err := fmt.Sscanf(path, "%d", &productID); n == 1 && err == nil && fmt.Sprint(productID) == path {
+		return EndpointDeliveries, []int{productID}, true
+	}
+	return "", nil, false
+}
+
+// takeFault returns the next fault of the endpoint, the lock must be held
+func (s *Server) takeFault(endpoint Endpoint) (fault Fault, ok bool) {
+	for i, f := range s.faults {
+		if f.Endpoint != "" && f.Endpoint != endpoint {
+			continue
+		}
+		s.faults[i].Times--
+		if s.faults[i].Times <= 0 {
+			s.faults = append(s.faults[:i], s.faults[i+1:]...)
+		}
+		return f, true
+	}
+	return
+}
+
+// authorized reports if the request has a valid token
+func (s *Server) authorized(r *http.Request) bool {
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	expiry, ok := s.tokens[token]
+	return ok && time.Now().Before(expiry)
+}
+
+// handleLogin issues a token for the password grant
+func (s *Server) handleLogin(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	auth := r.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, "Basic ") {
+		writeError(w, http.StatusUnauthorized, "invalid_client")
+		return
+	}
+	if s.ClientID != "" {
+		clientID, errDecode := base64.StdEncoding.DecodeString(strings.TrimPrefix(auth, "Basic "))
+		if errDecode != nil || string(clientID) != s.ClientID {
+			writeError(w, http.StatusUnauthorized, "invalid_client")
+			return
+		}
+	}
+	err := r.ParseForm()
+	if err != nil || r.PostForm.Get("grant_type") != "password" {
+		writeError(w, http.StatusBadRequest, "invalid_request")
+		return
+	}
+	if r.PostForm.Get("username") != s.Username || r.PostForm.Get("password") != s.Password {
+		writeError(w, http.StatusUnauthorized, "invalid_grant")
+		return
+	}
+	s.mu.Lock()
+	token := s.issueToken()
+	lifetime := s.TokenLifetime
+	s.mu.Unlock()
+	writeJSON(w, tokenJSON{
+		AccessToken: token,
+		TokenType:   "Bearer",
+		ExpiresIn:   int(lifetime.Seconds()),
+		Scope:       "openid",
+	})
+}
+
+// handleProducts lists the products
+func (s *Server) handleProducts(w http.ResponseWriter) {
+	s.mu.Lock()
+	products := []productJSON{}
+	for _, p := range s.products {
+		products = append(products, productJSON{ID: p.ID, Name: p.Name, Description: p.Description})
+	}
+	s.mu.Unlock()
+	writeJSON(w, products)
+}
+
+// handleDeliveries lists the deliveries and files of a product
+func (s *Server) handleDeliveries(w http.ResponseWriter, ids []int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, p := range s.products {
+		if p.ID == ids[0] {
+			writeJSON(w, p.toJSON())
+			return
+		}
+	}
+	writeError(w, http.StatusNotFound, "product not found")
+}
+
+// handleDownload serves the content of a file
+func (s *Server) handleDownload(w http.ResponseWriter, r *http.Request, ids []int, fault Fault) {
+	s.mu.Lock()
+	file, ok := s.findFile(ids[0], ids[1], ids[2])
+	bytesPerSecond := s.bytesPerSecond
+	noRanges := s.noRanges
+	s.mu.Unlock()
+	if !ok {
+		writeError(w, http.StatusNotFound, "file not found")
+		return
+	}
+
+	sw := &streamWriter{
+		ResponseWriter: w,
+		request:        r,
+		bytesPerSecond: bytesPerSecond,
+		drop:           fault.Drop,
+		dropAfter:      fault.DropAfter,
+	}
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", file.Name))
+	if noRanges {
+		w.Header().Set("Content-Length", fmt.Sprint(len(file.Content)))
+		w.WriteHeader(http.StatusOK)
+		_, _ = sw.Write(file.Content)
+		return
+	}
+	// ServeContent handles the range requests
+	http.ServeContent(sw, r, file.Name, time.Time{}, bytes.NewReader(file.Content))
+}
+
+// findFile returns a file of the catalog, the lock must be held
+func (s *Server) findFile(productID, deliveryID, fileID int) (file File, ok bool) {
+	for _, p := range s.products {
+		if p.ID != productID {
+			continue
+		}
+		for _, d := range p.Deliveries {
+			if d.ID != deliveryID {
+				continue
+			}
+			for _, f := range d.Files {
+				if f.ID == fileID {
+					return f, true
+				}
+			}
+		}
+	}
+	return
+}
+
+// streamWriter slows down the body and drops the connection
+type streamWriter struct {
+	http.ResponseWriter
+	request        *http.Request
+	bytesPerSecond int64
+	drop           bool
+	dropAfter      int64
+	written        int64
+}
+
+// Write writes the body in chunks at the configured speed
+func (w *streamWriter) Write(p []byte) (n int, err error) {
+	for len(p) > 0 {
+		chunk := p
+		if w.bytesPerSecond > 0 {
+			// ten chunks per second
+			size := w.bytesPerSecond/10 + 1
+			if int64(len(chunk)) > size {
+				chunk = chunk[:size]
+			}
+		}
+		if w.drop && w.written+int64(len(chunk)) > w.dropAfter {
+			chunk = chunk[:w.dropAfter-w.written]
+			_, _ = w.ResponseWriter.Write(chunk)
+			if f, ok := w.ResponseWriter.(http.Flusher); ok {
+				f.Flush()
+			}
+			// abort the response without a complete body
+			panic(http.ErrAbortHandler)
+		}
+		if w.bytesPerSecond > 0 {
+			// wait the time the chunk takes at the configured speed
+			select {
+			case <-w.request.Context().Done():
+				return n, w.request.Context().Err()
+			case <-time.After(time.Duration(float64(len(chunk)) / float64(w.bytesPerSecond) * float64(time.Second))):
+			}
+		}
+		var m int
+		m, err = w.ResponseWriter.Write(chunk)
+		n += m
+		w.written += int64(m)
+		if err != nil {
+			return
+		}
+		if f, ok := w.ResponseWriter.(http.Flusher); ok && w.bytesPerSecond > 0 {
+			f.Flush()
+		}
+		p = p[len(chunk):]
+	}
+	return
+}
+
+// writeJSON writes the value as json
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// writeError writes an error response
+func writeError(w http.ResponseWriter, statusCode int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	_ = json.NewEncoder(w).Encode(map[string]string{"error": message})
+}
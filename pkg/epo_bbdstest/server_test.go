@@ -0,0 +1,177 @@
+package epo_bbdstest
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var testProduct = Product{
+	ID:   3,
+	Name: "DOCDB front files",
+	Deliveries: []Delivery{
+		{
+			ID:                  10,
+			Name:                "DOCDB 2024 week 1",
+			PublicationDatetime: time.Date(2024, 1, 5, 0, 0, 0, 0, time.UTC),
+			Files:               []File{NewFile(20, "a.zip", []byte("0123456789"))},
+		},
+	},
+}
+
+func get(t *testing.T, s *Server, path string, header http.Header) (resp *http.Response, body string) {
+	req, err := http.NewRequest(http.MethodGet, s.URL+path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", s.Token())
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	b, _ := io.ReadAll(resp.Body)
+	return resp, string(b)
+}
+
+func TestLogin(t *testing.T) {
+	ass := assert.New(t)
+	s := NewServer(testProduct)
+	defer s.Close()
+
+	login := func(username, password string) *http.Response {
+		form := url.Values{"grant_type": {"password"}, "username": {username}, "password": {password}}
+		req, _ := http.NewRequest(http.MethodPost, s.LoginURL(), strings.NewReader(form.Encode()))
+		req.Header.Set("Authorization", "Basic eA==")
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		resp, err := http.DefaultClient.Do(req)
+		ass.NoError(err)
+		return resp
+	}
+
+	resp := login(DefaultUsername, "wrong")
+	ass.Equal(http.StatusUnauthorized, resp.StatusCode)
+	_ = resp.Body.Close()
+
+	resp = login(DefaultUsername, DefaultPassword)
+	ass.Equal(http.StatusOK, resp.StatusCode)
+	var token tokenJSON
+	ass.NoError(json.NewDecoder(resp.Body).Decode(&token))
+	_ = resp.Body.Close()
+	ass.Equal("Bearer", token.TokenType)
+	ass.Equal(3600, token.ExpiresIn)
+	ass.NotEmpty(token.AccessToken)
+	ass.Equal(2, s.Requests(EndpointLogin))
+}
+
+func TestCatalog(t *testing.T) {
+	ass := assert.New(t)
+	s := NewServer(testProduct)
+	defer s.Close()
+
+	resp, body := get(t, s, "/api/products/", nil)
+	ass.Equal(http.StatusOK, resp.StatusCode)
+	ass.JSONEq(`[{"id":3,"name":"DOCDB front files","description":""}]`, body)
+
+	resp, body = get(t, s, "/api/products/3", nil)
+	ass.Equal(http.StatusOK, resp.StatusCode)
+	var catalog deliveriesJSON
+	ass.NoError(json.Unmarshal([]byte(body), &catalog))
+	ass.Len(catalog.Deliveries, 1)
+	ass.Equal("a.zip", catalog.Deliveries[0].Files[0].FileName)
+	ass.Equal("10 B", catalog.Deliveries[0].Files[0].FileSize)
+	ass.Equal("781e5e245d69b566979b86e28d23f2c7", catalog.Deliveries[0].Files[0].FileChecksum)
+
+	resp, _ = get(t, s, "/api/products/4", nil)
+	ass.Equal(http.StatusNotFound, resp.StatusCode)
+
+	// expired tokens are unauthorized
+	token := s.Token()
+	s.ExpireTokens()
+	resp, _ = get(t, s, "/api/products/3", http.Header{"Authorization": {token}})
+	ass.Equal(http.StatusUnauthorized, resp.StatusCode)
+}
+
+func TestDownload(t *testing.T) {
+	ass := assert.New(t)
+	s := NewServer(testProduct)
+	defer s.Close()
+	path := "/api/products/3/delivery/10/file/20/download"
+
+	resp, body := get(t, s, path, nil)
+	ass.Equal(http.StatusOK, resp.StatusCode)
+	ass.Equal("0123456789", body)
+
+	resp, body = get(t, s, path, http.Header{"Range": {"bytes=4-"}})
+	ass.Equal(http.StatusPartialContent, resp.StatusCode)
+	ass.Equal("bytes 4-9/10", resp.Header.Get("Content-Range"))
+	ass.Equal("456789", body)
+
+	resp, _ = get(t, s, path, http.Header{"Range": {"bytes=20-"}})
+	ass.Equal(http.StatusRequestedRangeNotSatisfiable, resp.StatusCode)
+
+	s.SetRangeSupport(false)
+	resp, body = get(t, s, path, http.Header{"Range": {"bytes=4-"}})
+	ass.Equal(http.StatusOK, resp.StatusCode)
+	ass.Equal("0123456789", body)
+	ass.Equal(4, s.Requests(EndpointDownload))
+	ass.Equal([]string{"", "bytes=4-", "bytes=20-", "bytes=4-"}, s.Ranges())
+}
+
+func TestFaults(t *testing.T) {
+	ass := assert.New(t)
+	s := NewServer(testProduct)
+	defer s.Close()
+	path := "/api/products/3/delivery/10/file/20/download"
+
+	s.AddFaults(
+		Fault{Endpoint: EndpointDownload, StatusCode: http.StatusTooManyRequests, RetryAfter: "1", Times: 2},
+		Fault{Endpoint: EndpointDownload, StatusCode: http.StatusInternalServerError},
+	)
+	// faults of other endpoints are not applied
+	resp, _ := get(t, s, "/api/products/3", nil)
+	ass.Equal(http.StatusOK, resp.StatusCode)
+
+	for _, expected := range []int{http.StatusTooManyRequests, http.StatusTooManyRequests, http.StatusInternalServerError, http.StatusOK} {
+		resp, _ = get(t, s, path, nil)
+		ass.Equal(expected, resp.StatusCode)
+	}
+	ass.Equal(4, s.Requests(EndpointDownload))
+}
+
+func TestDroppedConnection(t *testing.T) {
+	ass := assert.New(t)
+	s := NewServer(testProduct)
+	defer s.Close()
+
+	s.AddFaults(Fault{Endpoint: EndpointDownload, Drop: true, DropAfter: 4})
+	req, _ := http.NewRequest(http.MethodGet, s.URL+"/api/products/3/delivery/10/file/20/download", nil)
+	req.Header.Set("Authorization", s.Token())
+	resp, err := http.DefaultClient.Do(req)
+	ass.NoError(err)
+	body, err := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	ass.Error(err)
+	ass.Equal("0123", string(body))
+}
+
+func TestSlowStream(t *testing.T) {
+	ass := assert.New(t)
+	s := NewServer(testProduct)
+	defer s.Close()
+
+	s.SetBytesPerSecond(50)
+	start := time.Now()
+	_, body := get(t, s, "/api/products/3/delivery/10/file/20/download", nil)
+	ass.Equal("0123456789", body)
+	ass.GreaterOrEqual(time.Since(start), 150*time.Millisecond)
+}
@@ -4,6 +4,7 @@ import (
 	"archive/zip"
 	"bufio"
 	"bytes"
+	"context"
 	"encoding/xml"
 	"fmt"
 	"github.com/krolaw/zipstream"
@@ -16,6 +17,9 @@ import (
 	"sort"
 	"strings"
 	"sync"
+
+	"github.com/max-planck-innovation-competition/go-epo-bdds/pkg/bulkzip"
+	"github.com/max-planck-innovation-competition/go-epo-bdds/pkg/storage"
 )
 
 // StateHandler can be used to track the state of the application
@@ -26,11 +30,12 @@ type StateHandler interface {
 
 // Processor creates a
 type Processor struct {
-	ContentHandler     ContentHandler      // content handler
-	includeAuthorities map[string]struct{} // e.g. EP, WO, etc.
-	includeFileTypes   map[string]struct{} // e.g. CreateDelete, Amend, etc.
-	StateHandler       StateHandler        // optional state handler
-	Workers            int                 // number of workers
+	ContentHandler        ContentHandler        // content handler
+	ContentHandlerContext ContentHandlerContext // optional content handler with the context, used instead of ContentHandler
+	includeAuthorities    map[string]struct{}   // e.g. EP, WO, etc.
+	includeFileTypes      map[string]struct{}   // e.g. CreateDelete, Amend, etc.
+	StateHandler          StateHandler          // optional state handler
+	Workers               int                   // number of workers
 }
 
 // NewProcessor creates a new processor
@@ -55,6 +60,12 @@ func NewFileExportProcessor(destinationPath string) *Processor {
 	return &p
 }
 
+// NewStorageExportProcessor creates a new processor
+// the default handler is StorageExporterLineHandler
+func NewStorageExportProcessor(store storage.Storage, prefix string) *Processor {
+	return NewProcessor().SetContentHandlerContext(StorageExporterLineHandler(store, prefix))
+}
+
 // SetContentHandler sets the content handler
 // you can create your own ContentHandler
 func (p *Processor) SetContentHandler(fn ContentHandler) *Processor {
@@ -62,6 +73,13 @@ func (p *Processor) SetContentHandler(fn ContentHandler) *Processor {
 	return p
 }
 
+// SetContentHandlerContext sets a content handler that receives the context of the processor
+// and is used instead of the ContentHandler
+func (p *Processor) SetContentHandlerContext(fn ContentHandlerContext) *Processor {
+	p.ContentHandlerContext = fn
+	return p
+}
+
 // SetStateHandler adds a state handler
 func (p *Processor) SetStateHandler(stateHandler StateHandler) *Processor {
 	p.StateHandler = stateHandler
@@ -144,11 +162,30 @@ func (p *Processor) skipFileBasedOnFileType(filePath string) bool {
 // ContentHandler is a function that handles the content of a file
 type ContentHandler func(fileName string, fileContent string)
 
+// ContentHandlerContext is a function that handles the content of a file with the context of the processor.
+// If it returns an error, the processing of the zip file stops and the zip file is not marked as done.
+type ContentHandlerContext func(ctx context.Context, fileName string, fileContent string) error
+
+// handleContent passes the content of a file to the content handler
+func (p *Processor) handleContent(ctx context.Context, fileName string, fileContent string) error {
+	if p.ContentHandlerContext != nil {
+		return p.ContentHandlerContext(ctx, fileName, fileContent)
+	}
+	p.ContentHandler(fileName, fileContent)
+	return nil
+}
+
 // regexFileName is used to extract the filename by using attributes from the xml file
 var regexFileName = regexp.MustCompile(`country="([A-Z]{1,3})".*doc-number="([A-Z0-9]{1,15})".*kind="([A-Z0-9]{1,3})"`)
 
 // ProcessDirectory processes a directory
 func (p *Processor) ProcessDirectory(workingDirectoryPath string) (err error) {
+	return p.ProcessDirectoryContext(context.Background(), workingDirectoryPath)
+}
+
+// ProcessDirectoryContext processes a directory
+// and stops processing further files if the context is cancelled
+func (p *Processor) ProcessDirectoryContext(ctx context.Context, workingDirectoryPath string) (err error) {
 	directoryLogger := slog.With("wd", workingDirectoryPath)
 	directoryLogger.Info("process directory")
 
@@ -201,9 +238,14 @@ func (p *Processor) ProcessDirectory(workingDirectoryPath string) (err error) {
 	}
 
 	for i, filePath := range queueFiles {
+		// check if cancelled
+		if ctx.Err() != nil {
+			directoryLogger.With("err", ctx.Err()).Info("processing cancelled")
+			return ctx.Err()
+		}
 		directoryLogger.With("file", filePath).Info("processing file")
 		// process bulk zip file
-		err = p.ProcessBulkZipFile(filePath)
+		err = p.ProcessBulkZipFileContext(ctx, filePath)
 		if err != nil {
 			directoryLogger.With("err", err).Error("failed to process bulk zip file")
 			return err
@@ -222,6 +264,14 @@ func (p *Processor) ProcessDirectory(workingDirectoryPath string) (err error) {
 
 // ProcessBulkZipFile processes a bulk zip file
 func (p *Processor) ProcessBulkZipFile(filePath string) (err error) {
+	return p.ProcessBulkZipFileContext(context.Background(), filePath)
+}
+
+// ProcessBulkZipFileContext processes a bulk zip file.
+// If the context is cancelled, the workers stop after the current exchange document
+// and only the zip files that were processed completely are marked as done.
+// The first error of a zip file is returned after all workers are done.
+func (p *Processor) ProcessBulkZipFileContext(ctx context.Context, filePath string) (err error) {
 	logger := slog.With("filePath", filePath)
 
 	// Open the bulk zip file
@@ -280,6 +330,8 @@ func (p *Processor) ProcessBulkZipFile(filePath string) (err error) {
 	// Set the number of workers
 	fileCh := make(chan *zip.File, len(queueFiles)) // Buffered channel with the number of files
 	var wg sync.WaitGroup
+	var errMu sync.Mutex
+	var errFirst error // first error of the workers
 	total := len(queueFiles)
 
 	// Start the worker pool
@@ -288,12 +340,31 @@ func (p *Processor) ProcessBulkZipFile(filePath string) (err error) {
 		go func(workerId int) {
 			defer wg.Done()
 			for zipFile := range fileCh {
+				// do not start new files if cancelled
+				if ctx.Err() != nil {
+					continue
+				}
 
 				fullPath := filepath.Join(filePath, zipFile.Name)
 				workerLogger := slog.With("workerId", workerId).With("file", zipFile.Name)
 
 				// process zip file
-				p.ProcessZipFile(workerLogger, zipFile)
+				errProcess := p.ProcessZipFileContext(ctx, workerLogger, zipFile)
+				if errProcess != nil && ctx.Err() != nil {
+					// the file was not processed completely
+					workerLogger.Debug("processing of zip file cancelled")
+					continue
+				}
+				if errProcess != nil {
+					// the file was not processed completely and is not marked as done
+					workerLogger.With("err", errProcess).Error("failed to process zip file")
+					errMu.Lock()
+					if errFirst == nil {
+						errFirst = errProcess
+					}
+					errMu.Unlock()
+					continue
+				}
 
 				// mark zip file as finished
 				if p.StateHandler != nil {
@@ -321,12 +392,118 @@ func (p *Processor) ProcessBulkZipFile(filePath string) (err error) {
 	// Wait for all workers to finish
 	wg.Wait()
 
+	// check if cancelled
+	if ctx.Err() != nil {
+		logger.With("err", ctx.Err()).Info("processing cancelled")
+		return ctx.Err()
+	}
+	if errFirst != nil {
+		return errFirst
+	}
+
+	logger.Debug("successfully done")
+	return
+}
+
+// ErrMalformedZipStream is returned if a zip stream can not be read
+var ErrMalformedZipStream = bulkzip.ErrMalformedZipStream
+
+// ProcessBulkZipStream processes a bulk zip file that is read from a stream
+func (p *Processor) ProcessBulkZipStream(name string, r io.Reader) (err error) {
+	return p.ProcessBulkZipStreamContext(context.Background(), name, r)
+}
+
+// ProcessBulkZipStreamContext processes a bulk zip file that is read from a stream,
+// e.g. the body of a download or stdin, without writing it to disk.
+// The name identifies the bulk zip file in the StateHandler, like the file path of ProcessBulkZipFileContext.
+// The zip files within the bulk zip file are processed one after another in the order of the stream,
+// the number of workers is not used.
+// The stream is read to the end, so that a checksum of the stream is verified.
+func (p *Processor) ProcessBulkZipStreamContext(ctx context.Context, name string, r io.Reader) (err error) {
+	logger := slog.With("stream", name)
+
+	zr := bulkzip.NewReader(r)
+	for {
+		// check if cancelled
+		if ctx.Err() != nil {
+			logger.With("err", ctx.Err()).Info("processing cancelled")
+			return ctx.Err()
+		}
+		header, errNext := zr.Next()
+		if errNext == io.EOF {
+			break
+		}
+		if errNext != nil {
+			logger.With("err", errNext).Error("failed to read bulk zip entry")
+			return errNext
+		}
+		path := header.Name
+
+		// check if zip file
+		if header.FileInfo().IsDir() || !strings.Contains(path, "Root/DOC/") || !strings.Contains(path, ".zip") {
+			continue
+		}
+		// skip countries that are not in the list of countries to include
+		if len(p.includeAuthorities) > 0 {
+			if p.skipFileBasedOnAuthority(path) {
+				continue
+			}
+		}
+		// check if state handler is set
+		// if yes then check if the file is already done
+		fullPath := filepath.Join(name, path)
+		if p.StateHandler != nil {
+			skip, errSkip := p.StateHandler.RegisterOrSkip(fullPath)
+			if errSkip != nil {
+				logger.With("err", errSkip).Error("failed to register or skip file")
+			}
+			if skip {
+				// if already done, skip
+				logger.With("zipFile", path).Debug("skipping zip file")
+				continue
+			}
+		}
+
+		// process the zip file while it is read from the stream
+		err = p.processZipStreamContext(ctx, logger.With("zipFile", path), zr)
+		if err != nil {
+			if ctx.Err() != nil {
+				logger.With("err", ctx.Err()).Info("processing cancelled")
+				return ctx.Err()
+			}
+			// the stream can not be continued
+			logger.With("err", err).Error("failed to process zip file")
+			return
+		}
+
+		// mark zip file as finished
+		if p.StateHandler != nil {
+			errMarkDone := p.StateHandler.MarkAsDone(fullPath)
+			if errMarkDone != nil {
+				logger.With("err", errMarkDone).Error("failed to mark zip file as done")
+			}
+		}
+	}
+
+	// read the rest of the stream
+	_, err = io.Copy(io.Discard, r)
+	if err != nil {
+		logger.With("err", err).Error("failed to read the end of the stream")
+		return
+	}
+
 	logger.Debug("successfully done")
 	return
 }
 
 // ProcessZipFile processes a zip file within a bulk zip file
 func (p *Processor) ProcessZipFile(logger *slog.Logger, zipFile *zip.File) {
+	_ = p.ProcessZipFileContext(context.Background(), logger, zipFile)
+}
+
+// ProcessZipFileContext processes a zip file within a bulk zip file
+// and stops after the current exchange document if the context is cancelled
+func (p *Processor) ProcessZipFileContext(ctx context.Context, logger *slog.Logger, zipFile *zip.File) (err error) {
 	logger = logger.With("zipFile", zipFile.Name)
 
 	// Open the zip file
@@ -342,38 +519,50 @@ func (p *Processor) ProcessZipFile(logger *slog.Logger, zipFile *zip.File) {
 		}
 	}(f)
 
+	return p.processZipStreamContext(ctx, logger, f)
+}
+
+// processZipStreamContext processes the entries of a zip file that is read from a stream
+func (p *Processor) processZipStreamContext(ctx context.Context, logger *slog.Logger, r io.Reader) (err error) {
 	// Use zipstream to process the zip entries without loading the entire file into memory
-	zr := zipstream.NewReader(f)
+	zr := bulkzip.NewReader(r)
 
 	for {
-		header, err := zr.Next()
-		if err == io.EOF {
+		header, errNext := zr.Next()
+		if errNext == io.EOF {
 			break
 		}
-		if err != nil {
-			logger.With("err", err).Error("failed to read zip entry")
-			return
+		if errNext != nil {
+			logger.With("err", errNext).Error("failed to read zip entry")
+			return errNext
 		}
 		logger.With("xmlFile", header.Name).Debug("child found")
 
 		// process zip file content
-		err = p.ProcessZipFileContent(logger, header, zr)
+		err = p.processZipFileContent(ctx, logger, header, zr)
 		if err != nil {
 			logger.With("err", err).Error("failed to process zip file content")
 			return
 		}
 	}
+	return
 }
 
 // ProcessZipFileContent processes a zip file content
 func (p *Processor) ProcessZipFileContent(logger *slog.Logger, header *zip.FileHeader, zr *zipstream.Reader) (err error) {
+	return p.processZipFileContent(context.Background(), logger, header, zr)
+}
+
+// processZipFileContent processes a zip file content
+// and stops if the context is cancelled
+func (p *Processor) processZipFileContent(ctx context.Context, logger *slog.Logger, header *zip.FileHeader, zr io.Reader) (err error) {
 	logger = logger.With("xmlFile", header.Name)
 	logger.Debug("process xml file")
 
 	// zr is already positioned at the file content
 	// zr implements io.Reader for the file content
 
-	return p.ProcessExchangeFileContent(logger, zr)
+	return p.processExchangeFileContent(ctx, logger, zr)
 }
 
 // ExchangeDocument represents the structure of the exchange-document
@@ -410,6 +599,12 @@ func extractFileName(line string) string {
 
 // ProcessExchangeFileContent processes an exchange file content
 func (p *Processor) ProcessExchangeFileContent(logger *slog.Logger, fc io.Reader) (err error) {
+	return p.processExchangeFileContent(context.Background(), logger, fc)
+}
+
+// processExchangeFileContent processes an exchange file content
+// and stops before the next exchange document if the context is cancelled
+func (p *Processor) processExchangeFileContent(ctx context.Context, logger *slog.Logger, fc io.Reader) (err error) {
 	// iterate over the lines of the file
 	// not use a xml decoder because the file is too big
 	// and we don't want to load the entire file into memory
@@ -423,11 +618,20 @@ func (p *Processor) ProcessExchangeFileContent(logger *slog.Logger, fc io.Reader
 	var buffer bytes.Buffer
 	tempDoc := ""
 	for {
+		// check if cancelled
+		if ctx.Err() != nil {
+			logger.With("err", ctx.Err()).Debug("processing cancelled")
+			return ctx.Err()
+		}
 		// Read a chunk of data (64KB at a time, adjust as needed)
 		chunk, err := reader.ReadString('>') // Read until the next `>`
-		if err != nil {
+		if err == io.EOF {
 			break
 		}
+		if err != nil {
+			logger.With("err", err).Error("failed to read exchange file")
+			return err
+		}
 		buffer.WriteString(chunk) // Accumulate chunk in buffer
 
 		// Check if `</exch:exchange-document>` appears in this chunk
@@ -447,7 +651,11 @@ func (p *Processor) ProcessExchangeFileContent(logger *slog.Logger, fc io.Reader
 				// parse the exchange-document
 				fileName := extractFileName(line)
 				// process the exchange-document
-				p.ContentHandler(fileName, line)
+				err = p.handleContent(ctx, fileName, line)
+				if err != nil {
+					logger.With("err", err).With("fileName", fileName).Error("failed to handle exchange document")
+					return err
+				}
 			} else if strings.Contains(line, "<exchange-document ") {
 				// remove everything before the exchange-document
 				line = line[strings.Index(line, "<exchange-document "):]
@@ -458,7 +666,11 @@ func (p *Processor) ProcessExchangeFileContent(logger *slog.Logger, fc io.Reader
 				// parse the exchange-document
 				fileName := extractFileName(tempDoc)
 				// process the exchange-document
-				p.ContentHandler(fileName, tempDoc)
+				err = p.handleContent(ctx, fileName, tempDoc)
+				if err != nil {
+					logger.With("err", err).With("fileName", fileName).Error("failed to handle exchange document")
+					return err
+				}
 				tempDoc = ""
 			} else if tempDoc != "" {
 				// add line to tempDoc
@@ -1,10 +1,15 @@
 package epo_docdb
 
 import (
+	"context"
 	"fmt"
 	"log/slog"
 	"os"
+	"path"
 	"path/filepath"
+	"strings"
+
+	"github.com/max-planck-innovation-competition/go-epo-bdds/pkg/storage"
 )
 
 // FileExporterLineHandler processes the files and saves them in the destination folder
@@ -33,6 +38,29 @@ func FileExporterLineHandler(destinationFolderPath string) ContentHandler {
 	}
 }
 
+// StorageExporterLineHandler processes the files and saves them in the storage.
+// The names of the objects are the file names below the prefix.
+// The objects are saved with the context of the processor,
+// an error of the storage stops the processing of the zip file.
+func StorageExporterLineHandler(store storage.Storage, prefix string) ContentHandlerContext {
+	logger := slog.With("prefix", prefix)
+	logger.Debug("started")
+
+	return func(
+		ctx context.Context,
+		fileName string,
+		fileContent string,
+	) (err error) {
+		name := path.Join(prefix, filepath.ToSlash(fileName))
+		_, err = store.Put(ctx, name, strings.NewReader(fileContent))
+		if err != nil {
+			logger.With("err", err).With("fileName", name).Error("failed to save file in storage")
+			return
+		}
+		return
+	}
+}
+
 // SaveFile saves the file with the given content and the given name
 func SaveFile(
 	fileName string,
@@ -65,3 +93,29 @@ func PrintLineHandler(
 ) {
 	fmt.Println(fileName)
 }
+
+// DocumentCounter counts the processed documents per country and kind, e.g. an epo_bbds.PublicationCounter
+type DocumentCounter interface {
+	Count(country, kind string)
+}
+
+// CountingContentHandler counts the documents by the country and the kind of their file name
+// and passes them to the next handler, if it is not nil.
+// The counts can be compared with the statistics of the bulk file.
+func CountingContentHandler(counter DocumentCounter, next ContentHandler) ContentHandler {
+	return func(
+		fileName string,
+		fileContent string,
+	) {
+		// the file name is CC-NR-KIND.xml
+		parts := strings.Split(strings.TrimSuffix(fileName, ".xml"), "-")
+		if len(parts) == 3 {
+			counter.Count(parts[0], parts[2])
+		} else {
+			slog.With("fileName", fileName).Warn("can not count document")
+		}
+		if next != nil {
+			next(fileName, fileContent)
+		}
+	}
+}
@@ -1,10 +1,20 @@
 package epo_docdb
 
 import (
+	"archive/zip"
 	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"github.com/max-planck-innovation-competition/go-epo-bdds/pkg/epo_bbds"
+	"github.com/max-planck-innovation-competition/go-epo-bdds/pkg/storage"
 	"github.com/stretchr/testify/assert"
+	"io"
 	"log/slog"
 	"os"
+	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 )
@@ -137,3 +147,245 @@ func TestProcessBackfileExchangeDocuments(t *testing.T) {
 		t.Error(err)
 	}
 }
+
+// memoryStateHandler is a StateHandler that keeps the state in memory
+type memoryStateHandler struct {
+	mu   sync.Mutex
+	done map[string]bool
+}
+
+func newMemoryStateHandler() *memoryStateHandler {
+	return &memoryStateHandler{done: map[string]bool{}}
+}
+
+func (m *memoryStateHandler) RegisterOrSkip(filePath string) (skip bool, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.done[filePath], nil
+}
+
+func (m *memoryStateHandler) MarkAsDone(filePath string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.done[filePath] = true
+	return nil
+}
+
+// writeTestBulkZip creates a bulk zip file with one inner zip per authority.
+// Each inner zip contains the DE backfile test data.
+func writeTestBulkZip(t *testing.T, dir string, authorities ...string) string {
+	xmlData, err := os.ReadFile("./test-data/DE-backfile.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bulkPath := filepath.Join(dir, "docdb_xml_202407_CreateDelete_001.zip")
+	bulkFile, err := os.Create(bulkPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer bulkFile.Close()
+	bulk := zip.NewWriter(bulkFile)
+	for _, authority := range authorities {
+		var inner bytes.Buffer
+		innerZip := zip.NewWriter(&inner)
+		w, _ := innerZip.Create("DOCDB-202407-CreateDelete-PubDate20240216AndBefore-" + authority + "-0001.xml")
+		_, _ = w.Write(xmlData)
+		_ = innerZip.Close()
+
+		w, _ = bulk.Create("docdb_xml_202407_CreateDelete_001/Root/DOC/DOCDB-202407-CreateDelete-PubDate20240216AndBefore-" + authority + "-0001.zip")
+		_, _ = w.Write(inner.Bytes())
+	}
+	if err := bulk.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return bulkPath
+}
+
+func TestProcessBulkZipFileContext(t *testing.T) {
+	ass := assert.New(t)
+	bulkPath := writeTestBulkZip(t, t.TempDir(), "DE", "EP")
+
+	var mu sync.Mutex
+	count := 0
+	sh := newMemoryStateHandler()
+	p := NewProcessor()
+	p.Workers = 2
+	p.SetStateHandler(sh)
+	p.SetContentHandler(func(fileName string, fileContent string) {
+		mu.Lock()
+		count++
+		mu.Unlock()
+	})
+	err := p.ProcessBulkZipFileContext(context.Background(), bulkPath)
+	ass.NoError(err)
+	ass.Equal(20, count)
+	ass.Len(sh.done, 2)
+}
+
+func TestProcessBulkZipFileContextCancelled(t *testing.T) {
+	ass := assert.New(t)
+	dir := t.TempDir()
+	writeTestBulkZip(t, dir, "DE", "EP")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	count := 0
+	sh := newMemoryStateHandler()
+	p := NewProcessor()
+	p.SetStateHandler(sh)
+	p.SetContentHandler(func(fileName string, fileContent string) {
+		// cancel while processing the first document
+		count++
+		cancel()
+	})
+	err := p.ProcessDirectoryContext(ctx, dir)
+	ass.ErrorIs(err, context.Canceled)
+	ass.Equal(1, count)
+	// the cancelled zip file is not marked as done
+	ass.Empty(sh.done)
+}
+
+func TestStorageExportProcessor(t *testing.T) {
+	ass := assert.New(t)
+	bulkPath := writeTestBulkZip(t, t.TempDir(), "DE")
+	store := storage.NewLocal(t.TempDir())
+
+	p := NewStorageExportProcessor(store, "export")
+	err := p.ProcessBulkZipFileContext(context.Background(), bulkPath)
+	ass.NoError(err)
+	objects, err := store.List(context.Background(), "export/")
+	ass.NoError(err)
+	ass.Len(objects, 10)
+	for _, o := range objects {
+		ass.Greater(o.Size, int64(0))
+	}
+}
+
+// hookStorage calls put before an object is saved
+type hookStorage struct {
+	storage.Storage
+	put func(ctx context.Context, name string) error
+}
+
+func (s hookStorage) Put(ctx context.Context, name string, r io.Reader) (int64, error) {
+	if err := s.put(ctx, name); err != nil {
+		return 0, err
+	}
+	return s.Storage.Put(ctx, name, r)
+}
+
+type testContextKey struct{}
+
+func TestStorageExportProcessorContext(t *testing.T) {
+	ass := assert.New(t)
+	bulkPath := writeTestBulkZip(t, t.TempDir(), "DE")
+	sh := newMemoryStateHandler()
+
+	// the objects are saved with the context of the processor
+	ctx := context.WithValue(context.Background(), testContextKey{}, "processor")
+	count := 0
+	store := hookStorage{Storage: storage.NewLocal(t.TempDir()), put: func(ctx context.Context, name string) error {
+		ass.Equal("processor", ctx.Value(testContextKey{}))
+		count++
+		return nil
+	}}
+	err := NewStorageExportProcessor(store, "export").ProcessBulkZipFileContext(ctx, bulkPath)
+	ass.NoError(err)
+	ass.Equal(10, count)
+
+	// an error of the storage is returned and the zip file is not marked as done
+	store.put = func(ctx context.Context, name string) error {
+		return errors.New("storage not available")
+	}
+	p := NewStorageExportProcessor(store, "export")
+	p.SetStateHandler(sh)
+	err = p.ProcessBulkZipFileContext(context.Background(), bulkPath)
+	ass.EqualError(err, "storage not available")
+	ass.Empty(sh.done)
+}
+
+func TestProcessBulkZipStreamContext(t *testing.T) {
+	ass := assert.New(t)
+	bulkPath := writeTestBulkZip(t, t.TempDir(), "DE", "EP", "US")
+	f, err := os.Open(bulkPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	// the file is only read as a stream
+	count := 0
+	sh := newMemoryStateHandler()
+	_ = sh.MarkAsDone(filepath.Join("bulk.zip", "docdb_xml_202407_CreateDelete_001/Root/DOC/DOCDB-202407-CreateDelete-PubDate20240216AndBefore-US-0001.zip"))
+	p := NewProcessor()
+	p.IncludeAuthorities("DE", "US")
+	p.SetStateHandler(sh)
+	p.SetContentHandler(func(fileName string, fileContent string) {
+		count++
+	})
+	err = p.ProcessBulkZipStreamContext(context.Background(), "bulk.zip", struct{ io.Reader }{f})
+	ass.NoError(err)
+	// EP is excluded and US was already done
+	ass.Equal(10, count)
+	ass.Len(sh.done, 2)
+}
+
+func TestProcessBulkZipStreamContextBroken(t *testing.T) {
+	ass := assert.New(t)
+	bulkPath := writeTestBulkZip(t, t.TempDir(), "DE")
+	data, err := os.ReadFile(bulkPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p := NewProcessor()
+	p.SetContentHandler(func(fileName string, fileContent string) {})
+	// the stream ends within the inner zip file
+	err = p.ProcessBulkZipStreamContext(context.Background(), "bulk.zip", bytes.NewReader(data[:len(data)/2]))
+	ass.ErrorIs(err, ErrMalformedZipStream)
+
+	// the stream is cancelled
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err = p.ProcessBulkZipStreamContext(ctx, "bulk.zip", bytes.NewReader(data))
+	ass.ErrorIs(err, context.Canceled)
+
+	// a panic of the content handler is not recovered
+	p.SetContentHandler(func(fileName string, fileContent string) {
+		panic("content handler")
+	})
+	ass.PanicsWithValue("content handler", func() {
+		_ = p.ProcessBulkZipStreamContext(context.Background(), "bulk.zip", bytes.NewReader(data))
+	})
+}
+
+func TestCountingContentHandler(t *testing.T) {
+	ass := assert.New(t)
+	bulkPath := writeTestBulkZip(t, t.TempDir(), "DE", "EP")
+
+	counter := epo_bbds.NewPublicationCounter()
+	var mu sync.Mutex
+	count := 0
+	p := NewProcessor()
+	p.Workers = 2
+	p.SetContentHandler(CountingContentHandler(counter, func(fileName string, fileContent string) {
+		mu.Lock()
+		count++
+		mu.Unlock()
+	}))
+	err := p.ProcessBulkZipFileContext(context.Background(), bulkPath)
+	ass.NoError(err)
+	ass.Equal(20, count)
+	ass.Equal(map[epo_bbds.StatisticsKey]int{{CC: "DE", KC: "B"}: 20}, counter.Counts())
+
+	// the counts are compared with the statistics of the bulk file
+	stats, err := epo_bbds.ParseStatisticsCSV(strings.NewReader("DOCA095,202407,DOCDB-202407,20,1\n" +
+		"Status,NrOfPNStatus,CC,KC,FirstPN,LastPN,FirstPubDate,LastPubDate,NrOfPN\n" +
+		"A,20,DE,B,1,2,19000101,19000101,20\n"))
+	ass.NoError(err)
+	ass.NoError(stats.Reconcile(counter.Counts()).Err())
+
+	// documents without a country and kind are not counted
+	CountingContentHandler(counter, nil)("unknown.xml", "")
+	ass.Equal(20, counter.Counts()[epo_bbds.StatisticsKey{CC: "DE", KC: "B"}])
+	ass.Len(counter.Counts(), 1)
+}
@@ -0,0 +1,168 @@
+package epo_fulltext
+
+import (
+	"encoding/xml"
+	"strings"
+)
+
+// EpPatentDocument is an EP publication with the full text (ep-patent-document)
+type EpPatentDocument struct {
+	XMLName       xml.Name      `xml:"ep-patent-document"`
+	IDAttr        string        `xml:"id,attr"`   // e.g. EP1234567B1
+	FileAttr      string        `xml:"file,attr"` // e.g. EP04012345NWB1.xml
+	LangAttr      string        `xml:"lang,attr"` // language of the procedure
+	CountryAttr   string        `xml:"country,attr"`
+	DocNumberAttr string        `xml:"doc-number,attr"`
+	KindAttr      string        `xml:"kind,attr"`
+	DatePublAttr  int           `xml:"date-publ,attr"` // YYYYMMDD
+	StatusAttr    string        `xml:"status,attr"`
+	DtdVersion    string        `xml:"dtd-version,attr"`
+	Bibliographic Bibliographic `xml:"SDOBI"`
+	Abstracts     []Text        `xml:"abstract"`
+	Description   *Text         `xml:"description"`
+	Claims        []Claims      `xml:"claims"`         // claims per language
+	AmendedClaims []Claims      `xml:"amended-claims"` // e.g. claims amended under Art. 19 PCT
+}
+
+// FileName constructs the file name from the document attributes
+func (d *EpPatentDocument) FileName() string {
+	return d.CountryAttr + "-" + d.DocNumberAttr + "-" + d.KindAttr + ".xml"
+}
+
+// ClaimsIn returns the claims in a language, e.g. en, or nil
+func (d *EpPatentDocument) ClaimsIn(lang string) *Claims {
+	for i := range d.Claims {
+		if strings.EqualFold(d.Claims[i].LangAttr, lang) {
+			return &d.Claims[i]
+		}
+	}
+	return nil
+}
+
+// Bibliographic is the bibliographic header of the document (SDOBI)
+type Bibliographic struct {
+	LangAttr           string     `xml:"lang,attr"`
+	PublicationNumber  string     `xml:"B100>B110"`
+	DocumentType       string     `xml:"B100>B120>B121"` // e.g. EUROPEAN PATENT SPECIFICATION
+	Kind               string     `xml:"B100>B130"`
+	PublicationDate    int        `xml:"B100>B140>date"` // YYYYMMDD
+	PublicationCountry string     `xml:"B100>B190"`
+	ApplicationNumber  string     `xml:"B200>B210"`
+	FilingDate         int        `xml:"B200>B220>date"` // YYYYMMDD
+	FilingLanguage     string     `xml:"B200>B250"`
+	ProcedureLanguage  string     `xml:"B200>B251EP"`
+	Priorities         []Priority `xml:"B300"`
+	GrantDate          int        `xml:"B400>B450>date"` // YYYYMMDD, mention of the grant
+	IPCR               []string   `xml:"B500>B510EP>classification-ipcr>text"`
+	Titles             Titles     `xml:"B500>B540"`
+	Applicants         []Party    `xml:"B700>B710>B711"`
+	Inventors          []Party    `xml:"B700>B720>B721"`
+	Representatives    []Party    `xml:"B700>B740>B741"`
+	DesignatedStates   []string   `xml:"B800>B840>ctry"`
+}
+
+// Priority is a priority claim
+type Priority struct {
+	Number  string `xml:"B310"`
+	Date    int    `xml:"B320>date"` // YYYYMMDD
+	Country string `xml:"B330>ctry"`
+}
+
+// Party is an applicant, inventor or representative
+type Party struct {
+	Name    string `xml:"snm"`
+	ID      string `xml:"iid"`
+	Street  string `xml:"adr>str"`
+	City    string `xml:"adr>city"`
+	Country string `xml:"adr>ctry"`
+}
+
+// Title is the title of the invention in a language
+type Title struct {
+	Lang  string
+	Title string
+}
+
+// Titles are the titles of the invention.
+// In the document they are pairs of B541 (language) and B542 (title).
+type Titles []Title
+
+// UnmarshalXML pairs the languages with the titles
+func (t *Titles) UnmarshalXML(d *xml.Decoder, start xml.StartElement) (err error) {
+	var lang string
+	for {
+		var token xml.Token
+		token, err = d.Token()
+		if err != nil {
+			return
+		}
+		switch el := token.(type) {
+		case xml.StartElement:
+			var value string
+			err = d.DecodeElement(&value, &el)
+			if err != nil {
+				return
+			}
+			switch el.Name.Local {
+			case "B541":
+				lang = strings.TrimSpace(value)
+			case "B542":
+				*t = append(*t, Title{Lang: lang, Title: strings.TrimSpace(value)})
+			}
+		case xml.EndElement:
+			return
+		}
+	}
+}
+
+// In returns the title in a language, e.g. en
+func (t Titles) In(lang string) string {
+	for _, title := range t {
+		if strings.EqualFold(title.Lang, lang) {
+			return title.Title
+		}
+	}
+	return ""
+}
+
+// Text is a text part of the document with its markup, e.g. the description or the abstract
+type Text struct {
+	IDAttr   string `xml:"id,attr"`
+	LangAttr string `xml:"lang,attr"`
+	InnerXML string `xml:",innerxml"`
+}
+
+// PlainText returns the text without markup, see PlainText
+func (t Text) PlainText() string {
+	return PlainText(t.InnerXML)
+}
+
+// Claims are the claims of the document in a language
+type Claims struct {
+	IDAttr   string  `xml:"id,attr"`
+	LangAttr string  `xml:"lang,attr"`
+	Claims   []Claim `xml:"claim"`
+}
+
+// PlainText returns the claims without markup, one claim per paragraph
+func (c Claims) PlainText() string {
+	texts := make([]string, 0, len(c.Claims))
+	for _, claim := range c.Claims {
+		if text := claim.PlainText(); text != "" {
+			texts = append(texts, text)
+		}
+	}
+	return strings.Join(texts, "\n\n")
+}
+
+// Claim is a claim with its markup
+type Claim struct {
+	IDAttr   string `xml:"id,attr"`
+	NumAttr  string `xml:"num,attr"`
+	InnerXML string `xml:",innerxml"`
+}
+
+// PlainText returns the claim without markup, see PlainText
+func (c Claim) PlainText() string {
+	return PlainText(c.InnerXML)
+}
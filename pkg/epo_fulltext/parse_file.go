@@ -0,0 +1,121 @@
+package epo_fulltext
+
+import (
+	"bufio"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"unicode/utf8"
+)
+
+// ErrNoDocument is returned if an XML file contains no ep-patent-document
+var ErrNoDocument = errors.New("no ep-patent-document")
+
+// newDecoder creates a decoder that resolves the HTML entities of the DTD
+// and reads ISO-8859-1 encoded files
+func newDecoder(r io.Reader) *xml.Decoder {
+	d := xml.NewDecoder(r)
+	d.Strict = false
+	d.Entity = xml.HTMLEntity
+	d.CharsetReader = charsetReader
+	return d
+}
+
+// charsetReader converts ISO-8859-1 to UTF-8
+func charsetReader(charset string, input io.Reader) (io.Reader, error) {
+	switch strings.ToLower(charset) {
+	case "utf-8", "us-ascii":
+		return input, nil
+	case "iso-8859-1", "latin1", "latin-1":
+		return &latin1Reader{r: bufio.NewReader(input)}, nil
+	}
+	return nil, fmt.Errorf("unsupported charset %s", charset)
+}
+
+// latin1Reader converts ISO-8859-1 to UTF-8
+type latin1Reader struct {
+	r *bufio.Reader
+}
+
+func (l *latin1Reader) Read(p []byte) (n int, err error) {
+	for n+utf8.UTFMax <= len(p) {
+		var b byte
+		b, err = l.r.ReadByte()
+		if err != nil {
+			return
+		}
+		n += utf8.EncodeRune(p[n:], rune(b))
+	}
+	return
+}
+
+// ParseXmlFileToStruct reads a whole file (XML) and returns the first EpPatentDocument
+func ParseXmlFileToStruct(filepath string) (doc *EpPatentDocument, err error) {
+	logger := slog.With("filepath", filepath)
+	f, err := os.Open(filepath)
+	if err != nil {
+		logger.With("err", err).Error("failed to read file")
+		return nil, err
+	}
+	defer f.Close()
+	return parseFirst(f)
+}
+
+// ParseXmlStringToStruct gets the XML content and returns the first EpPatentDocument
+func ParseXmlStringToStruct(data string) (doc *EpPatentDocument, err error) {
+	return parseFirst(strings.NewReader(data))
+}
+
+// parseFirst returns the first document of the XML content
+func parseFirst(r io.Reader) (doc *EpPatentDocument, err error) {
+	errFound := errors.New("found")
+	err = decodeDocuments(context.Background(), r, func(d *EpPatentDocument) error {
+		doc = d
+		return errFound
+	})
+	if errors.Is(err, errFound) {
+		return doc, nil
+	}
+	if err == nil {
+		err = ErrNoDocument
+	}
+	slog.With("err", err).Error("failed to unmarshall xml")
+	return nil, err
+}
+
+// decodeDocuments decodes the ep-patent-document elements of the XML content one by one
+// and passes them to fn, so a file with many documents is not loaded into memory at once
+func decodeDocuments(ctx context.Context, r io.Reader, fn func(doc *EpPatentDocument) error) (err error) {
+	d := newDecoder(r)
+	for {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		var token xml.Token
+		token, err = d.Token()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return
+		}
+		start, ok := token.(xml.StartElement)
+		if !ok || start.Name.Local != "ep-patent-document" {
+			continue
+		}
+		doc := &EpPatentDocument{}
+		err = d.DecodeElement(doc, &start)
+		if err != nil {
+			return
+		}
+		err = fn(doc)
+		if err != nil {
+			return
+		}
+	}
+}
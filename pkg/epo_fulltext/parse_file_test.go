@@ -0,0 +1,74 @@
+package epo_fulltext
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseXmlFileToStruct(t *testing.T) {
+	ass := assert.New(t)
+	doc, err := ParseXmlFileToStruct("./test-data/EP-1234567-B1.xml")
+	if !ass.NoError(err) {
+		return
+	}
+	ass.Equal("EP1234567B1", doc.IDAttr)
+	ass.Equal("EP02012345NWB1.xml", doc.FileAttr)
+	ass.Equal("en", doc.LangAttr)
+	ass.Equal(20230405, doc.DatePublAttr)
+	ass.Equal("EP-1234567-B1.xml", doc.FileName())
+
+	b := doc.Bibliographic
+	ass.Equal("1234567", b.PublicationNumber)
+	ass.Equal("EUROPEAN PATENT SPECIFICATION", b.DocumentType)
+	ass.Equal("B1", b.Kind)
+	ass.Equal(20230405, b.PublicationDate)
+	ass.Equal("02012345.6", b.ApplicationNumber)
+	ass.Equal(20020603, b.FilingDate)
+	ass.Equal("de", b.FilingLanguage)
+	ass.Equal("en", b.ProcedureLanguage)
+	ass.Equal(20230405, b.GrantDate)
+	ass.Equal([]Priority{
+		{Number: "10124567", Date: 20010601, Country: "DE"},
+		{Number: "2001-170123", Date: 20010605, Country: "JP"},
+	}, b.Priorities)
+	ass.Len(b.IPCR, 2)
+	ass.Equal(Titles{
+		{Lang: "de", Title: "Pflug mit Rädern"},
+		{Lang: "en", Title: "Plough with wheels"},
+		{Lang: "fr", Title: "Charrue à roues"},
+	}, b.Titles)
+	ass.Equal("Plough with wheels", b.Titles.In("EN"))
+	ass.Equal("", b.Titles.In("es"))
+	ass.Equal([]Party{{Name: "Agrar Maschinen GmbH", ID: "101234567", Street: "Feldweg 1", City: "80331 München", Country: "DE"}}, b.Applicants)
+	ass.Len(b.Inventors, 2)
+	ass.Equal("FR", b.Inventors[1].Country)
+	ass.Equal("Patentanwälte Beispiel", b.Representatives[0].Name)
+	ass.Equal([]string{"AT", "BE", "CH", "DE"}, b.DesignatedStates)
+
+	ass.Len(doc.Abstracts, 1)
+	ass.NotNil(doc.Description)
+	ass.Len(doc.Claims, 3)
+	ass.Len(doc.ClaimsIn("en").Claims, 2)
+	ass.Equal("0002", doc.ClaimsIn("en").Claims[1].NumAttr)
+	ass.Nil(doc.ClaimsIn("es"))
+	ass.Len(doc.AmendedClaims, 1)
+}
+
+func TestParseXmlStringToStruct(t *testing.T) {
+	ass := assert.New(t)
+	doc, err := ParseXmlStringToStruct(`<?xml version="1.0" encoding="ISO-8859-1"?>` +
+		"<ep-patent-document id=\"EP1A1\"><abstract lang=\"de\"><p>R\xe4der</p></abstract></ep-patent-document>")
+	if !ass.NoError(err) {
+		return
+	}
+	ass.Equal("EP1A1", doc.IDAttr)
+	ass.Equal("Räder", doc.AbstractText("de"))
+	ass.Nil(doc.Description)
+	ass.Equal("", doc.DescriptionText())
+
+	_, err = ParseXmlStringToStruct("<other/>")
+	ass.ErrorIs(err, ErrNoDocument)
+	_, err = ParseXmlStringToStruct(`<?xml version="1.0" encoding="UTF-16"?><ep-patent-document/>`)
+	ass.Error(err)
+}
@@ -0,0 +1,116 @@
+package epo_fulltext
+
+import (
+	"encoding/xml"
+	"io"
+	"strings"
+	"unicode"
+)
+
+// blockElements start a new line in the plain text
+var blockElements = map[string]struct{}{
+	"p": {}, "heading": {}, "claim-text": {}, "li": {}, "dt": {}, "dd": {},
+	"row": {}, "tr": {}, "title": {}, "pre": {}, "br": {},
+}
+
+// skippedElements are not included in the plain text,
+// e.g. images, formulas and tables that are not useful as text
+var skippedElements = map[string]struct{}{
+	"img": {}, "maths": {}, "math": {}, "chemistry": {}, "tables": {}, "table": {}, "figure": {},
+}
+
+// PlainText converts the markup of a text part, e.g. the inner XML of the description or a claim,
+// into plain text for text analytics. Paragraphs, headings and claim texts are separated by new lines,
+// whitespace within a paragraph is collapsed to a single space
+// and images, formulas and tables are removed.
+func PlainText(innerXML string) string {
+	d := newDecoder(strings.NewReader("<text>" + innerXML + "</text>"))
+	var lines []string
+	var line strings.Builder
+	endLine := func() {
+		if text := collapseSpace(line.String()); text != "" {
+			lines = append(lines, text)
+		}
+		line.Reset()
+	}
+	skipped := 0
+	for {
+		token, err := d.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			// keep the text that was read before invalid markup
+			break
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			if _, ok := skippedElements[t.Name.Local]; ok {
+				skipped++
+				continue
+			}
+			if _, ok := blockElements[t.Name.Local]; ok && skipped == 0 {
+				endLine()
+			}
+		case xml.EndElement:
+			if _, ok := skippedElements[t.Name.Local]; ok && skipped > 0 {
+				skipped--
+				continue
+			}
+			if _, ok := blockElements[t.Name.Local]; ok && skipped == 0 {
+				endLine()
+			}
+		case xml.CharData:
+			if skipped == 0 {
+				line.Write(t)
+			}
+		}
+	}
+	endLine()
+	return strings.Join(lines, "\n")
+}
+
+// collapseSpace collapses runs of whitespace to a single space and trims the text
+func collapseSpace(s string) string {
+	var b strings.Builder
+	space := false
+	for _, r := range s {
+		if unicode.IsSpace(r) {
+			space = true
+			continue
+		}
+		if space && b.Len() > 0 {
+			b.WriteByte(' ')
+		}
+		space = false
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// DescriptionText returns the description without markup
+func (d *EpPatentDocument) DescriptionText() string {
+	if d.Description == nil {
+		return ""
+	}
+	return d.Description.PlainText()
+}
+
+// ClaimsText returns the claims in a language without markup, e.g. en
+func (d *EpPatentDocument) ClaimsText(lang string) string {
+	claims := d.ClaimsIn(lang)
+	if claims == nil {
+		return ""
+	}
+	return claims.PlainText()
+}
+
+// AbstractText returns the abstract in a language without markup, e.g. en
+func (d *EpPatentDocument) AbstractText(lang string) string {
+	for _, a := range d.Abstracts {
+		if strings.EqualFold(a.LangAttr, lang) {
+			return a.PlainText()
+		}
+	}
+	return ""
+}
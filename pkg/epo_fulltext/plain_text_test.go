@@ -0,0 +1,44 @@
+package epo_fulltext
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPlainText(t *testing.T) {
+	ass := assert.New(t)
+	ass.Equal("", PlainText(""))
+	ass.Equal("a b", PlainText(" a \n\t b "))
+	ass.Equal("Title\nFirst bold text.\nSecond", PlainText(
+		"<heading>Title</heading><p>First <b>bold</b>\n text.</p><p>Second</p>",
+	))
+	ass.Equal("x\ny", PlainText("<p>x<br/>y</p>"))
+	ass.Equal("A B C.", PlainText("<p>A <maths><math><mi>F</mi></math></maths>B <img file=\"a.tif\"/>C.</p>"))
+	ass.Equal("before\nafter", PlainText("<p>before</p><tables><table><row><entry>cell</entry></row></table></tables><p>after</p>"))
+	ass.Equal("a – b ä", PlainText("<p>a &ndash; b &auml;</p>"))
+	// the text before invalid markup is kept
+	ass.Equal("a", PlainText("<p>a</p></x><p>b</p>"))
+}
+
+func TestDocumentPlainText(t *testing.T) {
+	ass := assert.New(t)
+	doc, err := ParseXmlFileToStruct("./test-data/EP-1234567-B1.xml")
+	if !ass.NoError(err) {
+		return
+	}
+	ass.Equal("A plough (1) with wheels.", doc.AbstractText("en"))
+	ass.Equal("", doc.AbstractText("de"))
+	ass.Equal("FIELD OF THE INVENTION\n"+
+		"The invention relates to a plough with wheels, e.g. 103 wheels.\n"+
+		"The force is as usual.\n"+
+		"SUMMARY\n"+
+		"A wheel – or two.", doc.DescriptionText())
+	ass.Equal("A plough comprising:\n"+
+		"a frame; and\n"+
+		"wheels.\n\n"+
+		"The plough according to claim 1, wherein the wheels are made of steel.", doc.ClaimsText("en"))
+	ass.Equal("Pflug mit Rädern.", doc.ClaimsText("DE"))
+	ass.Equal("", doc.ClaimsText("es"))
+	ass.Equal("An amended plough.", doc.AmendedClaims[0].PlainText())
+}
@@ -0,0 +1,271 @@
+package epo_fulltext
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/max-planck-innovation-competition/go-epo-bdds/pkg/bulkzip"
+)
+
+// ErrMalformedZipStream is returned if a zip stream can not be read
+var ErrMalformedZipStream = bulkzip.ErrMalformedZipStream
+
+// DocumentHandler is a function that handles a parsed document,
+// the file name is the name of the XML file within the archive
+type DocumentHandler func(fileName string, doc *EpPatentDocument)
+
+// PrintDocumentHandler is a dummy handler that prints the file name and the id of the document
+func PrintDocumentHandler(fileName string, doc *EpPatentDocument) {
+	fmt.Println(fileName, doc.IDAttr)
+}
+
+// Processor streams the ep-patent-document XML files of the EP full-text bulk archives
+// (epo_bbds.EpoFullTextFrontFilesProductID) into EpPatentDocument structs.
+// The archives can contain the XML files directly or in nested zip files.
+type Processor struct {
+	DocumentHandler DocumentHandler
+	includeKinds    map[string]struct{} // e.g. A1, B1
+}
+
+// NewProcessor creates a new processor
+// the default handler is PrintDocumentHandler
+func NewProcessor() *Processor {
+	return &Processor{
+		DocumentHandler: PrintDocumentHandler,
+		includeKinds:    map[string]struct{}{},
+	}
+}
+
+// SetDocumentHandler sets the document handler
+func (p *Processor) SetDocumentHandler(fn DocumentHandler) *Processor {
+	p.DocumentHandler = fn
+	return p
+}
+
+// IncludeKinds sets the kinds of the documents to include, e.g. B1 and B2 for granted patents.
+// If no kinds are included all documents are included.
+func (p *Processor) IncludeKinds(kinds ...string) *Processor {
+	p.includeKinds = map[string]struct{}{}
+	for _, k := range kinds {
+		p.includeKinds[strings.ToUpper(k)] = struct{}{}
+	}
+	return p
+}
+
+// includesKind checks if the kind of the document is included
+func (p *Processor) includesKind(doc *EpPatentDocument) bool {
+	if len(p.includeKinds) == 0 {
+		return true
+	}
+	_, ok := p.includeKinds[strings.ToUpper(doc.KindAttr)]
+	return ok
+}
+
+// ProcessDirectory processes the archives and XML files of a directory
+func (p *Processor) ProcessDirectory(workingDirectoryPath string) (err error) {
+	return p.ProcessDirectoryContext(context.Background(), workingDirectoryPath)
+}
+
+// ProcessDirectoryContext processes the archives and XML files of a directory in the order of their names
+// and stops processing further files if the context is cancelled
+func (p *Processor) ProcessDirectoryContext(ctx context.Context, workingDirectoryPath string) (err error) {
+	logger := slog.With("wd", workingDirectoryPath)
+	logger.Info("process directory")
+
+	var filePaths []string
+	err = filepath.WalkDir(workingDirectoryPath, func(path string, d fs.DirEntry, errWalk error) error {
+		if errWalk != nil {
+			return errWalk
+		}
+		if d.IsDir() {
+			return nil
+		}
+		ext := strings.ToLower(filepath.Ext(path))
+		if ext == ".zip" || ext == ".xml" {
+			filePaths = append(filePaths, path)
+		}
+		return nil
+	})
+	if err != nil {
+		logger.With("err", err).Error("failed to walk dir")
+		return
+	}
+	sort.Strings(filePaths)
+
+	for i, filePath := range filePaths {
+		if ctx.Err() != nil {
+			logger.With("err", ctx.Err()).Info("processing cancelled")
+			return ctx.Err()
+		}
+		if strings.EqualFold(filepath.Ext(filePath), ".zip") {
+			err = p.ProcessBulkZipFileContext(ctx, filePath)
+		} else {
+			err = p.processXMLFile(ctx, filePath)
+		}
+		if err != nil {
+			logger.With("err", err, "file", filePath).Error("failed to process file")
+			return
+		}
+		logger.
+			With("file", i+1).
+			With("total", len(filePaths)).
+			Info("current progress")
+	}
+	logger.Info("successfully done")
+	return
+}
+
+// processXMLFile processes an XML file of the directory
+func (p *Processor) processXMLFile(ctx context.Context, filePath string) (err error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	return p.processEntry(ctx, filepath.Base(filePath), f)
+}
+
+// ProcessBulkZipFile processes a bulk zip file
+func (p *Processor) ProcessBulkZipFile(filePath string) (err error) {
+	return p.ProcessBulkZipFileContext(context.Background(), filePath)
+}
+
+// ProcessBulkZipFileContext processes a bulk zip file
+// and stops after the current document if the context is cancelled
+func (p *Processor) ProcessBulkZipFileContext(ctx context.Context, filePath string) (err error) {
+	logger := slog.With("filePath", filePath)
+
+	reader, err := zip.OpenReader(filePath)
+	if err != nil {
+		logger.With("err", err).Error("failed to open bulk zip file")
+		return
+	}
+	defer func(reader *zip.ReadCloser) {
+		errClose := reader.Close()
+		if errClose != nil {
+			logger.With("err", errClose).Error("failed to close bulk zip file")
+		}
+	}(reader)
+
+	for _, f := range reader.File {
+		if ctx.Err() != nil {
+			logger.With("err", ctx.Err()).Info("processing cancelled")
+			return ctx.Err()
+		}
+		if f.FileInfo().IsDir() || !isArchiveEntry(f.Name) {
+			continue
+		}
+		var r io.ReadCloser
+		r, err = f.Open()
+		if err != nil {
+			logger.With("err", err, "entry", f.Name).Error("failed to open entry")
+			return
+		}
+		err = p.processEntry(ctx, f.Name, r)
+		_ = r.Close()
+		if err != nil {
+			return
+		}
+	}
+	logger.Debug("successfully done")
+	return
+}
+
+// ProcessBulkZipStream processes a bulk zip file that is read from a stream
+func (p *Processor) ProcessBulkZipStream(name string, r io.Reader) (err error) {
+	return p.ProcessBulkZipStreamContext(context.Background(), name, r)
+}
+
+// ProcessBulkZipStreamContext processes a bulk zip file that is read from a stream,
+// e.g. the body of a download, without writing it to disk.
+// The stream is read to the end, so that a checksum of the stream is verified.
+func (p *Processor) ProcessBulkZipStreamContext(ctx context.Context, name string, r io.Reader) (err error) {
+	logger := slog.With("stream", name)
+	err = p.processZipStream(ctx, r)
+	if err != nil {
+		if ctx.Err() == nil {
+			logger.With("err", err).Error("failed to read bulk zip stream")
+		}
+		return
+	}
+	// read the rest of the stream
+	_, err = io.Copy(io.Discard, r)
+	if err != nil {
+		logger.With("err", err).Error("failed to read the end of the stream")
+		return
+	}
+	logger.Debug("successfully done")
+	return
+}
+
+// processZipStream processes the entries of a zip file that is read from a stream
+func (p *Processor) processZipStream(ctx context.Context, r io.Reader) (err error) {
+	zr := bulkzip.NewReader(r)
+	for {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		header, errNext := zr.Next()
+		if errNext == io.EOF {
+			return
+		}
+		if errNext != nil {
+			return errNext
+		}
+		if header.FileInfo().IsDir() || !isArchiveEntry(header.Name) {
+			continue
+		}
+		err = p.processEntry(ctx, header.Name, zr)
+		if err != nil {
+			return
+		}
+	}
+}
+
+// isArchiveEntry checks if an entry of an archive is an XML or a nested zip file
+func isArchiveEntry(name string) bool {
+	ext := strings.ToLower(filepath.Ext(name))
+	return ext == ".xml" || ext == ".zip"
+}
+
+// processEntry processes a nested zip file or the documents of an XML file.
+// XML files that can not be parsed are logged and skipped,
+// all other errors, e.g. of reading a corrupt archive, are returned.
+func (p *Processor) processEntry(ctx context.Context, name string, r io.Reader) (err error) {
+	if strings.EqualFold(filepath.Ext(name), ".zip") {
+		return p.processZipStream(ctx, r)
+	}
+	err = p.ProcessXMLContext(ctx, name, r)
+	var syntaxErr *xml.SyntaxError
+	if errors.As(err, &syntaxErr) || errors.Is(err, ErrNoDocument) {
+		slog.With("err", err, "xmlFile", name).Warn("failed to parse xml file - skipping")
+		return nil
+	}
+	return
+}
+
+// ProcessXML processes the documents of an XML file
+func (p *Processor) ProcessXML(fileName string, r io.Reader) (err error) {
+	return p.ProcessXMLContext(context.Background(), fileName, r)
+}
+
+// ProcessXMLContext processes the documents of an XML file, which can contain one or more
+// ep-patent-document elements, and passes the included documents to the handler
+func (p *Processor) ProcessXMLContext(ctx context.Context, fileName string, r io.Reader) (err error) {
+	return decodeDocuments(ctx, r, func(doc *EpPatentDocument) error {
+		if p.includesKind(doc) {
+			p.DocumentHandler(fileName, doc)
+		}
+		return nil
+	})
+}
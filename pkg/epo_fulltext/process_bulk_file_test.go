@@ -0,0 +1,178 @@
+package epo_fulltext
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/flate"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// zipEntry is an entry of a test zip file
+type zipEntry struct {
+	name    string
+	content []byte
+}
+
+// zipBytes creates a zip file with the entries in order
+func zipBytes(t *testing.T, entries ...zipEntry) []byte {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, e := range entries {
+		w, err := zw.Create(e.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = w.Write(e.content)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// testDocument is a minimal document
+func testDocument(id, kind string) []byte {
+	return []byte(`<ep-patent-document id="` + id + `" kind="` + kind + `"><claims lang="en"><claim><claim-text>` + id + `</claim-text></claim></claims></ep-patent-document>`)
+}
+
+// testArchive is a bulk archive with an XML file, a nested zip file, an invalid XML file and a PDF
+func testArchive(t *testing.T) []byte {
+	fixture, err := os.ReadFile("./test-data/EP-1234567-B1.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return zipBytes(t,
+		zipEntry{name: "DOC/EP1234567NWB1/EP1234567NWB1.xml", content: fixture},
+		zipEntry{name: "DOC/EP2000001NWA1.zip", content: zipBytes(t,
+			zipEntry{name: "EP2000001NWA1.xml", content: testDocument("EP2000001A1", "A1")},
+			zipEntry{name: "EP2000001NWA1.pdf", content: []byte("pdf")},
+		)},
+		zipEntry{name: "DOC/EP2000002NWA1.xml", content: []byte("<ep-patent-document><claims>")},
+		zipEntry{name: "DOC/EP2000003NWB1.xml", content: testDocument("EP2000003B1", "B1")},
+		zipEntry{name: "index.pdf", content: []byte("pdf")},
+	)
+}
+
+// collect returns a handler that collects the ids of the documents
+func collect(ids *[]string) DocumentHandler {
+	return func(fileName string, doc *EpPatentDocument) {
+		*ids = append(*ids, doc.IDAttr)
+	}
+}
+
+func TestProcessBulkZipFile(t *testing.T) {
+	ass := assert.New(t)
+	filePath := filepath.Join(t.TempDir(), "EPRTBJV2023000014001001.zip")
+	if err := os.WriteFile(filePath, testArchive(t), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var ids []string
+	p := NewProcessor().SetDocumentHandler(collect(&ids))
+	ass.NoError(p.ProcessBulkZipFile(filePath))
+	ass.Equal([]string{"EP1234567B1", "EP2000001A1", "EP2000003B1"}, ids)
+
+	ids = nil
+	p.IncludeKinds("b1", "B2")
+	ass.NoError(p.ProcessBulkZipFileContext(context.Background(), filePath))
+	ass.Equal([]string{"EP1234567B1", "EP2000003B1"}, ids)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	ass.ErrorIs(p.ProcessBulkZipFileContext(ctx, filePath), context.Canceled)
+}
+
+func TestProcessBulkZipStream(t *testing.T) {
+	ass := assert.New(t)
+	content := testArchive(t)
+
+	var ids []string
+	p := NewProcessor().SetDocumentHandler(collect(&ids))
+	r := bytes.NewReader(content)
+	ass.NoError(p.ProcessBulkZipStream("EPRTBJV2023000014001001.zip", r))
+	ass.Equal([]string{"EP1234567B1", "EP2000001A1", "EP2000003B1"}, ids)
+	// the stream is read to the end
+	ass.Equal(0, r.Len())
+
+	// a truncated stream fails
+	err := p.ProcessBulkZipStreamContext(context.Background(), "truncated.zip", bytes.NewReader(content[:len(content)/2]))
+	ass.Error(err)
+
+	// only the reads of the zip stream are recovered, not the handler
+	p.SetDocumentHandler(func(fileName string, doc *EpPatentDocument) {
+		panic("handler")
+	})
+	ass.PanicsWithValue("handler", func() {
+		_ = p.ProcessBulkZipStream("EPRTBJV2023000014001001.zip", bytes.NewReader(content))
+	})
+}
+
+func TestProcessCorruptEntry(t *testing.T) {
+	ass := assert.New(t)
+	name := "DOC/EP2000001NWA1.xml"
+	content := zipBytes(t, zipEntry{name: name, content: testDocument("EP2000001A1", "A1")})
+	// the first block of the deflated data has the reserved block type
+	content[30+len(name)] = 0xff
+	filePath := filepath.Join(t.TempDir(), "corrupt.zip")
+	if err := os.WriteFile(filePath, content, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var ids []string
+	p := NewProcessor().SetDocumentHandler(collect(&ids))
+	var corrupt flate.CorruptInputError
+	ass.ErrorAs(p.ProcessBulkZipFile(filePath), &corrupt)
+	ass.ErrorAs(p.ProcessBulkZipStream("corrupt.zip", bytes.NewReader(content)), &corrupt)
+	ass.Empty(ids)
+}
+
+func TestProcessDirectory(t *testing.T) {
+	ass := assert.New(t)
+	dir := t.TempDir()
+	files := map[string][]byte{
+		"a.zip":     zipBytes(t, zipEntry{name: "EP2000001NWA1.xml", content: testDocument("EP2000001A1", "A1")}),
+		"b/c.xml":   testDocument("EP2000002A1", "A1"),
+		"b/d.xml":   []byte("<ep-patent-document>"),
+		"readme.md": []byte("readme"),
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, content, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var ids []string
+	var fileNames []string
+	p := NewProcessor().SetDocumentHandler(func(fileName string, doc *EpPatentDocument) {
+		ids = append(ids, doc.IDAttr)
+		fileNames = append(fileNames, fileName)
+	})
+	ass.NoError(p.ProcessDirectory(dir))
+	ass.Equal([]string{"EP2000001A1", "EP2000002A1"}, ids)
+	ass.Equal([]string{"EP2000001NWA1.xml", "c.xml"}, fileNames)
+
+	ass.Error(p.ProcessDirectory(filepath.Join(dir, "missing")))
+}
+
+func TestProcessXML(t *testing.T) {
+	ass := assert.New(t)
+	var ids []string
+	p := NewProcessor().SetDocumentHandler(collect(&ids))
+	// a file can contain more than one document
+	content := append(append([]byte("<documents>"), testDocument("EP1A1", "A1")...), testDocument("EP2A1", "A1")...)
+	content = append(content, []byte("</documents>")...)
+	ass.NoError(p.ProcessXML("documents.xml", bytes.NewReader(content)))
+	ass.Equal([]string{"EP1A1", "EP2A1"}, ids)
+}
@@ -0,0 +1,172 @@
+package epo_patstat
+
+import (
+	"archive/zip"
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/max-planck-innovation-competition/go-epo-bdds/pkg/bulkzip"
+)
+
+// ErrMalformedZipStream is returned if a zip stream can not be read
+var ErrMalformedZipStream = bulkzip.ErrMalformedZipStream
+
+// csvWalker walks the CSV files of archives.
+// The CSV files are either directly in an archive or in a zip file per part, e.g. tls201_part01.zip.
+type csvWalker struct {
+	// skip checks if a CSV or zip file of a part is skipped
+	skip func(name string) (bool, error)
+	// visit handles a CSV file, the source is its path within the archives
+	visit func(ctx context.Context, source string, r io.Reader) error
+}
+
+// archiveFiles returns the zip and CSV files of a directory in the order of their names
+func archiveFiles(directoryPath string) (filePaths []string, err error) {
+	err = filepath.WalkDir(directoryPath, func(path string, d fs.DirEntry, errWalk error) error {
+		if errWalk != nil {
+			return errWalk
+		}
+		if d.IsDir() {
+			return nil
+		}
+		ext := strings.ToLower(filepath.Ext(path))
+		if ext == ".zip" || ext == ".csv" {
+			filePaths = append(filePaths, path)
+		}
+		return nil
+	})
+	sort.Strings(filePaths)
+	return
+}
+
+// walkFile walks a zip file or visits a CSV file
+func (w csvWalker) walkFile(ctx context.Context, filePath string) (err error) {
+	if strings.EqualFold(filepath.Ext(filePath), ".zip") {
+		return w.walkZipFile(ctx, filePath)
+	}
+	skip, err := w.skip(filepath.Base(filePath))
+	if err != nil || skip {
+		return
+	}
+	f, err := os.Open(filePath)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	return w.visit(ctx, filePath, f)
+}
+
+// walkZipFile walks the CSV files of a zip file
+func (w csvWalker) walkZipFile(ctx context.Context, filePath string) (err error) {
+	logger := slog.With("filePath", filePath)
+
+	reader, err := zip.OpenReader(filePath)
+	if err != nil {
+		logger.With("err", err).Error("failed to open bulk zip file")
+		return
+	}
+	defer func(reader *zip.ReadCloser) {
+		errClose := reader.Close()
+		if errClose != nil {
+			logger.With("err", errClose).Error("failed to close bulk zip file")
+		}
+	}(reader)
+
+	for _, f := range reader.File {
+		if ctx.Err() != nil {
+			logger.With("err", ctx.Err()).Info("processing cancelled")
+			return ctx.Err()
+		}
+		if f.FileInfo().IsDir() {
+			continue
+		}
+		var skip bool
+		skip, err = w.skipEntry(f.Name)
+		if err != nil {
+			return
+		}
+		if skip {
+			continue
+		}
+		var r io.ReadCloser
+		r, err = f.Open()
+		if err != nil {
+			logger.With("err", err, "entry", f.Name).Error("failed to open entry")
+			return
+		}
+		err = w.walkEntry(ctx, filepath.Join(filePath, f.Name), r)
+		_ = r.Close()
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+// walkZipStream walks the CSV files of a zip file that is read from a stream
+func (w csvWalker) walkZipStream(ctx context.Context, source string, r io.Reader) (err error) {
+	zr := bulkzip.NewReader(r)
+	for {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		header, errNext := zr.Next()
+		if errNext == io.EOF {
+			return
+		}
+		if errNext != nil {
+			return fmt.Errorf("%s: %w", source, errNext)
+		}
+		if header.FileInfo().IsDir() {
+			continue
+		}
+		var skip bool
+		skip, err = w.skipEntry(header.Name)
+		if err != nil {
+			return
+		}
+		if skip {
+			continue
+		}
+		err = w.walkEntry(ctx, filepath.Join(source, header.Name), zr)
+		if err != nil {
+			return
+		}
+	}
+}
+
+// skipEntry checks if an entry of an archive is skipped,
+// entries that are not CSV or zip files are skipped
+func (w csvWalker) skipEntry(name string) (skip bool, err error) {
+	ext := strings.ToLower(filepath.Ext(name))
+	if ext != ".csv" && ext != ".zip" {
+		return true, nil
+	}
+	return w.skip(filepath.Base(name))
+}
+
+// walkEntry walks a zip file or visits a CSV file of an archive
+func (w csvWalker) walkEntry(ctx context.Context, source string, r io.Reader) (err error) {
+	if strings.EqualFold(filepath.Ext(source), ".zip") {
+		return w.walkZipStream(ctx, source, r)
+	}
+	return w.visit(ctx, source, r)
+}
+
+// walkStream walks a zip file that is read from a stream
+// and reads the rest of the stream, so that a checksum of the stream is verified
+func (w csvWalker) walkStream(ctx context.Context, name string, r io.Reader) (err error) {
+	err = w.walkZipStream(ctx, name, r)
+	if err != nil {
+		return
+	}
+	_, err = io.Copy(io.Discard, r)
+	return
+}
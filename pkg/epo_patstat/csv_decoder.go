@@ -0,0 +1,147 @@
+package epo_patstat
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm/schema"
+)
+
+// ErrNoColumns is returned if the header of a CSV file has no column of the model of the table
+var ErrNoColumns = errors.New("no known columns in CSV header")
+
+// dateLayout is the layout of the dates in the CSV files
+const dateLayout = "2006-01-02"
+
+// schemaCache caches the parsed models
+var schemaCache = &sync.Map{}
+
+// column maps a column of a CSV file to a field of a model
+type column struct {
+	index int   // index in the CSV record
+	field []int // index of the field in the model
+	name  string
+}
+
+// decodeCSV decodes the rows of a CSV file with a header into values of the type of the model
+// and passes them to fn. The columns are mapped by the gorm column names of the fields.
+func decodeCSV(part string, model any, r io.Reader, fn func(row reflect.Value) error) (rows int64, err error) {
+	modelType := reflect.TypeOf(model)
+	if modelType.Kind() == reflect.Ptr {
+		modelType = modelType.Elem()
+	}
+	cr := csv.NewReader(r)
+	cr.ReuseRecord = true
+
+	header, err := cr.Read()
+	if err == io.EOF {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("%s: failed to read header: %w", part, err)
+	}
+	columns, err := mapColumns(model, header)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", part, err)
+	}
+
+	for {
+		record, errRead := cr.Read()
+		if errRead == io.EOF {
+			return
+		}
+		if errRead != nil {
+			return rows, fmt.Errorf("%s: %w", part, errRead)
+		}
+		row := reflect.New(modelType).Elem()
+		for _, c := range columns {
+			errSet := setValue(row.FieldByIndex(c.field), record[c.index])
+			if errSet != nil {
+				line, _ := cr.FieldPos(c.index)
+				return rows, fmt.Errorf("%s line %d column %s: %w", part, line, c.name, errSet)
+			}
+		}
+		err = fn(row)
+		if err != nil {
+			return
+		}
+		rows++
+	}
+}
+
+// mapColumns maps the columns of the header to the fields of the model
+func mapColumns(model any, header []string) (columns []column, err error) {
+	s, err := schema.Parse(model, schemaCache, schema.NamingStrategy{})
+	if err != nil {
+		return
+	}
+	for i, name := range header {
+		// the first column can start with a byte order mark
+		name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		field, ok := s.FieldsByDBName[name]
+		if !ok {
+			slog.With("table", s.Table, "column", name).Debug("ignoring unknown column")
+			continue
+		}
+		columns = append(columns, column{index: i, field: field.StructField.Index, name: name})
+	}
+	if len(columns) == 0 {
+		err = fmt.Errorf("%w of %s", ErrNoColumns, s.Table)
+	}
+	return
+}
+
+// setValue converts a value of a CSV file to the type of the field,
+// empty values are the zero value
+func setValue(field reflect.Value, value string) (err error) {
+	if field.Kind() == reflect.String {
+		field.SetString(value)
+		return
+	}
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return
+	}
+	switch field.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		var i int64
+		i, err = strconv.ParseInt(value, 10, field.Type().Bits())
+		field.SetInt(i)
+	case reflect.Float32, reflect.Float64:
+		var f float64
+		f, err = strconv.ParseFloat(value, field.Type().Bits())
+		field.SetFloat(f)
+	case reflect.Bool:
+		// flags are Y or N
+		switch strings.ToUpper(value) {
+		case "Y":
+			field.SetBool(true)
+		case "N":
+			field.SetBool(false)
+		default:
+			var b bool
+			b, err = strconv.ParseBool(value)
+			field.SetBool(b)
+		}
+	default:
+		if field.Type() != reflect.TypeOf(time.Time{}) {
+			return fmt.Errorf("unsupported field type %s", field.Type())
+		}
+		// some exports contain a time after the date
+		if len(value) > len(dateLayout) {
+			value = value[:len(dateLayout)]
+		}
+		var t time.Time
+		t, err = time.Parse(dateLayout, value)
+		field.Set(reflect.ValueOf(t))
+	}
+	return
+}
@@ -0,0 +1,71 @@
+package epo_patstat
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetValue(t *testing.T) {
+	ass := assert.New(t)
+	var row struct {
+		S string
+		I int
+		F float64
+		B bool
+		T time.Time
+		U []string
+	}
+	v := reflect.ValueOf(&row).Elem()
+
+	ass.NoError(setValue(v.Field(0), " A01B 1/00 "))
+	ass.NoError(setValue(v.Field(1), " 42 "))
+	ass.NoError(setValue(v.Field(2), "1.5"))
+	ass.NoError(setValue(v.Field(3), "Y"))
+	ass.NoError(setValue(v.Field(4), "2024-02-29"))
+	ass.Equal(" A01B 1/00 ", row.S)
+	ass.Equal(42, row.I)
+	ass.Equal(1.5, row.F)
+	ass.True(row.B)
+	ass.Equal(time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC), row.T)
+
+	// empty values are the zero value
+	row.I = 0
+	ass.NoError(setValue(v.Field(1), ""))
+	ass.Equal(0, row.I)
+	ass.NoError(setValue(v.Field(3), "n"))
+	ass.False(row.B)
+	ass.NoError(setValue(v.Field(3), "1"))
+	ass.True(row.B)
+
+	ass.Error(setValue(v.Field(1), "1.0"))
+	ass.Error(setValue(v.Field(3), "yes"))
+	ass.Error(setValue(v.Field(4), "29.02.2024"))
+	ass.Error(setValue(v.Field(5), "a"))
+}
+
+func TestDecodeCSV(t *testing.T) {
+	ass := assert.New(t)
+	var titles []Tls202ApplnTitle
+	rows, err := decodeCSV("tls202_part01", &Tls202ApplnTitle{}, strings.NewReader(
+		"appln_title,appln_id\n\"Plough, with \"\"wheels\"\"\",1\n\"multi\nline\",2\n",
+	), func(row reflect.Value) error {
+		titles = append(titles, row.Interface().(Tls202ApplnTitle))
+		return nil
+	})
+	ass.NoError(err)
+	ass.Equal(int64(2), rows)
+	ass.Equal([]Tls202ApplnTitle{
+		{ApplnID: 1, ApplnTitle: `Plough, with "wheels"`},
+		{ApplnID: 2, ApplnTitle: "multi\nline"},
+	}, titles)
+
+	// the line of an invalid value is reported
+	_, err = decodeCSV("tls202_part01", Tls202ApplnTitle{}, strings.NewReader(
+		"appln_id,appln_title\n1,a\n\"2\nx\",b\n",
+	), func(row reflect.Value) error { return nil })
+	ass.ErrorContains(err, "tls202_part01 line 3 column appln_id")
+}
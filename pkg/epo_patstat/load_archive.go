@@ -0,0 +1,124 @@
+package epo_patstat
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"path/filepath"
+)
+
+// walker returns the walker that loads the parts of the included tables
+func (l *Loader) walker() csvWalker {
+	return csvWalker{
+		skip: l.skipPart,
+		visit: func(ctx context.Context, source string, r io.Reader) error {
+			_, err := l.loadCSV(ctx, source, filepath.Base(source), r)
+			return err
+		},
+	}
+}
+
+// skipPart checks if a file of a part can be skipped,
+// because it is not a part of an included table or the part was loaded before
+func (l *Loader) skipPart(name string) (skip bool, err error) {
+	if !l.includesTable(tablePrefix(name)) {
+		return true, nil
+	}
+	return l.isLoaded(partName(name))
+}
+
+// LoadDirectory loads the archives and CSV files of a directory
+func (l *Loader) LoadDirectory(directoryPath string) (err error) {
+	return l.LoadDirectoryContext(context.Background(), directoryPath)
+}
+
+// LoadDirectoryContext loads the archives (e.g. data_PATSTAT_Global_2024_Spring_01.zip)
+// and CSV files (e.g. tls201_part01.csv) of a directory in the order of their names.
+// The parts of a table can be spread over several archives.
+func (l *Loader) LoadDirectoryContext(ctx context.Context, directoryPath string) (err error) {
+	logger := slog.With("wd", directoryPath)
+	logger.Info("load directory")
+
+	filePaths, err := archiveFiles(directoryPath)
+	if err != nil {
+		logger.With("err", err).Error("failed to walk dir")
+		return
+	}
+
+	if l.deferIndexes {
+		err = l.DropIndexes()
+		if err != nil {
+			return
+		}
+	}
+	walker := l.walker()
+	for i, filePath := range filePaths {
+		if ctx.Err() != nil {
+			logger.With("err", ctx.Err()).Info("loading cancelled")
+			return ctx.Err()
+		}
+		err = l.checkEdition(filePath)
+		if err != nil {
+			return
+		}
+		err = walker.walkFile(ctx, filePath)
+		if err != nil {
+			logger.With("err", err, "file", filePath).Error("failed to load file")
+			return
+		}
+		logger.
+			With("file", i+1).
+			With("total", len(filePaths)).
+			Info("current progress")
+	}
+	if l.deferIndexes {
+		err = l.CreateIndexes()
+		if err != nil {
+			return
+		}
+	}
+	logger.Info("successfully done")
+	return
+}
+
+// LoadBulkZipFile loads an archive of PATSTAT Global
+func (l *Loader) LoadBulkZipFile(filePath string) (err error) {
+	return l.LoadBulkZipFileContext(context.Background(), filePath)
+}
+
+// LoadBulkZipFileContext loads an archive of PATSTAT Global.
+// The archive contains the CSV files of the parts, either directly or in a zip file per part,
+// e.g. tls201_part01.zip. Other files, e.g. the documentation, are skipped.
+func (l *Loader) LoadBulkZipFileContext(ctx context.Context, filePath string) (err error) {
+	err = l.checkEdition(filePath)
+	if err != nil {
+		return
+	}
+	return l.walker().walkZipFile(ctx, filePath)
+}
+
+// ProcessBulkZipFileContext loads an archive of PATSTAT Global,
+// so the loader can be used as an epo_bbds.BulkFileProcessor, e.g. in a watcher
+func (l *Loader) ProcessBulkZipFileContext(ctx context.Context, filePath string) error {
+	return l.LoadBulkZipFileContext(ctx, filePath)
+}
+
+// LoadBulkZipStream loads an archive of PATSTAT Global that is read from a stream
+func (l *Loader) LoadBulkZipStream(name string, r io.Reader) (err error) {
+	return l.LoadBulkZipStreamContext(context.Background(), name, r)
+}
+
+// LoadBulkZipStreamContext loads an archive of PATSTAT Global that is read from a stream,
+// e.g. the body of a download, without writing it to disk.
+// The stream is read to the end, so that a checksum of the stream is verified.
+func (l *Loader) LoadBulkZipStreamContext(ctx context.Context, name string, r io.Reader) (err error) {
+	err = l.checkEdition(name)
+	if err != nil {
+		return
+	}
+	err = l.walker().walkStream(ctx, name, r)
+	if err != nil && ctx.Err() == nil {
+		slog.With("err", err, "stream", name).Error("failed to load bulk zip stream")
+	}
+	return
+}
@@ -0,0 +1,191 @@
+package epo_patstat
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// zipEntry is an entry of a test zip file
+type zipEntry struct {
+	name    string
+	content []byte
+}
+
+// zipBytes creates a zip file with the entries in order
+func zipBytes(t *testing.T, entries ...zipEntry) []byte {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, e := range entries {
+		w, err := zw.Create(e.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = w.Write(e.content)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// partZip is a zip file of a part with its CSV file
+func partZip(t *testing.T, part, content string) zipEntry {
+	return zipEntry{
+		name:    part + ".zip",
+		content: zipBytes(t, zipEntry{name: part + ".csv", content: []byte(content)}),
+	}
+}
+
+// writeTestArchives writes a delivery of two archives to a directory
+func writeTestArchives(t *testing.T, dir string, tls201Part02 string) {
+	first := zipBytes(t,
+		zipEntry{name: "documentation.pdf", content: []byte("pdf")},
+		partZip(t, "tls201_part01", "appln_id,appln_auth\n1,EP\n2,US\n"),
+		partZip(t, "tls206_part01", "person_id,person_name\n7,ACME\n"),
+		partZip(t, "tls999_part01", "a,b\n1,2\n"),
+	)
+	second := zipBytes(t,
+		partZip(t, "tls201_part02", tls201Part02),
+		zipEntry{name: "tls209_part01.csv", content: []byte("appln_id,ipc_class_symbol\n1,A01B   1/00\n")},
+	)
+	for name, content := range map[string][]byte{
+		"data_PATSTAT_Global_2024_Spring_01.zip": first,
+		"data_PATSTAT_Global_2024_Spring_02.zip": second,
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), content, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func countRows(t *testing.T, l *Loader, model any) int64 {
+	var count int64
+	if err := l.DB().Model(model).Count(&count).Error; err != nil {
+		t.Fatal(err)
+	}
+	return count
+}
+
+func TestLoadDirectory(t *testing.T) {
+	ass := assert.New(t)
+	dir := t.TempDir()
+	l := newTestLoader(t).SetDeferIndexes(true)
+
+	// the second archive has an invalid row
+	writeTestArchives(t, dir, "appln_id,appln_auth\nthree,DE\n")
+	err := l.LoadDirectory(dir)
+	ass.ErrorContains(err, "tls201_part02")
+	ass.Equal(int64(2), countRows(t, l, &Tls201Appln{}))
+	ass.Equal(int64(1), countRows(t, l, &Tls206Person{}))
+	ass.False(l.DB().Migrator().HasIndex(&Tls201Appln{}, "idx_tls201_appln_nr"))
+
+	// the load is continued with the failed part
+	writeTestArchives(t, dir, "appln_id,appln_auth\n3,DE\n")
+	ass.NoError(l.LoadDirectory(dir))
+	ass.Equal(int64(3), countRows(t, l, &Tls201Appln{}))
+	ass.Equal(int64(1), countRows(t, l, &Tls206Person{}))
+	ass.Equal(int64(1), countRows(t, l, &Tls209ApplnIpc{}))
+	ass.True(l.DB().Migrator().HasIndex(&Tls201Appln{}, "idx_tls201_appln_nr"))
+
+	parts, err := l.LoadedParts()
+	ass.NoError(err)
+	var names []string
+	for _, p := range parts {
+		names = append(names, p.Part)
+	}
+	ass.Equal([]string{"tls201_part01", "tls201_part02", "tls206_part01", "tls209_part01"}, names)
+	ass.Equal(filepath.Join(dir, "data_PATSTAT_Global_2024_Spring_01.zip", "tls201_part01.zip", "tls201_part01.csv"), parts[0].Source)
+
+	// nothing is loaded again
+	ass.NoError(l.LoadDirectory(dir))
+	ass.Equal(int64(3), countRows(t, l, &Tls201Appln{}))
+}
+
+func TestLoadAnotherEdition(t *testing.T) {
+	ass := assert.New(t)
+	dir := t.TempDir()
+	writeTestArchives(t, dir, "appln_id,appln_auth\n3,DE\n")
+	l := newTestLoader(t)
+	ass.NoError(l.LoadDirectory(dir))
+	editions, err := l.Editions()
+	ass.NoError(err)
+	ass.Equal([]string{"2024 Spring"}, editions)
+
+	// the parts of the next edition have the same names and are not skipped
+	next := t.TempDir()
+	content, err := os.ReadFile(filepath.Join(dir, "data_PATSTAT_Global_2024_Spring_01.zip"))
+	ass.NoError(err)
+	ass.NoError(os.WriteFile(filepath.Join(next, "data_PATSTAT_Global_2024_Autumn_01.zip"), content, 0o644))
+	err = l.LoadDirectory(next)
+	ass.ErrorIs(err, ErrEditionMismatch)
+	err = l.LoadBulkZipFile(filepath.Join(next, "data_PATSTAT_Global_2024_Autumn_01.zip"))
+	ass.ErrorIs(err, ErrEditionMismatch)
+	err = l.LoadBulkZipStream("data_PATSTAT_Global_2024_Autumn_01.zip", bytes.NewReader(content))
+	ass.ErrorIs(err, ErrEditionMismatch)
+	ass.Equal(int64(3), countRows(t, l, &Tls201Appln{}))
+
+	// the same edition is continued
+	ass.NoError(l.LoadBulkZipFile(filepath.Join(dir, "data_PATSTAT_Global_2024_Spring_01.zip")))
+}
+
+func TestLoadDirectoryIncludeTables(t *testing.T) {
+	ass := assert.New(t)
+	dir := t.TempDir()
+	writeTestArchives(t, dir, "appln_id,appln_auth\n3,DE\n")
+	if err := os.WriteFile(filepath.Join(dir, "tls206_part02.csv"), []byte("person_id,person_name\n8,ACME\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	l := newTestLoader(t).IncludeTables("tls206")
+
+	ass.NoError(l.LoadDirectoryContext(context.Background(), dir))
+	ass.Equal(int64(0), countRows(t, l, &Tls201Appln{}))
+	ass.Equal(int64(2), countRows(t, l, &Tls206Person{}))
+	ass.Equal(int64(0), countRows(t, l, &Tls209ApplnIpc{}))
+}
+
+func TestLoadBulkZipStream(t *testing.T) {
+	ass := assert.New(t)
+	dir := t.TempDir()
+	writeTestArchives(t, dir, "appln_id,appln_auth\n3,DE\n")
+	l := newTestLoader(t)
+
+	for _, name := range []string{"data_PATSTAT_Global_2024_Spring_01.zip", "data_PATSTAT_Global_2024_Spring_02.zip"} {
+		content, err := os.ReadFile(filepath.Join(dir, name))
+		ass.NoError(err)
+		r := bytes.NewReader(content)
+		ass.NoError(l.LoadBulkZipStreamContext(context.Background(), name, r))
+		// the stream is read to the end
+		ass.Equal(0, r.Len())
+	}
+	ass.Equal(int64(3), countRows(t, l, &Tls201Appln{}))
+	ass.Equal(int64(1), countRows(t, l, &Tls209ApplnIpc{}))
+
+	// a truncated stream fails
+	content, err := os.ReadFile(filepath.Join(dir, "data_PATSTAT_Global_2024_Spring_01.zip"))
+	ass.NoError(err)
+	l = newTestLoader(t)
+	err = l.LoadBulkZipStream("truncated.zip", bytes.NewReader(content[:len(content)/2]))
+	ass.Error(err)
+}
+
+func TestLoadBulkZipFileCancelled(t *testing.T) {
+	ass := assert.New(t)
+	dir := t.TempDir()
+	writeTestArchives(t, dir, "appln_id,appln_auth\n3,DE\n")
+	l := newTestLoader(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := l.ProcessBulkZipFileContext(ctx, filepath.Join(dir, "data_PATSTAT_Global_2024_Spring_01.zip"))
+	ass.ErrorIs(err, context.Canceled)
+	ass.Equal(int64(0), countRows(t, l, &Tls201Appln{}))
+}
@@ -0,0 +1,101 @@
+package epo_patstat
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"reflect"
+
+	"gorm.io/gorm"
+)
+
+// LoadCSV loads a CSV file of a part, e.g. tls201_part01, into its table
+func (l *Loader) LoadCSV(part string, r io.Reader) (rows int64, err error) {
+	return l.LoadCSVContext(context.Background(), part, r)
+}
+
+// LoadCSVContext loads a CSV file of a part, e.g. tls201_part01, into its table.
+// The columns are mapped by the names in the header, so the order of the columns does not matter.
+// The part is skipped if it was loaded before. If the context is cancelled,
+// no rows of the part are kept.
+func (l *Loader) LoadCSVContext(ctx context.Context, part string, r io.Reader) (rows int64, err error) {
+	return l.loadCSV(ctx, part, part, r)
+}
+
+// loadCSV loads a CSV file of a part from a source into its table in one transaction
+func (l *Loader) loadCSV(ctx context.Context, source, part string, r io.Reader) (rows int64, err error) {
+	part = partName(part)
+	logger := slog.With("part", part, "source", source)
+	model, err := modelOf(part)
+	if err != nil {
+		logger.With("err", err).Error("failed to load part")
+		return
+	}
+	loaded, err := l.isLoaded(part)
+	if err != nil {
+		logger.With("err", err).Error("failed to check loaded part")
+		return
+	}
+	if loaded {
+		logger.Debug("part already loaded - skipping")
+		return
+	}
+	err = l.checkEdition(source)
+	if err != nil {
+		return
+	}
+
+	logger.Info("load part")
+	start := l.now()
+	err = l.db.Transaction(func(tx *gorm.DB) (errTx error) {
+		rows, errTx = l.insertCSV(ctx, tx, part, model, r)
+		if errTx != nil {
+			return
+		}
+		return tx.Create(&LoadedPart{
+			Part:     part,
+			Table:    tablePrefix(part),
+			Edition:  editionOf(source),
+			Source:   source,
+			Rows:     rows,
+			LoadedAt: l.now(),
+		}).Error
+	})
+	if err != nil {
+		if ctx.Err() == nil {
+			logger.With("err", err).Error("failed to load part")
+		}
+		return 0, err
+	}
+	logger.With("rows", rows, "duration", l.now().Sub(start)).Info("part loaded")
+	return
+}
+
+// insertCSV inserts the rows of a CSV file in batches
+func (l *Loader) insertCSV(ctx context.Context, tx *gorm.DB, part string, model any, r io.Reader) (rows int64, err error) {
+	modelType := reflect.TypeOf(model).Elem()
+	batch := reflect.MakeSlice(reflect.SliceOf(modelType), 0, l.batchSize)
+	flush := func() error {
+		if batch.Len() == 0 {
+			return nil
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		errCreate := tx.Create(batch.Interface()).Error
+		batch = reflect.MakeSlice(reflect.SliceOf(modelType), 0, l.batchSize)
+		return errCreate
+	}
+	rows, err = decodeCSV(part, model, r, func(row reflect.Value) error {
+		batch = reflect.Append(batch, row)
+		if batch.Len() < l.batchSize {
+			return nil
+		}
+		return flush()
+	})
+	if err != nil {
+		return
+	}
+	err = flush()
+	return
+}
@@ -0,0 +1,106 @@
+package epo_patstat
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testTls201CSV = "\ufeff" + `appln_id,appln_auth,appln_nr,appln_kind,appln_filing_date,appln_filing_year,unknown_column,granted,docdb_family_id
+1,EP,20100001,A,2010-01-05,2010,x,Y,100
+2,US,"12,345",A,9999-12-31,9999,x,N,
+3,DE,102010000001,U,2010-03-01 00:00:00,2010,x,N,300
+4,EP,20100004,A,2010-04-01,2010,x,N,100
+5,EP,20100005,A,2010-05-01,2010,x,Y,500
+`
+
+func TestLoadCSV(t *testing.T) {
+	ass := assert.New(t)
+	l := newTestLoader(t).SetBatchSize(2)
+
+	rows, err := l.LoadCSV("tls201_part01.csv", strings.NewReader(testTls201CSV))
+	ass.NoError(err)
+	ass.Equal(int64(5), rows)
+
+	var applications []Tls201Appln
+	ass.NoError(l.DB().Order("appln_id").Find(&applications).Error)
+	if ass.Len(applications, 5) {
+		ass.Equal("EP", applications[0].ApplnAuth)
+		ass.Equal(time.Date(2010, 1, 5, 0, 0, 0, 0, time.UTC), applications[0].ApplnFilingDate.UTC())
+		ass.Equal(100, applications[0].DocdbFamilyID)
+		ass.Equal("12,345", applications[1].ApplnNr)
+		ass.Equal(9999, applications[1].ApplnFilingDate.Year())
+		ass.Equal(0, applications[1].DocdbFamilyID)
+		ass.Equal(time.Date(2010, 3, 1, 0, 0, 0, 0, time.UTC), applications[2].ApplnFilingDate.UTC())
+	}
+
+	// the part is only loaded once
+	rows, err = l.LoadCSV("tls201_part01.csv", strings.NewReader(testTls201CSV))
+	ass.NoError(err)
+	ass.Equal(int64(0), rows)
+	parts, err := l.LoadedParts()
+	ass.NoError(err)
+	if ass.Len(parts, 1) {
+		ass.Equal("tls201_part01", parts[0].Part)
+		ass.Equal("tls201", parts[0].Table)
+		ass.Equal(int64(5), parts[0].Rows)
+	}
+
+	// the columns are mapped by the header
+	rows, err = l.LoadCSV("tls206_part01", strings.NewReader("psn_name,person_id,nuts_level\n\"ACME, INC\",7,2\n"))
+	ass.NoError(err)
+	ass.Equal(int64(1), rows)
+	var person Tls206Person
+	ass.NoError(l.DB().First(&person, 7).Error)
+	ass.Equal("ACME, INC", person.PsnName)
+	ass.Equal(2, person.NutsLevel)
+
+	// an empty file has no rows
+	rows, err = l.LoadCSV("tls209_part01", strings.NewReader(""))
+	ass.NoError(err)
+	ass.Equal(int64(0), rows)
+}
+
+func TestLoadCSVInvalid(t *testing.T) {
+	ass := assert.New(t)
+	l := newTestLoader(t).SetBatchSize(2)
+
+	// no rows of a failed part are kept
+	invalid := strings.Replace(testTls201CSV, "2010-04-01", "01.04.2010", 1)
+	_, err := l.LoadCSV("tls201_part01", strings.NewReader(invalid))
+	ass.ErrorContains(err, "tls201_part01 line 5 column appln_filing_date")
+	var count int64
+	ass.NoError(l.DB().Model(&Tls201Appln{}).Count(&count).Error)
+	ass.Equal(int64(0), count)
+	parts, err := l.LoadedParts()
+	ass.NoError(err)
+	ass.Empty(parts)
+
+	// the part is loaded again
+	rows, err := l.LoadCSV("tls201_part01", strings.NewReader(testTls201CSV))
+	ass.NoError(err)
+	ass.Equal(int64(5), rows)
+
+	_, err = l.LoadCSV("tls999_part01", strings.NewReader("a,b\n"))
+	ass.ErrorIs(err, ErrUnknownTable)
+	_, err = l.LoadCSV("tls202_part01", strings.NewReader("a,b\n1,2\n"))
+	ass.ErrorIs(err, ErrNoColumns)
+	_, err = l.LoadCSV("tls202_part01", strings.NewReader("appln_id,appln_title\n1,\"unterminated\n"))
+	ass.Error(err)
+}
+
+func TestLoadCSVCancelled(t *testing.T) {
+	ass := assert.New(t)
+	l := newTestLoader(t).SetBatchSize(2)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := l.LoadCSVContext(ctx, "tls201_part01", strings.NewReader(testTls201CSV))
+	ass.ErrorIs(err, context.Canceled)
+	var count int64
+	ass.NoError(l.DB().Model(&Tls201Appln{}).Count(&count).Error)
+	ass.Equal(int64(0), count)
+}
@@ -0,0 +1,301 @@
+package epo_patstat
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// DefaultBatchSize is the default number of rows per insert
+const DefaultBatchSize = 500
+
+// ErrUnknownTable is returned if a table has no model in Tables
+var ErrUnknownTable = errors.New("unknown PATSTAT table")
+
+// ErrEditionMismatch is returned if an edition is loaded into a database that contains another edition
+var ErrEditionMismatch = errors.New("database contains another PATSTAT edition")
+
+// LoadedPart is a part of a table that was loaded completely.
+// The rows of a part and the loaded part are inserted in one transaction,
+// so a part is either loaded completely or not at all.
+type LoadedPart struct {
+	Part     string `gorm:"primaryKey"` // e.g. tls201_part01
+	Table    string `gorm:"index"`      // e.g. tls201
+	Edition  string `gorm:"index"`      // e.g. 2024 Spring, empty if the source does not name the edition
+	Source   string // archive or file the part was loaded from
+	Rows     int64
+	LoadedAt time.Time
+}
+
+// TableName is the name of the table of the loaded parts
+func (LoadedPart) TableName() string { return "patstat_loaded_parts" }
+
+// Loader loads the CSV files of PATSTAT Global into a database.
+// The tables are split into parts, e.g. tls201_part01.csv, that can be spread over several archives.
+// Parts that were loaded completely are skipped, so an interrupted load can be continued.
+// A database holds one edition, e.g. 2024 Spring, the archives of another edition
+// are refused with ErrEditionMismatch.
+type Loader struct {
+	db            *gorm.DB
+	includeTables map[string]struct{} // e.g. tls201, tls206
+	batchSize     int
+	deferIndexes  bool
+	now           func() time.Time // replaceable for tests
+}
+
+// OpenLoader opens or creates a SQLite database file and migrates the tables
+func OpenLoader(filePath string) (l *Loader, err error) {
+	db, err := gorm.Open(sqlite.Open(filePath), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		slog.With("err", err, "filePath", filePath).Error("failed to open patstat database")
+		return
+	}
+	return NewLoader(db)
+}
+
+// NewLoader creates a loader for an existing database and migrates the tables
+func NewLoader(db *gorm.DB) (l *Loader, err error) {
+	models := []any{&LoadedPart{}}
+	for _, table := range tableNames() {
+		models = append(models, Tables[table])
+	}
+	err = db.AutoMigrate(models...)
+	if err != nil {
+		slog.With("err", err).Error("failed to migrate patstat tables")
+		return
+	}
+	return &Loader{
+		db:            db,
+		includeTables: map[string]struct{}{},
+		batchSize:     DefaultBatchSize,
+		now:           time.Now,
+	}, nil
+}
+
+// DB returns the database of the loader for queries
+func (l *Loader) DB() *gorm.DB {
+	return l.db
+}
+
+// Close closes the database connection
+func (l *Loader) Close() error {
+	sqlDB, err := l.db.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.Close()
+}
+
+// IncludeTables sets the tables to load, e.g. tls201 or tls201_appln.
+// If no tables are included all tables with a model are loaded.
+func (l *Loader) IncludeTables(tables ...string) *Loader {
+	l.includeTables = map[string]struct{}{}
+	for _, t := range tables {
+		l.includeTables[tablePrefix(t)] = struct{}{}
+	}
+	return l
+}
+
+// SetBatchSize sets the number of rows per insert, the default is DefaultBatchSize
+func (l *Loader) SetBatchSize(batchSize int) *Loader {
+	if batchSize > 0 {
+		l.batchSize = batchSize
+	}
+	return l
+}
+
+// SetDeferIndexes drops the indexes of the included tables before a directory is loaded
+// and creates them after all parts were loaded, which is much faster for a complete load.
+// If the load fails, the indexes are created by the next successful load or by CreateIndexes.
+func (l *Loader) SetDeferIndexes(deferIndexes bool) *Loader {
+	l.deferIndexes = deferIndexes
+	return l
+}
+
+// includesTable checks if the table is loaded
+func (l *Loader) includesTable(table string) bool {
+	if _, ok := Tables[table]; !ok {
+		return false
+	}
+	if len(l.includeTables) == 0 {
+		return true
+	}
+	_, ok := l.includeTables[table]
+	return ok
+}
+
+// includedTables returns the included tables in order
+func (l *Loader) includedTables() (tables []string) {
+	for _, table := range tableNames() {
+		if l.includesTable(table) {
+			tables = append(tables, table)
+		}
+	}
+	return
+}
+
+// LoadedParts returns the parts that were loaded completely
+func (l *Loader) LoadedParts() (parts []LoadedPart, err error) {
+	err = l.db.Order("part").Find(&parts).Error
+	return
+}
+
+// Editions returns the editions that were loaded into the database,
+// parts from sources without an edition in their name are not included
+func (l *Loader) Editions() (editions []string, err error) {
+	err = l.db.Model(&LoadedPart{}).Where("edition <> ''").Distinct().Order("edition").Pluck("edition", &editions).Error
+	return
+}
+
+// checkEdition checks that the edition of a source is the edition of the database.
+// The parts are identified by their names, which are the same in every edition,
+// so a second edition can not be loaded into the same database.
+func (l *Loader) checkEdition(source string) (err error) {
+	edition := editionOf(source)
+	if edition == "" {
+		return
+	}
+	editions, err := l.Editions()
+	if err != nil {
+		return
+	}
+	for _, e := range editions {
+		if e != edition {
+			err = fmt.Errorf("%w: %s is loaded, %s needs a new database", ErrEditionMismatch, e, edition)
+			slog.With("err", err, "source", source).Error("failed to load edition")
+			return
+		}
+	}
+	return
+}
+
+// isLoaded checks if the part was loaded completely
+func (l *Loader) isLoaded(part string) (loaded bool, err error) {
+	var count int64
+	err = l.db.Model(&LoadedPart{}).Where("part = ?", part).Count(&count).Error
+	return count > 0, err
+}
+
+// CreateIndexes creates the missing indexes of the included tables
+func (l *Loader) CreateIndexes() (err error) {
+	for _, table := range l.includedTables() {
+		var names []string
+		names, err = l.indexNames(table)
+		if err != nil {
+			return
+		}
+		for _, name := range names {
+			if l.db.Migrator().HasIndex(Tables[table], name) {
+				continue
+			}
+			slog.With("table", table, "index", name).Info("create index")
+			err = l.db.Migrator().CreateIndex(Tables[table], name)
+			if err != nil {
+				slog.With("err", err, "table", table, "index", name).Error("failed to create index")
+				return
+			}
+		}
+	}
+	return
+}
+
+// DropIndexes drops the indexes of the included tables, the primary keys are kept
+func (l *Loader) DropIndexes() (err error) {
+	for _, table := range l.includedTables() {
+		var names []string
+		names, err = l.indexNames(table)
+		if err != nil {
+			return
+		}
+		for _, name := range names {
+			if !l.db.Migrator().HasIndex(Tables[table], name) {
+				continue
+			}
+			err = l.db.Migrator().DropIndex(Tables[table], name)
+			if err != nil {
+				slog.With("err", err, "table", table, "index", name).Error("failed to drop index")
+				return
+			}
+		}
+	}
+	return
+}
+
+// indexNames returns the names of the indexes of the model of a table
+func (l *Loader) indexNames(table string) (names []string, err error) {
+	stmt := &gorm.Statement{DB: l.db}
+	err = stmt.Parse(Tables[table])
+	if err != nil {
+		return
+	}
+	for name := range stmt.Schema.ParseIndexes() {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return
+}
+
+// tableNames returns the names of the tables with a model in order
+func tableNames() (tables []string) {
+	for table := range Tables {
+		tables = append(tables, table)
+	}
+	sort.Strings(tables)
+	return
+}
+
+// tablePrefix returns the prefix of a table, part or file name, e.g. tls201 for tls201_part01.csv
+func tablePrefix(name string) string {
+	name = strings.ToLower(name)
+	if i := strings.IndexAny(name, "_."); i >= 0 {
+		name = name[:i]
+	}
+	return name
+}
+
+// regexEdition matches the edition in the name of a delivery or an archive,
+// e.g. PATSTAT Global 2024 Spring or data_PATSTAT_Global_2024_Spring_01.zip
+var regexEdition = regexp.MustCompile(`(?i)patstat[ _]global[ _](\d{4})[ _](spring|autumn)`)
+
+// editionOf returns the edition of a source, e.g. 2024 Spring, or an empty string if the source does not name it
+func editionOf(source string) string {
+	matches := regexEdition.FindAllStringSubmatch(source, -1)
+	if len(matches) == 0 {
+		return ""
+	}
+	// the archive name is more specific than the directory
+	m := matches[len(matches)-1]
+	season := strings.ToLower(m[2])
+	return m[1] + " " + strings.ToUpper(season[:1]) + season[1:]
+}
+
+// partName returns the name of the part of a file name, e.g. tls201_part01 for dir/tls201_part01.csv
+func partName(fileName string) string {
+	fileName = strings.ToLower(fileName)
+	if i := strings.LastIndexAny(fileName, "/\\"); i >= 0 {
+		fileName = fileName[i+1:]
+	}
+	if i := strings.LastIndex(fileName, "."); i >= 0 {
+		fileName = fileName[:i]
+	}
+	return fileName
+}
+
+// modelOf returns the model of the table of a part
+func modelOf(part string) (model any, err error) {
+	model, ok := Tables[tablePrefix(part)]
+	if !ok {
+		err = fmt.Errorf("%w: %s", ErrUnknownTable, part)
+	}
+	return
+}
@@ -0,0 +1,81 @@
+package epo_patstat
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestLoader(t *testing.T) *Loader {
+	l, err := OpenLoader(filepath.Join(t.TempDir(), "patstat.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = l.Close() })
+	return l
+}
+
+func TestNewLoader(t *testing.T) {
+	ass := assert.New(t)
+	l := newTestLoader(t)
+	for _, model := range Tables {
+		ass.True(l.DB().Migrator().HasTable(model))
+	}
+	ass.True(l.DB().Migrator().HasTable(&LoadedPart{}))
+	ass.True(l.DB().Migrator().HasIndex(&Tls201Appln{}, "idx_tls201_appln_nr"))
+
+	// the tables can be migrated again
+	_, err := NewLoader(l.DB())
+	ass.NoError(err)
+}
+
+func TestLoaderIndexes(t *testing.T) {
+	ass := assert.New(t)
+	l := newTestLoader(t)
+	migrator := l.DB().Migrator()
+
+	l.IncludeTables("tls211_pat_publn")
+	ass.NoError(l.DropIndexes())
+	ass.False(migrator.HasIndex(&Tls211PatPubln{}, "idx_tls211_publn_nr"))
+	ass.False(migrator.HasIndex(&Tls211PatPubln{}, "idx_tls211_pat_publn_appln_id"))
+	// other tables are not changed
+	ass.True(migrator.HasIndex(&Tls201Appln{}, "idx_tls201_appln_nr"))
+
+	ass.NoError(l.CreateIndexes())
+	ass.True(migrator.HasIndex(&Tls211PatPubln{}, "idx_tls211_publn_nr"))
+	ass.True(migrator.HasIndex(&Tls211PatPubln{}, "idx_tls211_pat_publn_appln_id"))
+}
+
+func TestLoaderIncludeTables(t *testing.T) {
+	ass := assert.New(t)
+	l := newTestLoader(t)
+	ass.True(l.includesTable("tls201"))
+	ass.False(l.includesTable("tls999"))
+
+	l.IncludeTables("TLS201", "tls206_person")
+	ass.Equal([]string{"tls201", "tls206"}, l.includedTables())
+	ass.False(l.includesTable("tls209"))
+}
+
+func TestPartName(t *testing.T) {
+	ass := assert.New(t)
+	ass.Equal("tls201_part01", partName("data/tls201_part01.csv"))
+	ass.Equal("tls201_part01", partName("TLS201_PART01.zip"))
+	ass.Equal("tls206", partName("tls206"))
+	ass.Equal("tls201", tablePrefix("tls201_part01.csv"))
+	ass.Equal("tls206", tablePrefix("tls206.csv"))
+	ass.Equal("data", tablePrefix("data_PATSTAT_Global_2024_Spring_01.zip"))
+
+	_, err := modelOf("tls999_part01")
+	ass.ErrorIs(err, ErrUnknownTable)
+}
+
+func TestEditionOf(t *testing.T) {
+	ass := assert.New(t)
+	ass.Equal("2024 Spring", editionOf("data_PATSTAT_Global_2024_Spring_01.zip"))
+	ass.Equal("2023 Autumn", editionOf("/data/17/PATSTAT Global 2023 Autumn/tls201_part01.csv"))
+	// the archive name is more specific than the directory
+	ass.Equal("2024 Spring", editionOf("/data/PATSTAT Global 2023 Autumn/data_patstat_global_2024_spring_01.zip/tls201_part01.zip"))
+	ass.Equal("", editionOf("tls201_part01.csv"))
+}
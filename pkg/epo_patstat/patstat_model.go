@@ -0,0 +1,189 @@
+package epo_patstat
+
+import "time"
+
+// The models follow the data catalog of PATSTAT Global.
+// The columns are mapped by the header names of the CSV files,
+// columns of the CSV files that are not in a model are ignored.
+// Unknown dates are 9999-12-31 like in PATSTAT.
+
+// Tls201Appln is an application
+type Tls201Appln struct {
+	ApplnID            int       `gorm:"column:appln_id;primaryKey;autoIncrement:false"`
+	ApplnAuth          string    `gorm:"column:appln_auth;index:idx_tls201_appln_nr,priority:1"`
+	ApplnNr            string    `gorm:"column:appln_nr;index:idx_tls201_appln_nr,priority:2"`
+	ApplnKind          string    `gorm:"column:appln_kind"`
+	ApplnFilingDate    time.Time `gorm:"column:appln_filing_date"`
+	ApplnFilingYear    int       `gorm:"column:appln_filing_year"`
+	ApplnNrEpodoc      string    `gorm:"column:appln_nr_epodoc"`
+	ApplnNrOriginal    string    `gorm:"column:appln_nr_original"`
+	IprType            string    `gorm:"column:ipr_type"`
+	ReceivingOffice    string    `gorm:"column:receiving_office"`
+	InternatApplnID    int       `gorm:"column:internat_appln_id"`
+	IntPhase           string    `gorm:"column:int_phase"`
+	RegPhase           string    `gorm:"column:reg_phase"`
+	NatPhase           string    `gorm:"column:nat_phase"`
+	EarliestFilingDate time.Time `gorm:"column:earliest_filing_date"`
+	EarliestFilingYear int       `gorm:"column:earliest_filing_year"`
+	EarliestFilingID   int       `gorm:"column:earliest_filing_id"`
+	EarliestPublnDate  time.Time `gorm:"column:earliest_publn_date"`
+	EarliestPublnYear  int       `gorm:"column:earliest_publn_year"`
+	EarliestPatPublnID int       `gorm:"column:earliest_pat_publn_id"`
+	Granted            string    `gorm:"column:granted"` // Y or N
+	DocdbFamilyID      int       `gorm:"column:docdb_family_id;index"`
+	InpadocFamilyID    int       `gorm:"column:inpadoc_family_id;index"`
+	DocdbFamilySize    int       `gorm:"column:docdb_family_size"`
+	NbCitingDocdbFam   int       `gorm:"column:nb_citing_docdb_fam"`
+	NbApplicants       int       `gorm:"column:nb_applicants"`
+	NbInventors        int       `gorm:"column:nb_inventors"`
+}
+
+// TableName is the name of the table
+func (Tls201Appln) TableName() string { return "tls201_appln" }
+
+// Tls202ApplnTitle is the title of an application
+type Tls202ApplnTitle struct {
+	ApplnID      int    `gorm:"column:appln_id;primaryKey;autoIncrement:false"`
+	ApplnTitleLg string `gorm:"column:appln_title_lg"`
+	ApplnTitle   string `gorm:"column:appln_title"`
+}
+
+// TableName is the name of the table
+func (Tls202ApplnTitle) TableName() string { return "tls202_appln_title" }
+
+// Tls203ApplnAbstr is the abstract of an application
+type Tls203ApplnAbstr struct {
+	ApplnID         int    `gorm:"column:appln_id;primaryKey;autoIncrement:false"`
+	ApplnAbstractLg string `gorm:"column:appln_abstract_lg"`
+	ApplnAbstract   string `gorm:"column:appln_abstract"`
+}
+
+// TableName is the name of the table
+func (Tls203ApplnAbstr) TableName() string { return "tls203_appln_abstr" }
+
+// Tls204ApplnPrior is a priority claim of an application
+type Tls204ApplnPrior struct {
+	ApplnID         int `gorm:"column:appln_id;primaryKey;autoIncrement:false"`
+	PriorApplnID    int `gorm:"column:prior_appln_id;primaryKey;autoIncrement:false;index"`
+	PriorApplnSeqNr int `gorm:"column:prior_appln_seq_nr"`
+}
+
+// TableName is the name of the table
+func (Tls204ApplnPrior) TableName() string { return "tls204_appln_prior" }
+
+// Tls206Person is an applicant or inventor
+type Tls206Person struct {
+	PersonID         int    `gorm:"column:person_id;primaryKey;autoIncrement:false"`
+	PersonName       string `gorm:"column:person_name"`
+	PersonNameOrigLg string `gorm:"column:person_name_orig_lg"`
+	PersonAddress    string `gorm:"column:person_address"`
+	PersonCtryCode   string `gorm:"column:person_ctry_code"`
+	Nuts             string `gorm:"column:nuts"`
+	NutsLevel        int    `gorm:"column:nuts_level"`
+	DocStdNameID     int    `gorm:"column:doc_std_name_id"`
+	DocStdName       string `gorm:"column:doc_std_name"`
+	PsnID            int    `gorm:"column:psn_id;index"`
+	PsnName          string `gorm:"column:psn_name"`
+	PsnLevel         int    `gorm:"column:psn_level"`
+	PsnSector        string `gorm:"column:psn_sector"`
+	HanID            int    `gorm:"column:han_id;index"`
+	HanName          string `gorm:"column:han_name"`
+	HanHarmonized    int    `gorm:"column:han_harmonized"`
+}
+
+// TableName is the name of the table
+func (Tls206Person) TableName() string { return "tls206_person" }
+
+// Tls207PersAppln links the persons with the applications
+type Tls207PersAppln struct {
+	PersonID   int `gorm:"column:person_id;primaryKey;autoIncrement:false"`
+	ApplnID    int `gorm:"column:appln_id;primaryKey;autoIncrement:false;index"`
+	AppltSeqNr int `gorm:"column:applt_seq_nr;primaryKey;autoIncrement:false"` // > 0 for applicants
+	InvtSeqNr  int `gorm:"column:invt_seq_nr;primaryKey;autoIncrement:false"`  // > 0 for inventors
+}
+
+// TableName is the name of the table
+func (Tls207PersAppln) TableName() string { return "tls207_pers_appln" }
+
+// Tls209ApplnIpc is an IPC classification of an application
+type Tls209ApplnIpc struct {
+	ApplnID        int       `gorm:"column:appln_id;primaryKey;autoIncrement:false"`
+	IpcClassSymbol string    `gorm:"column:ipc_class_symbol;primaryKey;index"`
+	IpcClassLevel  string    `gorm:"column:ipc_class_level"`
+	IpcVersion     time.Time `gorm:"column:ipc_version"`
+	IpcValue       string    `gorm:"column:ipc_value"`
+	IpcPosition    string    `gorm:"column:ipc_position"`
+	IpcGenerAuth   string    `gorm:"column:ipc_gener_auth"`
+}
+
+// TableName is the name of the table
+func (Tls209ApplnIpc) TableName() string { return "tls209_appln_ipc" }
+
+// Tls211PatPubln is a publication
+type Tls211PatPubln struct {
+	PatPublnID      int       `gorm:"column:pat_publn_id;primaryKey;autoIncrement:false"`
+	PublnAuth       string    `gorm:"column:publn_auth;index:idx_tls211_publn_nr,priority:1"`
+	PublnNr         string    `gorm:"column:publn_nr;index:idx_tls211_publn_nr,priority:2"`
+	PublnNrOriginal string    `gorm:"column:publn_nr_original"`
+	PublnKind       string    `gorm:"column:publn_kind;index:idx_tls211_publn_nr,priority:3"`
+	ApplnID         int       `gorm:"column:appln_id;index"`
+	PublnDate       time.Time `gorm:"column:publn_date"`
+	PublnLg         string    `gorm:"column:publn_lg"`
+	PublnFirstGrant string    `gorm:"column:publn_first_grant"` // Y or N
+	PublnClaims     int       `gorm:"column:publn_claims"`
+}
+
+// TableName is the name of the table
+func (Tls211PatPubln) TableName() string { return "tls211_pat_publn" }
+
+// Tls212Citation is a citation of a publication
+type Tls212Citation struct {
+	PatPublnID      int    `gorm:"column:pat_publn_id;primaryKey;autoIncrement:false"`
+	CitnReplenished int    `gorm:"column:citn_replenished;primaryKey;autoIncrement:false"`
+	CitnID          int    `gorm:"column:citn_id;primaryKey;autoIncrement:false"`
+	CitnOrigin      string `gorm:"column:citn_origin"`
+	CitedPatPublnID int    `gorm:"column:cited_pat_publn_id;index"`
+	CitedApplnID    int    `gorm:"column:cited_appln_id;index"`
+	PatCitnSeqNr    int    `gorm:"column:pat_citn_seq_nr"`
+	CitedNplPublnID string `gorm:"column:cited_npl_publn_id"`
+	NplCitnSeqNr    int    `gorm:"column:npl_citn_seq_nr"`
+	CitnGenerAuth   string `gorm:"column:citn_gener_auth"`
+}
+
+// TableName is the name of the table
+func (Tls212Citation) TableName() string { return "tls212_citation" }
+
+// Tls224ApplnCpc is a CPC classification of an application
+type Tls224ApplnCpc struct {
+	ApplnID        int    `gorm:"column:appln_id;primaryKey;autoIncrement:false"`
+	CpcClassSymbol string `gorm:"column:cpc_class_symbol;primaryKey;index"`
+}
+
+// TableName is the name of the table
+func (Tls224ApplnCpc) TableName() string { return "tls224_appln_cpc" }
+
+// Tls227PersPubln links the persons with the publications
+type Tls227PersPubln struct {
+	PersonID   int `gorm:"column:person_id;primaryKey;autoIncrement:false"`
+	PatPublnID int `gorm:"column:pat_publn_id;primaryKey;autoIncrement:false;index"`
+	AppltSeqNr int `gorm:"column:applt_seq_nr;primaryKey;autoIncrement:false"`
+	InvtSeqNr  int `gorm:"column:invt_seq_nr;primaryKey;autoIncrement:false"`
+}
+
+// TableName is the name of the table
+func (Tls227PersPubln) TableName() string { return "tls227_pers_publn" }
+
+// Tables are the models of the tables that can be loaded, by the prefix of their file names e.g. tls201
+var Tables = map[string]any{
+	"tls201": &Tls201Appln{},
+	"tls202": &Tls202ApplnTitle{},
+	"tls203": &Tls203ApplnAbstr{},
+	"tls204": &Tls204ApplnPrior{},
+	"tls206": &Tls206Person{},
+	"tls207": &Tls207PersAppln{},
+	"tls209": &Tls209ApplnIpc{},
+	"tls211": &Tls211PatPubln{},
+	"tls212": &Tls212Citation{},
+	"tls224": &Tls224ApplnCpc{},
+	"tls227": &Tls227PersPubln{},
+}
@@ -0,0 +1,165 @@
+package epo_patstat
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"path/filepath"
+	"reflect"
+)
+
+// RegisterHandler is a function that handles the records of the EP Register,
+// the type of the record depends on the table, e.g. RegisterParty for reg107_parties
+type RegisterHandler func(fileName string, record RegisterRecord)
+
+// PrintRegisterHandler is a dummy handler that prints the file name and the record
+func PrintRegisterHandler(fileName string, record RegisterRecord) {
+	fmt.Println(fileName, record)
+}
+
+// RegisterReader reads the CSV files of the PATSTAT EP Register
+// (epo_bbds.EpoPatstatEpRegisterProductID) and passes the records to the handler.
+// The rows are passed in the order of the files, the tables are not joined.
+type RegisterReader struct {
+	Handler       RegisterHandler
+	includeTables map[string]struct{} // e.g. reg101, reg107
+}
+
+// NewRegisterReader creates a new reader of the EP Register,
+// the default handler is PrintRegisterHandler
+func NewRegisterReader() *RegisterReader {
+	return &RegisterReader{
+		Handler:       PrintRegisterHandler,
+		includeTables: map[string]struct{}{},
+	}
+}
+
+// SetHandler sets the handler of the records
+func (r *RegisterReader) SetHandler(fn RegisterHandler) *RegisterReader {
+	r.Handler = fn
+	return r
+}
+
+// IncludeTables sets the tables to read, e.g. reg107 or reg107_parties.
+// If no tables are included all tables of RegisterTables are read.
+func (r *RegisterReader) IncludeTables(tables ...string) *RegisterReader {
+	r.includeTables = map[string]struct{}{}
+	for _, t := range tables {
+		r.includeTables[tablePrefix(t)] = struct{}{}
+	}
+	return r
+}
+
+// includesTable checks if the table is read
+func (r *RegisterReader) includesTable(table string) bool {
+	if _, ok := RegisterTables[table]; !ok {
+		return false
+	}
+	if len(r.includeTables) == 0 {
+		return true
+	}
+	_, ok := r.includeTables[table]
+	return ok
+}
+
+// walker returns the walker that reads the files of the included tables
+func (r *RegisterReader) walker() csvWalker {
+	return csvWalker{
+		skip: func(name string) (bool, error) {
+			return !r.includesTable(tablePrefix(name)), nil
+		},
+		visit: func(ctx context.Context, source string, rd io.Reader) error {
+			_, err := r.ReadCSVContext(ctx, filepath.Base(source), rd)
+			return err
+		},
+	}
+}
+
+// ReadCSV reads a CSV file of a table, e.g. reg107_parties.csv
+func (r *RegisterReader) ReadCSV(fileName string, rd io.Reader) (rows int64, err error) {
+	return r.ReadCSVContext(context.Background(), fileName, rd)
+}
+
+// ReadCSVContext reads a CSV file of a table, e.g. reg107_parties.csv,
+// and passes the records to the handler. The table is detected by the prefix of the file name
+// and the columns are mapped by the names in the header.
+func (r *RegisterReader) ReadCSVContext(ctx context.Context, fileName string, rd io.Reader) (rows int64, err error) {
+	logger := slog.With("fileName", fileName)
+	table := tablePrefix(filepath.Base(fileName))
+	model, ok := RegisterTables[table]
+	if !ok {
+		err = fmt.Errorf("%w: %s", ErrUnknownTable, fileName)
+		logger.With("err", err).Error("failed to read file")
+		return
+	}
+	logger.Debug("read file")
+	rows, err = decodeCSV(partName(fileName), model, rd, func(row reflect.Value) error {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		r.Handler(fileName, row.Interface().(RegisterRecord))
+		return nil
+	})
+	if err != nil && ctx.Err() == nil {
+		logger.With("err", err).Error("failed to read file")
+	}
+	return
+}
+
+// ReadDirectory reads the archives and CSV files of a directory
+func (r *RegisterReader) ReadDirectory(directoryPath string) (err error) {
+	return r.ReadDirectoryContext(context.Background(), directoryPath)
+}
+
+// ReadDirectoryContext reads the archives and CSV files of a directory in the order of their names
+func (r *RegisterReader) ReadDirectoryContext(ctx context.Context, directoryPath string) (err error) {
+	logger := slog.With("wd", directoryPath)
+	filePaths, err := archiveFiles(directoryPath)
+	if err != nil {
+		logger.With("err", err).Error("failed to walk dir")
+		return
+	}
+	walker := r.walker()
+	for _, filePath := range filePaths {
+		err = walker.walkFile(ctx, filePath)
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+// ReadBulkZipFile reads an archive of the EP Register
+func (r *RegisterReader) ReadBulkZipFile(filePath string) (err error) {
+	return r.ReadBulkZipFileContext(context.Background(), filePath)
+}
+
+// ReadBulkZipFileContext reads an archive of the EP Register.
+// The CSV files are either directly in the archive or in zip files within the archive,
+// other files are skipped.
+func (r *RegisterReader) ReadBulkZipFileContext(ctx context.Context, filePath string) (err error) {
+	return r.walker().walkZipFile(ctx, filePath)
+}
+
+// ProcessBulkZipFileContext reads an archive of the EP Register,
+// so the reader can be used as an epo_bbds.BulkFileProcessor, e.g. in a watcher
+func (r *RegisterReader) ProcessBulkZipFileContext(ctx context.Context, filePath string) error {
+	return r.ReadBulkZipFileContext(ctx, filePath)
+}
+
+// ReadBulkZipStream reads an archive of the EP Register from a stream
+func (r *RegisterReader) ReadBulkZipStream(name string, rd io.Reader) (err error) {
+	return r.ReadBulkZipStreamContext(context.Background(), name, rd)
+}
+
+// ReadBulkZipStreamContext reads an archive of the EP Register from a stream,
+// e.g. the body of a download, without writing it to disk.
+// The stream is read to the end, so that a checksum of the stream is verified.
+func (r *RegisterReader) ReadBulkZipStreamContext(ctx context.Context, name string, rd io.Reader) (err error) {
+	err = r.walker().walkStream(ctx, name, rd)
+	if err != nil && ctx.Err() == nil {
+		slog.With("err", err, "stream", name).Error("failed to read bulk zip stream")
+	}
+	return
+}
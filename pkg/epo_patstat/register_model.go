@@ -0,0 +1,142 @@
+package epo_patstat
+
+import "time"
+
+// The models follow the data catalog of PATSTAT EP Register.
+// The records of all tables are linked by the id of the application in the register,
+// e.g. the parties and the events of an application have the id of its RegisterApplication.
+// Changes are published in the bulletin, IsLatest marks the current value.
+
+// RegisterRecord is a row of a table of the EP Register
+type RegisterRecord interface {
+	// RegisterID returns the id of the application in the register
+	RegisterID() int
+}
+
+// RegisterApplication is an application including its legal status
+type RegisterApplication struct {
+	ID         int       `gorm:"column:id;primaryKey;autoIncrement:false"`
+	ApplnID    int       `gorm:"column:appln_id;index"` // appln_id of tls201_appln of PATSTAT Global
+	ApplnNr    string    `gorm:"column:appln_nr"`
+	FilingDate time.Time `gorm:"column:filing_date"`
+	LgFiled    string    `gorm:"column:lg_filed"`
+	LgProc     string    `gorm:"column:lg_proc"`
+	Status     string    `gorm:"column:status"` // legal status, e.g. the patent has been granted
+	StatusDate time.Time `gorm:"column:status_date"`
+}
+
+// TableName is the name of the table
+func (RegisterApplication) TableName() string { return "reg101_appln" }
+
+// RegisterID returns the id of the application in the register
+func (r RegisterApplication) RegisterID() int { return r.ID }
+
+// RegisterPublication is a publication of an application
+type RegisterPublication struct {
+	ID        int       `gorm:"column:id;primaryKey;autoIncrement:false"`
+	PublnAuth string    `gorm:"column:publn_auth;primaryKey"`
+	PublnNr   string    `gorm:"column:publn_nr;primaryKey"`
+	PublnKind string    `gorm:"column:publn_kind;primaryKey"`
+	PublnDate time.Time `gorm:"column:publn_date"`
+	PublnLg   string    `gorm:"column:publn_lg"`
+}
+
+// TableName is the name of the table
+func (RegisterPublication) TableName() string { return "reg102_pat_publn" }
+
+// RegisterID returns the id of the application in the register
+func (r RegisterPublication) RegisterID() int { return r.ID }
+
+// RegisterParty is an applicant, inventor or representative of an application
+type RegisterParty struct {
+	ID           int       `gorm:"column:id;index"`
+	Type         string    `gorm:"column:type"` // e.g. applicant, inventor or representative
+	BulletinYear int       `gorm:"column:bulletin_year"`
+	BulletinNr   int       `gorm:"column:bulletin_nr"`
+	ChangeDate   time.Time `gorm:"column:change_date"`
+	IsLatest     bool      `gorm:"column:is_latest"`
+	SeqNr        int       `gorm:"column:seq_nr"`
+	Name         string    `gorm:"column:name"`
+	Address      string    `gorm:"column:address"`
+	Country      string    `gorm:"column:country"`
+}
+
+// TableName is the name of the table
+func (RegisterParty) TableName() string { return "reg107_parties" }
+
+// RegisterID returns the id of the application in the register
+func (r RegisterParty) RegisterID() int { return r.ID }
+
+// RegisterDesignatedState is a designated state of an application
+type RegisterDesignatedState struct {
+	ID              int       `gorm:"column:id;index"`
+	BulletinYear    int       `gorm:"column:bulletin_year"`
+	BulletinNr      int       `gorm:"column:bulletin_nr"`
+	ChangeDate      time.Time `gorm:"column:change_date"`
+	IsLatest        bool      `gorm:"column:is_latest"`
+	DesignatedState string    `gorm:"column:designated_state"` // e.g. DE
+}
+
+// TableName is the name of the table
+func (RegisterDesignatedState) TableName() string { return "reg108_desig_states" }
+
+// RegisterID returns the id of the application in the register
+func (r RegisterDesignatedState) RegisterID() int { return r.ID }
+
+// RegisterProceduralStep is a step of the procedure of an application, e.g. the request for examination
+type RegisterProceduralStep struct {
+	ID          int    `gorm:"column:id;index"`
+	StepID      int    `gorm:"column:step_id"`
+	StepCode    string `gorm:"column:step_code"`
+	StepDescr   string `gorm:"column:step_descr"`
+	StepPhase   string `gorm:"column:step_phase"`
+	StepDescrLg string `gorm:"column:step_descr_lg"`
+}
+
+// TableName is the name of the table
+func (RegisterProceduralStep) TableName() string { return "reg201_proc_step" }
+
+// RegisterID returns the id of the application in the register
+func (r RegisterProceduralStep) RegisterID() int { return r.ID }
+
+// RegisterProceduralStepDate is a date of a procedural step, e.g. the dispatch or the payment
+type RegisterProceduralStepDate struct {
+	ID           int       `gorm:"column:id;index"`
+	StepID       int       `gorm:"column:step_id"`
+	StepDateType string    `gorm:"column:step_date_type"`
+	StepDate     time.Time `gorm:"column:step_date"`
+}
+
+// TableName is the name of the table
+func (RegisterProceduralStepDate) TableName() string { return "reg203_proc_step_date" }
+
+// RegisterID returns the id of the application in the register
+func (r RegisterProceduralStepDate) RegisterID() int { return r.ID }
+
+// RegisterEvent is a legal event of an application that was published in the bulletin
+type RegisterEvent struct {
+	ID           int       `gorm:"column:id;index"`
+	EventID      int       `gorm:"column:event_id"`
+	EventCode    string    `gorm:"column:event_code"`
+	EventDate    time.Time `gorm:"column:event_date"`
+	EventDescr   string    `gorm:"column:event_descr"`
+	BulletinNr   int       `gorm:"column:bulletin_nr"`
+	BulletinDate time.Time `gorm:"column:bulletin_date"`
+}
+
+// TableName is the name of the table
+func (RegisterEvent) TableName() string { return "reg301_event_data" }
+
+// RegisterID returns the id of the application in the register
+func (r RegisterEvent) RegisterID() int { return r.ID }
+
+// RegisterTables are the models of the tables of the EP Register by the prefix of their file names e.g. reg101
+var RegisterTables = map[string]RegisterRecord{
+	"reg101": RegisterApplication{},
+	"reg102": RegisterPublication{},
+	"reg107": RegisterParty{},
+	"reg108": RegisterDesignatedState{},
+	"reg201": RegisterProceduralStep{},
+	"reg203": RegisterProceduralStepDate{},
+	"reg301": RegisterEvent{},
+}
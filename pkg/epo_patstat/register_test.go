@@ -0,0 +1,153 @@
+package epo_patstat
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	testReg101CSV = "id,appln_id,filing_date,lg_proc,status,status_date\n" +
+		"1001,55,2019-05-06,en,The patent has been granted,2022-01-12\n" +
+		"1002,56,2020-01-02,de,The application has been withdrawn,2021-03-04\n"
+	testReg107CSV = "id,type,bulletin_year,bulletin_nr,change_date,is_latest,seq_nr,name,address,country\n" +
+		"1001,applicant,2020,12,2020-03-18,N,1,ACME,\"Main Street 1, Springfield\",US\n" +
+		"1001,applicant,2021,40,2021-10-06,Y,1,ACME Holding,\"Main Street 1, Springfield\",US\n"
+	testReg108CSV = "id,bulletin_year,bulletin_nr,change_date,is_latest,designated_state\n" +
+		"1001,2020,12,2020-03-18,Y,DE\n1001,2020,12,2020-03-18,Y,FR\n"
+	testReg301CSV = "id,event_id,event_code,event_date,event_descr,bulletin_nr,bulletin_date\n" +
+		"1001,1,0009210,2022-01-12,Patent granted,2,2022-01-12\n"
+)
+
+// recordedRecords records the records of a handler
+type recordedRecords struct {
+	files   []string
+	records []RegisterRecord
+}
+
+func (rr *recordedRecords) handle(fileName string, record RegisterRecord) {
+	rr.files = append(rr.files, fileName)
+	rr.records = append(rr.records, record)
+}
+
+func TestRegisterReaderReadCSV(t *testing.T) {
+	ass := assert.New(t)
+	rr := &recordedRecords{}
+	reader := NewRegisterReader().SetHandler(rr.handle)
+
+	rows, err := reader.ReadCSV("reg101_appln.csv", strings.NewReader(testReg101CSV))
+	ass.NoError(err)
+	ass.Equal(int64(2), rows)
+	rows, err = reader.ReadCSV("reg107_parties.csv", strings.NewReader(testReg107CSV))
+	ass.NoError(err)
+	ass.Equal(int64(2), rows)
+
+	if ass.Len(rr.records, 4) {
+		application, ok := rr.records[0].(RegisterApplication)
+		if ass.True(ok) {
+			ass.Equal(1001, application.RegisterID())
+			ass.Equal(55, application.ApplnID)
+			ass.Equal("The patent has been granted", application.Status)
+			ass.Equal(time.Date(2022, 1, 12, 0, 0, 0, 0, time.UTC), application.StatusDate)
+		}
+		party, ok := rr.records[3].(RegisterParty)
+		if ass.True(ok) {
+			ass.Equal(1001, party.RegisterID())
+			ass.True(party.IsLatest)
+			ass.Equal("ACME Holding", party.Name)
+			ass.Equal("Main Street 1, Springfield", party.Address)
+		}
+		ass.False(rr.records[2].(RegisterParty).IsLatest)
+	}
+	ass.Equal("reg107_parties.csv", rr.files[3])
+
+	_, err = reader.ReadCSV("reg999_unknown.csv", strings.NewReader("id\n1\n"))
+	ass.ErrorIs(err, ErrUnknownTable)
+	_, err = reader.ReadCSV("reg108_desig_states.csv", strings.NewReader("id,is_latest\n1,maybe\n"))
+	ass.ErrorContains(err, "column is_latest")
+}
+
+func TestRegisterReaderReadBulkZipFile(t *testing.T) {
+	ass := assert.New(t)
+	dir := t.TempDir()
+	archive := zipBytes(t,
+		zipEntry{name: "documentation/EP_Register.pdf", content: []byte("pdf")},
+		zipEntry{name: "reg101_appln.csv", content: []byte(testReg101CSV)},
+		partZip(t, "reg107_parties_part01", testReg107CSV),
+		zipEntry{name: "reg108_desig_states.csv", content: []byte(testReg108CSV)},
+		zipEntry{name: "reg301_event_data.csv", content: []byte(testReg301CSV)},
+		zipEntry{name: "reg999_unknown.csv", content: []byte("id\n1\n")},
+	)
+	filePath := filepath.Join(dir, "PATSTAT_EP_Register_2024_Spring.zip")
+	if err := os.WriteFile(filePath, archive, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// the designated states and the legal events of the applications
+	states := map[int][]string{}
+	var events []RegisterEvent
+	reader := NewRegisterReader().
+		IncludeTables("reg108", "reg301_event_data").
+		SetHandler(func(fileName string, record RegisterRecord) {
+			switch r := record.(type) {
+			case RegisterDesignatedState:
+				states[r.ID] = append(states[r.ID], r.DesignatedState)
+			case RegisterEvent:
+				events = append(events, r)
+			default:
+				t.Errorf("unexpected record %T", record)
+			}
+		})
+	ass.NoError(reader.ReadBulkZipFile(filePath))
+	ass.Equal(map[int][]string{1001: {"DE", "FR"}}, states)
+	if ass.Len(events, 1) {
+		ass.Equal("0009210", events[0].EventCode)
+		ass.Equal("Patent granted", events[0].EventDescr)
+	}
+
+	// all tables from a stream
+	rr := &recordedRecords{}
+	reader = NewRegisterReader().SetHandler(rr.handle)
+	r := bytes.NewReader(archive)
+	ass.NoError(reader.ReadBulkZipStreamContext(context.Background(), "register.zip", r))
+	ass.Equal(0, r.Len())
+	ass.Len(rr.records, 7)
+	ass.Contains(rr.files, "reg107_parties_part01.csv")
+
+	// all tables from a directory
+	rr = &recordedRecords{}
+	reader.SetHandler(rr.handle)
+	ass.NoError(reader.ReadDirectory(dir))
+	ass.Len(rr.records, 7)
+}
+
+func TestRegisterReaderCancelled(t *testing.T) {
+	ass := assert.New(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	calls := 0
+	reader := NewRegisterReader().SetHandler(func(fileName string, record RegisterRecord) {
+		calls++
+		cancel()
+	})
+	_, err := reader.ReadCSVContext(ctx, "reg107_parties.csv", strings.NewReader(testReg107CSV))
+	ass.ErrorIs(err, context.Canceled)
+	ass.Equal(1, calls)
+}
+
+func TestRegisterReaderHandlerPanics(t *testing.T) {
+	ass := assert.New(t)
+	archive := zipBytes(t, partZip(t, "reg107_parties_part01", testReg107CSV))
+	reader := NewRegisterReader().SetHandler(func(fileName string, record RegisterRecord) {
+		panic("handler")
+	})
+	// only the reads of the zip stream are recovered, not the handler
+	ass.PanicsWithValue("handler", func() {
+		_ = reader.ReadBulkZipStream("register.zip", bytes.NewReader(archive))
+	})
+}
@@ -0,0 +1,183 @@
+package storage
+
+import (
+	"context"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// tempFilePrefix is the prefix of the temporary files of Local.Put
+const tempFilePrefix = ".storage-tmp-"
+
+// Local stores the objects as files below a root directory
+type Local struct {
+	Root string // root directory
+}
+
+// NewLocal creates a storage in the root directory
+func NewLocal(root string) *Local {
+	return &Local{Root: root}
+}
+
+// path returns the file path of a name
+func (l *Local) path(name string) (p string, err error) {
+	name, err = CleanName(name)
+	if err != nil {
+		return
+	}
+	return filepath.Join(l.Root, filepath.FromSlash(name)), nil
+}
+
+// Put writes the content to a temporary file that is renamed once complete
+func (l *Local) Put(ctx context.Context, name string, r io.Reader) (n int64, err error) {
+	p, err := l.path(name)
+	if err != nil {
+		return
+	}
+	logger := slog.With("path", p)
+	err = os.MkdirAll(filepath.Dir(p), os.ModePerm)
+	if err != nil {
+		logger.With("err", err).Error("failed to create directory")
+		return
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(p), tempFilePrefix+"*")
+	if err != nil {
+		logger.With("err", err).Error("failed to create temporary file")
+		return
+	}
+	defer func() {
+		if err != nil {
+			_ = os.Remove(tmp.Name())
+		}
+	}()
+	n, err = io.Copy(tmp, &contextReader{ctx: ctx, reader: r})
+	errClose := tmp.Close()
+	if err != nil {
+		logger.With("err", err).Error("failed to write file")
+		return
+	}
+	if errClose != nil {
+		err = errClose
+		logger.With("err", err).Error("failed to close file")
+		return
+	}
+	err = os.Rename(tmp.Name(), p)
+	if err != nil {
+		logger.With("err", err).Error("failed to rename temporary file")
+	}
+	return
+}
+
+// Stat returns the info of a file
+func (l *Local) Stat(ctx context.Context, name string) (info ObjectInfo, err error) {
+	p, err := l.path(name)
+	if err != nil {
+		return
+	}
+	fi, err := os.Stat(p)
+	if err != nil {
+		return
+	}
+	if fi.IsDir() {
+		return info, &fs.PathError{Op: "stat", Path: p, Err: ErrNotExist}
+	}
+	name, _ = CleanName(name)
+	return ObjectInfo{Name: name, Size: fi.Size(), ModTime: fi.ModTime()}, nil
+}
+
+// List walks the root directory and returns the files whose name starts with the prefix
+func (l *Local) List(ctx context.Context, prefix string) (objects []ObjectInfo, err error) {
+	prefix = strings.TrimPrefix(strings.ReplaceAll(prefix, "\\", "/"), "/")
+	err = filepath.WalkDir(l.Root, func(p string, d fs.DirEntry, errWalk error) error {
+		if errWalk != nil {
+			if os.IsNotExist(errWalk) && p == l.Root {
+				return filepath.SkipDir
+			}
+			return errWalk
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if d.IsDir() || strings.HasPrefix(d.Name(), tempFilePrefix) {
+			return nil
+		}
+		rel, errRel := filepath.Rel(l.Root, p)
+		if errRel != nil {
+			return errRel
+		}
+		name := filepath.ToSlash(rel)
+		if !strings.HasPrefix(name, prefix) {
+			return nil
+		}
+		fi, errInfo := d.Info()
+		if errInfo != nil {
+			return errInfo
+		}
+		objects = append(objects, ObjectInfo{Name: name, Size: fi.Size(), ModTime: fi.ModTime()})
+		return nil
+	})
+	if err != nil {
+		slog.With("err", err, "root", l.Root).Error("failed to list files")
+		return nil, err
+	}
+	sort.Slice(objects, func(i, j int) bool { return objects[i].Name < objects[j].Name })
+	return
+}
+
+// Open opens a file
+func (l *Local) Open(ctx context.Context, name string) (rc io.ReadCloser, err error) {
+	p, err := l.path(name)
+	if err != nil {
+		return
+	}
+	return os.Open(p)
+}
+
+// Rename moves a file, the directory of the new name is created
+func (l *Local) Rename(ctx context.Context, oldName, newName string) (err error) {
+	oldPath, err := l.path(oldName)
+	if err != nil {
+		return
+	}
+	newPath, err := l.path(newName)
+	if err != nil {
+		return
+	}
+	err = os.MkdirAll(filepath.Dir(newPath), os.ModePerm)
+	if err != nil {
+		return
+	}
+	return os.Rename(oldPath, newPath)
+}
+
+// Remove removes a file
+func (l *Local) Remove(ctx context.Context, name string) (err error) {
+	p, err := l.path(name)
+	if err != nil {
+		return
+	}
+	err = os.Remove(p)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return
+}
+
+// contextReader stops reading once the context is cancelled
+type contextReader struct {
+	ctx    context.Context
+	reader io.Reader
+}
+
+// Read reads from the reader if the context is not cancelled
+func (r *contextReader) Read(p []byte) (n int, err error) {
+	if err = r.ctx.Err(); err != nil {
+		return
+	}
+	return r.reader.Read(p)
+}
@@ -0,0 +1,113 @@
+package storage
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// testStorage runs the same checks against every implementation
+func testStorage(t *testing.T, s Storage) {
+	ass := assert.New(t)
+	ctx := context.Background()
+
+	objects, err := s.List(ctx, "")
+	ass.NoError(err)
+	ass.Empty(objects)
+
+	n, err := s.Put(ctx, "3/DOCDB 2024 week 1/a.zip", strings.NewReader("hello"))
+	ass.NoError(err)
+	ass.Equal(int64(5), n)
+	_, err = s.Put(ctx, "3/DOCDB 2024 week 1/b.zip", strings.NewReader("world!"))
+	ass.NoError(err)
+	_, err = s.Put(ctx, "14/c.zip", strings.NewReader(""))
+	ass.NoError(err)
+
+	info, err := s.Stat(ctx, "3/DOCDB 2024 week 1/b.zip")
+	ass.NoError(err)
+	ass.Equal("3/DOCDB 2024 week 1/b.zip", info.Name)
+	ass.Equal(int64(6), info.Size)
+	ass.False(info.ModTime.IsZero())
+
+	_, err = s.Stat(ctx, "3/missing.zip")
+	ass.ErrorIs(err, ErrNotExist)
+
+	rc, err := s.Open(ctx, "3/DOCDB 2024 week 1/a.zip")
+	ass.NoError(err)
+	content, err := io.ReadAll(rc)
+	ass.NoError(err)
+	ass.NoError(rc.Close())
+	ass.Equal("hello", string(content))
+
+	objects, err = s.List(ctx, "3/")
+	ass.NoError(err)
+	if ass.Len(objects, 2) {
+		ass.Equal("3/DOCDB 2024 week 1/a.zip", objects[0].Name)
+		ass.Equal("3/DOCDB 2024 week 1/b.zip", objects[1].Name)
+	}
+	objects, err = s.List(ctx, "")
+	ass.NoError(err)
+	ass.Len(objects, 3)
+
+	ass.NoError(s.Rename(ctx, "3/DOCDB 2024 week 1/a.zip", "3/archive/a.zip"))
+	_, err = s.Stat(ctx, "3/DOCDB 2024 week 1/a.zip")
+	ass.ErrorIs(err, ErrNotExist)
+	info, err = s.Stat(ctx, "3/archive/a.zip")
+	ass.NoError(err)
+	ass.Equal(int64(5), info.Size)
+
+	ass.NoError(s.Remove(ctx, "3/archive/a.zip"))
+	ass.NoError(s.Remove(ctx, "3/archive/a.zip"))
+	_, err = s.Stat(ctx, "3/archive/a.zip")
+	ass.ErrorIs(err, ErrNotExist)
+
+	_, err = s.Put(ctx, "", strings.NewReader("x"))
+	ass.ErrorIs(err, ErrInvalidName)
+}
+
+func TestLocal(t *testing.T) {
+	ass := assert.New(t)
+	root := t.TempDir()
+	testStorage(t, NewLocal(root))
+	ass.True(fileExists(filepath.Join(root, "3", "DOCDB 2024 week 1", "b.zip")))
+}
+
+func TestLocalPutCancelled(t *testing.T) {
+	ass := assert.New(t)
+	root := t.TempDir()
+	s := NewLocal(root)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := s.Put(ctx, "a.zip", strings.NewReader("hello"))
+	ass.ErrorIs(err, context.Canceled)
+	// neither the object nor the temporary file remain
+	entries, err := os.ReadDir(root)
+	ass.NoError(err)
+	ass.Empty(entries)
+}
+
+func TestCleanName(t *testing.T) {
+	ass := assert.New(t)
+	for name, expected := range map[string]string{
+		"a.zip":          "a.zip",
+		"/3//b/../a.zip": "3/a.zip",
+		"../../a.zip":    "a.zip",
+		"3\\a.zip":       "3/a.zip",
+	} {
+		actual, err := CleanName(name)
+		ass.NoError(err)
+		ass.Equal(expected, actual)
+	}
+	_, err := CleanName("/")
+	ass.ErrorIs(err, ErrInvalidName)
+}
+
+func fileExists(p string) bool {
+	_, err := os.Stat(p)
+	return err == nil
+}
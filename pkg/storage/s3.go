@@ -0,0 +1,417 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultS3Region is the region that is used if no region is set
+const DefaultS3Region = "us-east-1"
+
+// DefaultS3PartSize is the size of the parts of a multipart upload
+const DefaultS3PartSize = 16 << 20
+
+// minS3PartSize is the minimum size of the parts of a multipart upload
+const minS3PartSize = 5 << 20
+
+// S3Config configures an S3-compatible object storage e.g. AWS S3 or MinIO
+type S3Config struct {
+	Endpoint           string       // e.g. "https://s3.eu-central-1.amazonaws.com" or "http://localhost:9000"
+	Region             string       // region of the bucket, DefaultS3Region if empty
+	Bucket             string       // name of the bucket
+	Prefix             string       // optional prefix of all keys e.g. "epo/docdb"
+	AccessKeyID        string       // access key
+	SecretAccessKey    string       // secret key
+	SessionToken       string       // optional session token of temporary credentials
+	VirtualHostedStyle bool         // use <bucket>.<endpoint> instead of the path style <endpoint>/<bucket> that MinIO uses
+	PartSize           int64        // size of the parts of a multipart upload, DefaultS3PartSize if 0
+	HTTPClient         *http.Client // http.DefaultClient if nil
+}
+
+// S3Error is an error response of the object storage.
+// A missing object matches ErrNotExist with errors.Is.
+type S3Error struct {
+	StatusCode int    // http status code
+	Code       string // error code e.g. "NoSuchKey"
+	Message    string // error message
+	Key        string // key of the request
+}
+
+// Error returns the error message
+func (e *S3Error) Error() string {
+	return fmt.Sprintf("s3: %s %s: %s (status %d)", e.Code, e.Key, e.Message, e.StatusCode)
+}
+
+// Is reports if the target is ErrNotExist and the object does not exist
+func (e *S3Error) Is(target error) bool {
+	return target == ErrNotExist && (e.StatusCode == http.StatusNotFound || e.Code == "NoSuchKey")
+}
+
+// S3 stores the objects in a bucket of an S3-compatible object storage.
+// The requests are signed with the AWS signature version 4.
+type S3 struct {
+	cfg      S3Config
+	endpoint *url.URL
+	now      func() time.Time // replaceable for tests
+}
+
+// NewS3 creates a storage in a bucket
+func NewS3(cfg S3Config) (s *S3, err error) {
+	endpoint, err := url.Parse(strings.TrimSuffix(cfg.Endpoint, "/"))
+	if err != nil || endpoint.Host == "" {
+		err = fmt.Errorf("invalid s3 endpoint %q", cfg.Endpoint)
+		slog.With("err", err).Error("failed to create s3 storage")
+		return
+	}
+	if cfg.Bucket == "" {
+		err = errors.New("no s3 bucket set")
+		slog.With("err", err).Error("failed to create s3 storage")
+		return
+	}
+	if cfg.Region == "" {
+		cfg.Region = DefaultS3Region
+	}
+	if cfg.PartSize == 0 {
+		cfg.PartSize = DefaultS3PartSize
+	}
+	if cfg.PartSize < minS3PartSize {
+		cfg.PartSize = minS3PartSize
+	}
+	if cfg.HTTPClient == nil {
+		cfg.HTTPClient = http.DefaultClient
+	}
+	cfg.Prefix = strings.Trim(cfg.Prefix, "/")
+	return &S3{cfg: cfg, endpoint: endpoint, now: time.Now}, nil
+}
+
+// key returns the key of a name
+func (s *S3) key(name string) (key string, err error) {
+	name, err = CleanName(name)
+	if err != nil {
+		return
+	}
+	return path.Join(s.cfg.Prefix, name), nil
+}
+
+// name returns the name of a key
+func (s *S3) name(key string) string {
+	if s.cfg.Prefix == "" {
+		return key
+	}
+	return strings.TrimPrefix(key, s.cfg.Prefix+"/")
+}
+
+// url returns the url of a key or of the bucket if the key is empty
+func (s *S3) url(key string, query url.Values) *url.URL {
+	u := *s.endpoint
+	p := u.Path + "/"
+	if s.cfg.VirtualHostedStyle {
+		u.Host = s.cfg.Bucket + "." + u.Host
+	} else {
+		p += s.cfg.Bucket + "/"
+	}
+	p += key
+	u.Path = p
+	u.RawPath = uriEncode(p, false)
+	u.RawQuery = query.Encode()
+	return &u
+}
+
+// do sends a signed request and returns an *S3Error if the status code is not 2xx
+func (s *S3) do(ctx context.Context, method, key string, query url.Values, header http.Header, body []byte) (resp *http.Response, err error) {
+	var reader io.Reader
+	payloadHash := emptyPayloadHash
+	if body != nil {
+		reader = bytes.NewReader(body)
+		payloadHash = hexSHA256(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, s.url(key, query).String(), reader)
+	if err != nil {
+		return
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	signV4(req, s.cfg.AccessKeyID, s.cfg.SecretAccessKey, s.cfg.SessionToken, s.cfg.Region, "s3", payloadHash, s.now())
+	resp, err = s.cfg.HTTPClient.Do(req)
+	if err != nil {
+		return
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		err = newS3Error(resp, key)
+		_ = resp.Body.Close()
+		return nil, err
+	}
+	return
+}
+
+// newS3Error reads the error of the response
+func newS3Error(resp *http.Response, key string) *S3Error {
+	e := &S3Error{StatusCode: resp.StatusCode, Key: key, Code: http.StatusText(resp.StatusCode)}
+	var body struct {
+		Code    string `xml:"Code"`
+		Message string `xml:"Message"`
+	}
+	b, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	if xml.Unmarshal(b, &body) == nil && body.Code != "" {
+		e.Code, e.Message = body.Code, body.Message
+	}
+	return e
+}
+
+// Put uploads the content with a single request if it fits into one part
+// and with a multipart upload otherwise
+func (s *S3) Put(ctx context.Context, name string, r io.Reader) (n int64, err error) {
+	key, err := s.key(name)
+	if err != nil {
+		return
+	}
+	logger := slog.With("bucket", s.cfg.Bucket, "key", key)
+
+	// small objects only allocate their size
+	var first bytes.Buffer
+	size, err := io.CopyN(&first, r, s.cfg.PartSize)
+	if err == io.EOF {
+		// the content fits into a single request
+		resp, errPut := s.do(ctx, http.MethodPut, key, nil, nil, first.Bytes())
+		if errPut != nil {
+			logger.With("err", errPut).Error("failed to put object")
+			return 0, errPut
+		}
+		_ = resp.Body.Close()
+		return size, nil
+	}
+	if err != nil {
+		logger.With("err", err).Error("failed to read content")
+		return
+	}
+	return s.putMultipart(ctx, key, first.Bytes(), r)
+}
+
+// putMultipart uploads the content in parts, the first part is already read
+func (s *S3) putMultipart(ctx context.Context, key string, first []byte, r io.Reader) (n int64, err error) {
+	logger := slog.With("bucket", s.cfg.Bucket, "key", key)
+	resp, err := s.do(ctx, http.MethodPost, key, url.Values{"uploads": {""}}, nil, nil)
+	if err != nil {
+		logger.With("err", err).Error("failed to create multipart upload")
+		return
+	}
+	var upload struct {
+		UploadID string `xml:"UploadId"`
+	}
+	err = xml.NewDecoder(resp.Body).Decode(&upload)
+	_ = resp.Body.Close()
+	if err != nil {
+		logger.With("err", err).Error("failed to decode multipart upload")
+		return
+	}
+	defer func() {
+		if err != nil {
+			// abort the upload with a new context, the context of the upload may be cancelled
+			abortCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			defer cancel()
+			resp, errAbort := s.do(abortCtx, http.MethodDelete, key, url.Values{"uploadId": {upload.UploadID}}, nil, nil)
+			if errAbort != nil {
+				logger.With("err", errAbort).Error("failed to abort multipart upload")
+				return
+			}
+			_ = resp.Body.Close()
+		}
+	}()
+
+	type completedPart struct {
+		PartNumber int    `xml:"PartNumber"`
+		ETag       string `xml:"ETag"`
+	}
+	var parts []completedPart
+	buf := make([]byte, s.cfg.PartSize)
+	size := copy(buf, first)
+	for partNumber := 1; size > 0; partNumber++ {
+		query := url.Values{"partNumber": {strconv.Itoa(partNumber)}, "uploadId": {upload.UploadID}}
+		resp, err = s.do(ctx, http.MethodPut, key, query, nil, buf[:size])
+		if err != nil {
+			logger.With("err", err, "partNumber", partNumber).Error("failed to upload part")
+			return
+		}
+		_ = resp.Body.Close()
+		parts = append(parts, completedPart{PartNumber: partNumber, ETag: resp.Header.Get("ETag")})
+		n += int64(size)
+
+		size, err = io.ReadFull(r, buf)
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			err = nil
+		}
+		if err != nil {
+			logger.With("err", err).Error("failed to read content")
+			return
+		}
+	}
+
+	complete, err := xml.Marshal(struct {
+		XMLName xml.Name        `xml:"CompleteMultipartUpload"`
+		Parts   []completedPart `xml:"Part"`
+	}{Parts: parts})
+	if err != nil {
+		return
+	}
+	resp, err = s.do(ctx, http.MethodPost, key, url.Values{"uploadId": {upload.UploadID}}, nil, complete)
+	if err != nil {
+		logger.With("err", err).Error("failed to complete multipart upload")
+		return
+	}
+	err = checkBodyError(resp, key)
+	if err != nil {
+		logger.With("err", err).Error("failed to complete multipart upload")
+	}
+	return
+}
+
+// checkBodyError reads and closes the body and returns the error of a 200 response,
+// some requests e.g. copy and complete return an error with the status code 200
+func checkBodyError(resp *http.Response, key string) error {
+	defer func(Body io.ReadCloser) {
+		_ = Body.Close()
+	}(resp.Body)
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	var body struct {
+		XMLName xml.Name
+		Code    string `xml:"Code"`
+		Message string `xml:"Message"`
+	}
+	if xml.Unmarshal(b, &body) == nil && body.XMLName.Local == "Error" {
+		return &S3Error{StatusCode: resp.StatusCode, Code: body.Code, Message: body.Message, Key: key}
+	}
+	return nil
+}
+
+// Stat returns the info of an object
+func (s *S3) Stat(ctx context.Context, name string) (info ObjectInfo, err error) {
+	key, err := s.key(name)
+	if err != nil {
+		return
+	}
+	resp, err := s.do(ctx, http.MethodHead, key, nil, nil, nil)
+	if err != nil {
+		return
+	}
+	_ = resp.Body.Close()
+	info = ObjectInfo{Name: s.name(key), Size: resp.ContentLength}
+	info.ModTime, _ = http.ParseTime(resp.Header.Get("Last-Modified"))
+	return
+}
+
+// List returns the objects whose name starts with the prefix
+func (s *S3) List(ctx context.Context, prefix string) (objects []ObjectInfo, err error) {
+	prefix = strings.TrimPrefix(strings.ReplaceAll(prefix, "\\", "/"), "/")
+	if s.cfg.Prefix != "" {
+		prefix = s.cfg.Prefix + "/" + prefix
+	}
+	var token string
+	for {
+		query := url.Values{"list-type": {"2"}, "prefix": {prefix}}
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+		var resp *http.Response
+		resp, err = s.do(ctx, http.MethodGet, "", query, nil, nil)
+		if err != nil {
+			slog.With("err", err, "bucket", s.cfg.Bucket, "prefix", prefix).Error("failed to list objects")
+			return nil, err
+		}
+		var result struct {
+			Contents []struct {
+				Key          string    `xml:"Key"`
+				LastModified time.Time `xml:"LastModified"`
+				Size         int64     `xml:"Size"`
+			} `xml:"Contents"`
+			IsTruncated           bool   `xml:"IsTruncated"`
+			NextContinuationToken string `xml:"NextContinuationToken"`
+		}
+		err = xml.NewDecoder(resp.Body).Decode(&result)
+		_ = resp.Body.Close()
+		if err != nil {
+			slog.With("err", err, "bucket", s.cfg.Bucket).Error("failed to decode object list")
+			return nil, err
+		}
+		for _, c := range result.Contents {
+			objects = append(objects, ObjectInfo{Name: s.name(c.Key), Size: c.Size, ModTime: c.LastModified})
+		}
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			break
+		}
+		token = result.NextContinuationToken
+	}
+	sort.Slice(objects, func(i, j int) bool { return objects[i].Name < objects[j].Name })
+	return
+}
+
+// Open downloads an object
+func (s *S3) Open(ctx context.Context, name string) (rc io.ReadCloser, err error) {
+	key, err := s.key(name)
+	if err != nil {
+		return
+	}
+	resp, err := s.do(ctx, http.MethodGet, key, nil, nil, nil)
+	if err != nil {
+		return
+	}
+	return resp.Body, nil
+}
+
+// Rename copies the object to the new name and removes the old object.
+// A single copy request is limited to objects of 5 GB.
+func (s *S3) Rename(ctx context.Context, oldName, newName string) (err error) {
+	oldKey, err := s.key(oldName)
+	if err != nil {
+		return
+	}
+	newKey, err := s.key(newName)
+	if err != nil {
+		return
+	}
+	header := http.Header{}
+	header.Set("X-Amz-Copy-Source", uriEncode("/"+s.cfg.Bucket+"/"+oldKey, false))
+	resp, err := s.do(ctx, http.MethodPut, newKey, nil, header, nil)
+	if err != nil {
+		slog.With("err", err, "from", oldKey, "to", newKey).Error("failed to copy object")
+		return
+	}
+	err = checkBodyError(resp, newKey)
+	if err != nil {
+		slog.With("err", err, "from", oldKey, "to", newKey).Error("failed to copy object")
+		return
+	}
+	return s.Remove(ctx, oldName)
+}
+
+// Remove removes an object
+func (s *S3) Remove(ctx context.Context, name string) (err error) {
+	key, err := s.key(name)
+	if err != nil {
+		return
+	}
+	resp, err := s.do(ctx, http.MethodDelete, key, nil, nil, nil)
+	if errors.Is(err, ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return
+	}
+	_ = resp.Body.Close()
+	return
+}
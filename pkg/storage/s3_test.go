@@ -0,0 +1,237 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	testAccessKeyID     = "minioadmin"
+	testSecretAccessKey = "minioadmin-secret"
+	testBucket          = "epo"
+)
+
+// fakeS3 is a minimal in-memory stand-in of an S3-compatible object storage like MinIO.
+// It verifies the signatures and supports the requests of the S3 storage.
+type fakeS3 struct {
+	mu       sync.Mutex
+	objects  map[string][]byte
+	uploads  map[string]map[int][]byte
+	pageSize int
+}
+
+func newFakeS3(t *testing.T) (*fakeS3, *httptest.Server) {
+	f := &fakeS3{objects: map[string][]byte{}, uploads: map[string]map[int][]byte{}, pageSize: 2}
+	srv := httptest.NewServer(f)
+	t.Cleanup(srv.Close)
+	return f, srv
+}
+
+func newTestS3(t *testing.T, prefix string) (*S3, *fakeS3) {
+	f, srv := newFakeS3(t)
+	s, err := NewS3(S3Config{
+		Endpoint:        srv.URL,
+		Bucket:          testBucket,
+		Prefix:          prefix,
+		AccessKeyID:     testAccessKeyID,
+		SecretAccessKey: testSecretAccessKey,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s, f
+}
+
+func (f *fakeS3) error(w http.ResponseWriter, status int, code string) {
+	w.WriteHeader(status)
+	_, _ = fmt.Fprintf(w, "<Error><Code>%s</Code><Message>%s</Message></Error>", code, code)
+}
+
+// verify signs the request again and compares the signatures
+func (f *fakeS3) verify(r *http.Request, body []byte) bool {
+	auth := r.Header.Get("Authorization")
+	amzDate, err := time.Parse("20060102T150405Z", r.Header.Get("X-Amz-Date"))
+	if err != nil {
+		return false
+	}
+	check, _ := http.NewRequest(r.Method, "http://"+r.Host+r.URL.RequestURI(), nil)
+	for k, v := range r.Header {
+		check.Header[k] = v
+	}
+	payloadHash := r.Header.Get("X-Amz-Content-Sha256")
+	if payloadHash != hexSHA256(body) && !(payloadHash == emptyPayloadHash && len(body) == 0) {
+		return false
+	}
+	signV4(check, testAccessKeyID, testSecretAccessKey, "", DefaultS3Region, "s3", payloadHash, amzDate)
+	return check.Header.Get("Authorization") == auth
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	if !f.verify(r, body) {
+		f.error(w, http.StatusForbidden, "SignatureDoesNotMatch")
+		return
+	}
+	bucket, key, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	if bucket != testBucket {
+		f.error(w, http.StatusNotFound, "NoSuchBucket")
+		return
+	}
+	query := r.URL.Query()
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	switch {
+	case key == "" && r.Method == http.MethodGet:
+		f.list(w, query.Get("prefix"), query.Get("continuation-token"))
+	case r.Method == http.MethodPost && query.Has("uploads"):
+		id := strconv.Itoa(len(f.uploads) + 1)
+		f.uploads[id] = map[int][]byte{}
+		_, _ = fmt.Fprintf(w, "<InitiateMultipartUploadResult><UploadId>%s</UploadId></InitiateMultipartUploadResult>", id)
+	case r.Method == http.MethodPut && query.Has("uploadId"):
+		n, _ := strconv.Atoi(query.Get("partNumber"))
+		f.uploads[query.Get("uploadId")][n] = body
+		w.Header().Set("ETag", fmt.Sprintf("\"etag-%d\"", n))
+	case r.Method == http.MethodPost && query.Has("uploadId"):
+		var complete struct {
+			Parts []struct {
+				PartNumber int `xml:"PartNumber"`
+			} `xml:"Part"`
+		}
+		_ = xml.Unmarshal(body, &complete)
+		var content []byte
+		for _, p := range complete.Parts {
+			content = append(content, f.uploads[query.Get("uploadId")][p.PartNumber]...)
+		}
+		f.objects[key] = content
+		delete(f.uploads, query.Get("uploadId"))
+		_, _ = fmt.Fprint(w, "<CompleteMultipartUploadResult></CompleteMultipartUploadResult>")
+	case r.Method == http.MethodDelete && query.Has("uploadId"):
+		delete(f.uploads, query.Get("uploadId"))
+		w.WriteHeader(http.StatusNoContent)
+	case r.Method == http.MethodPut && r.Header.Get("X-Amz-Copy-Source") != "":
+		source, _ := url.PathUnescape(r.Header.Get("X-Amz-Copy-Source"))
+		source = strings.TrimPrefix(source, "/"+testBucket+"/")
+		content, ok := f.objects[source]
+		if !ok {
+			// the error of a copy is returned with the status code 200
+			_, _ = fmt.Fprint(w, "<Error><Code>NoSuchKey</Code><Message>copy source</Message></Error>")
+			return
+		}
+		f.objects[key] = content
+		_, _ = fmt.Fprint(w, "<CopyObjectResult></CopyObjectResult>")
+	case r.Method == http.MethodPut:
+		f.objects[key] = body
+	case r.Method == http.MethodHead || r.Method == http.MethodGet:
+		content, ok := f.objects[key]
+		if !ok {
+			f.error(w, http.StatusNotFound, "NoSuchKey")
+			return
+		}
+		w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+		w.Header().Set("Last-Modified", time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC).Format(http.TimeFormat))
+		if r.Method == http.MethodGet {
+			_, _ = w.Write(content)
+		}
+	case r.Method == http.MethodDelete:
+		delete(f.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		f.error(w, http.StatusNotImplemented, "NotImplemented")
+	}
+}
+
+// list returns a page of the objects with the prefix, the continuation token is the last key
+func (f *fakeS3) list(w http.ResponseWriter, prefix, token string) {
+	var keys []string
+	for key := range f.objects {
+		if strings.HasPrefix(key, prefix) && key > token {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	truncated := len(keys) > f.pageSize
+	if truncated {
+		keys = keys[:f.pageSize]
+	}
+	var b bytes.Buffer
+	b.WriteString("<ListBucketResult>")
+	for _, key := range keys {
+		fmt.Fprintf(&b, "<Contents><Key>%s</Key><LastModified>2024-01-01T00:00:00.000Z</LastModified><Size>%d</Size></Contents>",
+			key, len(f.objects[key]))
+	}
+	fmt.Fprintf(&b, "<IsTruncated>%t</IsTruncated>", truncated)
+	if truncated {
+		fmt.Fprintf(&b, "<NextContinuationToken>%s</NextContinuationToken>", keys[len(keys)-1])
+	}
+	b.WriteString("</ListBucketResult>")
+	_, _ = w.Write(b.Bytes())
+}
+
+func TestS3(t *testing.T) {
+	ass := assert.New(t)
+	s, f := newTestS3(t, "epo/docdb")
+	testStorage(t, s)
+	// the keys have the prefix
+	ass.Contains(f.objects, "epo/docdb/3/DOCDB 2024 week 1/b.zip")
+}
+
+func TestS3Multipart(t *testing.T) {
+	ass := assert.New(t)
+	s, f := newTestS3(t, "")
+	s.cfg.PartSize = 4
+	ctx := context.Background()
+
+	n, err := s.Put(ctx, "big.zip", strings.NewReader("0123456789"))
+	ass.NoError(err)
+	ass.Equal(int64(10), n)
+	ass.Equal("0123456789", string(f.objects["big.zip"]))
+	ass.Empty(f.uploads)
+
+	// a failed upload is aborted
+	_, err = s.Put(ctx, "broken.zip", io.MultiReader(strings.NewReader("0123456789"), &failingReader{}))
+	ass.Error(err)
+	ass.NotContains(f.objects, "broken.zip")
+	ass.Empty(f.uploads)
+}
+
+func TestS3Errors(t *testing.T) {
+	ass := assert.New(t)
+	s, _ := newTestS3(t, "")
+	ctx := context.Background()
+
+	_, err := s.Open(ctx, "missing.zip")
+	ass.ErrorIs(err, ErrNotExist)
+	err = s.Rename(ctx, "missing.zip", "other.zip")
+	var s3Err *S3Error
+	ass.True(errors.As(err, &s3Err))
+	ass.Equal("NoSuchKey", s3Err.Code)
+
+	// wrong credentials
+	s.cfg.SecretAccessKey = "wrong"
+	_, err = s.Put(ctx, "a.zip", strings.NewReader("a"))
+	ass.True(errors.As(err, &s3Err))
+	ass.Equal(http.StatusForbidden, s3Err.StatusCode)
+	ass.Equal("SignatureDoesNotMatch", s3Err.Code)
+}
+
+type failingReader struct{}
+
+func (r *failingReader) Read(p []byte) (int, error) {
+	return 0, errors.New("read failed")
+}
@@ -0,0 +1,56 @@
+// Package storage provides a common interface for the local filesystem and S3-compatible object storage.
+//
+// Names are slash separated keys relative to the root of the storage e.g. "3/DOCDB 2024 week 1/a.zip".
+// The downloads of epo_bbds and the exports of epo_docdb can both be written to a Storage.
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"path"
+	"strings"
+	"time"
+)
+
+// ErrNotExist is returned if an object does not exist, it is the same as fs.ErrNotExist
+var ErrNotExist = fs.ErrNotExist
+
+// ErrInvalidName is returned if a name is empty
+var ErrInvalidName = errors.New("invalid object name")
+
+// ObjectInfo describes a stored object
+type ObjectInfo struct {
+	Name    string    // name relative to the root of the storage
+	Size    int64     // size in bytes
+	ModTime time.Time // time of the last modification
+}
+
+// Storage stores objects by name
+type Storage interface {
+	// Put stores the content of the reader under the name and returns the written bytes.
+	// The object is only visible under the name once the content is complete.
+	Put(ctx context.Context, name string, r io.Reader) (n int64, err error)
+	// Stat returns the info of an object or an error that matches ErrNotExist
+	Stat(ctx context.Context, name string) (info ObjectInfo, err error)
+	// List returns the objects whose name starts with the prefix ordered by name
+	List(ctx context.Context, prefix string) (objects []ObjectInfo, err error)
+	// Open opens an object for reading, the reader must be closed
+	Open(ctx context.Context, name string) (rc io.ReadCloser, err error)
+	// Rename moves an object to a new name, an existing object with the new name is replaced
+	Rename(ctx context.Context, oldName, newName string) error
+	// Remove removes an object, removing an object that does not exist is not an error
+	Remove(ctx context.Context, name string) error
+}
+
+// CleanName returns the clean slash separated form of a name,
+// names can not leave the root e.g. "../a.zip" is "a.zip"
+func CleanName(name string) (string, error) {
+	name = strings.ReplaceAll(name, "\\", "/")
+	name = strings.TrimPrefix(path.Clean("/"+name), "/")
+	if name == "" || name == "." {
+		return "", ErrInvalidName
+	}
+	return name, nil
+}